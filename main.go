@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
@@ -10,10 +11,15 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
 	"github.com/spf13/pflag"
 )
 
+// tracingShutdownTimeout is how long exporting the remaining spans may take
+// when the proxy stops
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	logger.SetFlags(logger.Lshortfile)
 
@@ -54,6 +60,11 @@ func main() {
 		logger.Fatalf("%s", err)
 	}
 
+	shutdownTracing, err := tracing.Configure(opts.Tracing.OTLPEndpoint, opts.Tracing.ServiceName, opts.Tracing.SampleRatio)
+	if err != nil {
+		logger.Fatalf("ERROR: Failed to configure tracing: %v", err)
+	}
+
 	validator := NewValidator(opts.EmailDomains, opts.AuthenticatedEmailsFile)
 	oauthproxy, err := NewOAuthProxy(opts, validator)
 	if err != nil {
@@ -62,7 +73,16 @@ func main() {
 
 	rand.Seed(time.Now().UnixNano())
 
-	if err := oauthproxy.Start(); err != nil {
+	err = oauthproxy.Start()
+
+	// Export the spans of the final requests before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		logger.Errorf("ERROR: Failed to export traces: %v", shutdownErr)
+	}
+	cancel()
+
+	if err != nil {
 		logger.Fatalf("ERROR: Failed to start OAuth2 Proxy: %v", err)
 	}
 }
@@ -78,6 +78,7 @@ providers:
     insecureSkipNonce: true
     audienceClaims: [aud]
     extraAudiences: []
+    extraClaims: []
   loginURLParameters:
   - name: approval_prompt
     default:
@@ -157,6 +158,7 @@ redirect_url="http://localhost:4180/oauth2/callback"
 					UserIDClaim:       "email",
 					AudienceClaims:    []string{"aud"},
 					ExtraAudiences:    []string{},
+					ExtraClaims:       []string{},
 					InsecureSkipNonce: true,
 				},
 				LoginURLParameters: []options.LoginURLParameter{
@@ -22,21 +22,29 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/admin"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authorization/external"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 	proxyhttp "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/http"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -51,6 +59,12 @@ const (
 	oauthCallbackPath = "/callback"
 	authOnlyPath      = "/auth"
 	userInfoPath      = "/userinfo"
+	jwksPath          = "/jwks.json"
+
+	backChannelLogoutPath = "/backchannel_logout"
+
+	// providerQueryParam selects the provider to start the OAuth flow with
+	providerQueryParam = "provider"
 )
 
 var (
@@ -59,6 +73,10 @@ var (
 
 	// ErrAccessDenied means the user should receive a 401 Unauthorized response
 	ErrAccessDenied = errors.New("access denied")
+
+	// ErrInsufficientScope means the bearer token of the request is not granted
+	// the scopes required for the request
+	ErrInsufficientScope = errors.New("insufficient scope")
 )
 
 // allowedRoute manages method + path based allowlists
@@ -84,6 +102,7 @@ type OAuthProxy struct {
 	redirectURL         *url.URL // the url to receive requests at
 	whitelistDomains    []string
 	provider            providers.Provider
+	providers           map[string]providers.Provider
 	sessionStore        sessionsapi.SessionStore
 	ProxyPrefix         string
 	basicAuthValidator  basic.Validator
@@ -91,6 +110,7 @@ type OAuthProxy struct {
 	SkipProviderButton  bool
 	skipAuthPreflight   bool
 	skipJwtBearerTokens bool
+	bearerScopes        []string
 	forceJSONErrors     bool
 	realClientIPParser  ipapi.RealClientIPParser
 	trustedIPs          *ip.NetSet
@@ -100,10 +120,13 @@ type OAuthProxy struct {
 	preAuthChain      alice.Chain
 	pageWriter        pagewriter.Writer
 	server            proxyhttp.Server
-	upstreamProxy     http.Handler
+	upstreamProxy     upstream.Proxy
 	serveMux          *mux.Router
 	redirectValidator redirect.Validator
 	appDirector       redirect.AppDirector
+
+	externalAuthorizer *external.Authorizer
+	jwtSigner          *header.Signer
 }
 
 // NewOAuthProxy creates a new instance of OAuthProxy from the options provided
@@ -123,9 +146,9 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		}
 	}
 
-	provider, err := providers.NewProvider(opts.Providers[0])
+	provider, providersByID, err := buildProviders(opts.Providers)
 	if err != nil {
-		return nil, fmt.Errorf("error intiailising provider: %v", err)
+		return nil, err
 	}
 
 	pageWriter, err := pagewriter.NewWriter(pagewriter.Opts{
@@ -136,6 +159,7 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		Version:          VERSION,
 		Debug:            opts.Templates.Debug,
 		ProviderName:     buildProviderName(provider, opts.Providers[0].Name),
+		Providers:        buildSignInProviders(opts.Providers, providersByID),
 		SignInMessage:    buildSignInMessage(opts),
 		DisplayLoginForm: basicAuthValidator != nil && opts.Templates.DisplayLoginForm,
 	})
@@ -143,7 +167,14 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, fmt.Errorf("error initialising page writer: %v", err)
 	}
 
-	upstreamProxy, err := upstream.NewProxy(opts.UpstreamServers, opts.GetSignatureData(), pageWriter)
+	apiRoutes, err := buildAPIRoutes(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamProxy, err := upstream.NewProxy(opts.UpstreamServers, opts.GetSignatureData(), pageWriter, func(req *http.Request) bool {
+		return opts.ForceJSONErrors || isAjax(req) || isAPIPath(apiRoutes, req)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error initialising upstream proxy: %v", err)
 	}
@@ -159,7 +190,9 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		redirectURL.Path = fmt.Sprintf("%s/callback", opts.ProxyPrefix)
 	}
 
-	logger.Printf("OAuthProxy configured for %s Client ID: %s", provider.Data().ProviderName, opts.Providers[0].ClientID)
+	for _, providerOpts := range opts.Providers {
+		logger.Printf("OAuthProxy configured for %s Client ID: %s", providersByID[providerOpts.ID].Data().ProviderName, providerOpts.ClientID)
+	}
 	refresh := "disabled"
 	if opts.Cookie.Refresh != time.Duration(0) {
 		refresh = fmt.Sprintf("after %s", opts.Cookie.Refresh)
@@ -181,17 +214,24 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, err
 	}
 
-	apiRoutes, err := buildAPIRoutes(opts)
-	if err != nil {
-		return nil, err
-	}
-
 	preAuthChain, err := buildPreAuthChain(opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not build pre-auth chain: %v", err)
 	}
-	sessionChain := buildSessionChain(opts, provider, sessionStore, basicAuthValidator)
-	headersChain, err := buildHeadersChain(opts)
+	var jwtSigner *header.Signer
+	if opts.UpstreamJWTKeyFile != "" {
+		key, err := os.ReadFile(opts.UpstreamJWTKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read upstream JWT key: %v", err)
+		}
+		jwtSigner, err = header.NewSigner(key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load upstream JWT key: %v", err)
+		}
+	}
+
+	sessionChain := buildSessionChain(opts, provider, providersByID, sessionStore, basicAuthValidator)
+	headersChain, err := buildHeadersChain(opts, provider, providersByID, jwtSigner, upstreamProxy)
 	if err != nil {
 		return nil, fmt.Errorf("could not build headers chain: %v", err)
 	}
@@ -210,6 +250,7 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 
 		ProxyPrefix:         opts.ProxyPrefix,
 		provider:            provider,
+		providers:           providersByID,
 		sessionStore:        sessionStore,
 		redirectURL:         redirectURL,
 		apiRoutes:           apiRoutes,
@@ -217,6 +258,7 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		whitelistDomains:    opts.WhitelistDomains,
 		skipAuthPreflight:   opts.SkipAuthPreflight,
 		skipJwtBearerTokens: opts.SkipJwtBearerTokens,
+		bearerScopes:        opts.RequiredBearerScopes,
 		realClientIPParser:  opts.GetRealClientIPParser(),
 		SkipProviderButton:  opts.SkipProviderButton,
 		forceJSONErrors:     opts.ForceJSONErrors,
@@ -231,6 +273,11 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		upstreamProxy:      upstreamProxy,
 		redirectValidator:  redirectValidator,
 		appDirector:        appDirector,
+		jwtSigner:          jwtSigner,
+	}
+	if opts.ExternalAuthz.URL != "" {
+		logger.Printf("Authorizing requests with external authorization endpoint: %q", opts.ExternalAuthz.URL)
+		p.externalAuthorizer = external.NewAuthorizer(opts.ExternalAuthz)
 	}
 	p.buildServeMux(opts.ProxyPrefix)
 
@@ -267,6 +314,7 @@ func (p *OAuthProxy) setupServer(opts *options.Options) error {
 		BindAddress:       opts.Server.BindAddress,
 		SecureBindAddress: opts.Server.SecureBindAddress,
 		TLS:               opts.Server.TLS,
+		H2C:               opts.Server.H2C,
 	}
 
 	appServer, err := proxyhttp.NewServer(serverOpts)
@@ -284,7 +332,27 @@ func (p *OAuthProxy) setupServer(opts *options.Options) error {
 		return fmt.Errorf("could not build metrics server: %v", err)
 	}
 
-	p.server = proxyhttp.NewServerGroup(appServer, metricsServer)
+	servers := []proxyhttp.Server{appServer, metricsServer}
+
+	// Sessions can only be listed and revoked when they are indexed by the
+	// session store, validation ensures the admin server is otherwise disabled.
+	if indexedStore, ok := p.sessionStore.(sessionsapi.IndexedSessionStore); ok {
+		adminServer, err := proxyhttp.NewServer(proxyhttp.Opts{
+			Handler: admin.NewHandler(admin.HandlerOpts{
+				SessionStore: indexedStore,
+				Token:        opts.AdminToken,
+			}),
+			BindAddress:       opts.AdminServer.BindAddress,
+			SecureBindAddress: opts.AdminServer.SecureBindAddress,
+			TLS:               opts.AdminServer.TLS,
+		})
+		if err != nil {
+			return fmt.Errorf("could not build admin server: %v", err)
+		}
+		servers = append(servers, adminServer)
+	}
+
+	p.server = proxyhttp.NewServerGroup(servers...)
 	return nil
 }
 
@@ -307,6 +375,10 @@ func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	// are not applied.
 	p.buildProxySubrouter(r.PathPrefix(proxyPrefix).Subrouter())
 
+	// Requests to upstreams that allow unauthenticated requests are routed
+	// before the session is loaded, so that they never require a valid session.
+	r.MatcherFunc(p.isUnauthenticatedUpstreamRequest).Handler(p.sessionChain.ThenFunc(p.proxyUnauthenticated))
+
 	// Register serveHTTP last so it catches anything that isn't already caught earlier.
 	// Anything that got to this point needs to have a session loaded.
 	r.PathPrefix("/").Handler(p.sessionChain.ThenFunc(p.Proxy))
@@ -320,16 +392,24 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	s.Path(signOutPath).HandlerFunc(p.SignOut)
 	s.Path(oauthStartPath).HandlerFunc(p.OAuthStart)
 	s.Path(oauthCallbackPath).HandlerFunc(p.OAuthCallback)
+	s.Path(backChannelLogoutPath).HandlerFunc(p.BackChannelLogout)
 
 	// The userinfo endpoint needs to load sessions before handling the request
 	s.Path(userInfoPath).Handler(p.sessionChain.ThenFunc(p.UserInfo))
+
+	if p.jwtSigner != nil {
+		s.Path(jwksPath).HandlerFunc(p.JWKS)
+	}
 }
 
 // buildPreAuthChain constructs a chain that should process every request before
 // the OAuth2 Proxy authentication logic kicks in.
 // For example forcing HTTPS or health checks.
 func buildPreAuthChain(opts *options.Options) (alice.Chain, error) {
-	chain := alice.New(middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader))
+	chain := alice.New(
+		middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader),
+		middleware.NewTracing(),
+	)
 
 	if opts.ForceHTTPS {
 		_, httpsPort, err := net.SplitHostPort(opts.Server.SecureBindAddress)
@@ -366,20 +446,35 @@ func buildPreAuthChain(opts *options.Options) (alice.Chain, error) {
 	return chain, nil
 }
 
-func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
+func buildSessionChain(opts *options.Options, provider providers.Provider, providersByID map[string]providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
 	chain := alice.New()
 
 	if opts.SkipJwtBearerTokens {
-		sessionLoaders := []middlewareapi.TokenToSessionFunc{
-			provider.CreateSessionFromToken,
+		sessionLoaders := []middlewareapi.TokenToSessionFunc{}
+		opaqueSessionLoaders := []middlewareapi.TokenToSessionFunc{}
+		// JWTs rejected by the verifier of their issuer are not introspected
+		jwtIssuers := []string{}
+		for _, providerOpts := range opts.Providers {
+			sessionLoaders = append(sessionLoaders,
+				createProviderSessionFromToken(providerOpts.ID, providersByID[providerOpts.ID]))
+			if providersByID[providerOpts.ID].Data().Verifier != nil && providerOpts.OIDCConfig.IssuerURL != "" {
+				jwtIssuers = append(jwtIssuers, providerOpts.OIDCConfig.IssuerURL)
+			}
+			if providerOpts.IntrospectURL != "" {
+				opaqueSessionLoaders = append(opaqueSessionLoaders,
+					createProviderSessionFromIntrospection(providerOpts.ID, providersByID[providerOpts.ID]))
+			}
 		}
 
 		for _, verifier := range opts.GetJWTBearerVerifiers() {
 			sessionLoaders = append(sessionLoaders,
 				middlewareapi.CreateTokenToSessionFunc(verifier.Verify))
 		}
+		for _, issuer := range opts.ExtraJwtIssuers {
+			jwtIssuers = append(jwtIssuers, strings.SplitN(issuer, "=", 2)[0])
+		}
 
-		chain = chain.Append(middleware.NewJwtSessionLoader(sessionLoaders))
+		chain = chain.Append(middleware.NewJwtSessionLoader(sessionLoaders, opaqueSessionLoaders, jwtIssuers))
 	}
 
 	if validator != nil {
@@ -389,20 +484,103 @@ func buildSessionChain(opts *options.Options, provider providers.Provider, sessi
 	chain = chain.Append(middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
 		SessionStore:    sessionStore,
 		RefreshPeriod:   opts.Cookie.Refresh,
-		RefreshSession:  provider.RefreshSession,
-		ValidateSession: provider.ValidateSession,
+		RefreshSession:  refreshSessionWithProvider(provider, providersByID),
+		ValidateSession: validateSessionWithProvider(provider, providersByID),
 	}))
 
 	return chain
 }
 
-func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
-	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders)
+// refreshSessionWithProvider refreshes sessions using the provider that
+// authenticated them.
+func refreshSessionWithProvider(defaultProvider providers.Provider, providersByID map[string]providers.Provider) func(context.Context, *sessionsapi.SessionState) (bool, error) {
+	return func(ctx context.Context, s *sessionsapi.SessionState) (bool, error) {
+		provider := selectProvider(defaultProvider, providersByID, s.ProviderID)
+		if provider == nil {
+			return false, fmt.Errorf("unknown provider %q", s.ProviderID)
+		}
+		return provider.RefreshSession(ctx, s)
+	}
+}
+
+// validateSessionWithProvider validates sessions using the provider that
+// authenticated them. Sessions from unknown providers are never valid.
+func validateSessionWithProvider(defaultProvider providers.Provider, providersByID map[string]providers.Provider) func(context.Context, *sessionsapi.SessionState) bool {
+	return func(ctx context.Context, s *sessionsapi.SessionState) bool {
+		provider := selectProvider(defaultProvider, providersByID, s.ProviderID)
+		if provider == nil {
+			return false
+		}
+		return provider.ValidateSession(ctx, s)
+	}
+}
+
+// createProviderSessionFromToken wraps the provider's CreateSessionFromToken
+// so that sessions created from bearer tokens record the provider they
+// were issued for.
+func createProviderSessionFromToken(providerID string, provider providers.Provider) middlewareapi.TokenToSessionFunc {
+	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
+		session, err := provider.CreateSessionFromToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		session.ProviderID = providerID
+		return session, nil
+	}
+}
+
+// createProviderSessionFromIntrospection creates sessions from bearer tokens
+// introspected at the provider's introspection endpoint, recording the
+// provider they were issued for.
+func createProviderSessionFromIntrospection(providerID string, provider providers.Provider) middlewareapi.TokenToSessionFunc {
+	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
+		session, err := provider.Data().IntrospectToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		session.ProviderID = providerID
+		return session, nil
+	}
+}
+
+// exchangeTokenWithProvider exchanges session access tokens with the
+// provider that authenticated the session, for tokens issued for the
+// audience and scopes of the upstream's token exchange
+func exchangeTokenWithProvider(upstreams options.UpstreamConfig, defaultProvider providers.Provider, providersByID map[string]providers.Provider) header.TokenExchangeFunc {
+	tokenExchanges := make(map[string]*options.TokenExchange)
+	for _, upstream := range upstreams.Upstreams {
+		if upstream.TokenExchange != nil {
+			tokenExchanges[upstream.ID] = upstream.TokenExchange
+		}
+	}
+
+	return func(ctx context.Context, s *sessionsapi.SessionState, upstream string) (*oauth2.Token, error) {
+		tokenExchange, ok := tokenExchanges[upstream]
+		if !ok {
+			return nil, fmt.Errorf("upstream %q has no token exchange", upstream)
+		}
+		provider := selectProvider(defaultProvider, providersByID, s.ProviderID)
+		if provider == nil {
+			return nil, fmt.Errorf("unknown provider %q", s.ProviderID)
+		}
+		return provider.ExchangeToken(ctx, s, tokenExchange.Audience, tokenExchange.Scopes)
+	}
+}
+
+func buildHeadersChain(opts *options.Options, provider providers.Provider, providersByID map[string]providers.Provider, signer *header.Signer, upstreamProxy upstream.Proxy) (alice.Chain, error) {
+	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders, header.InjectorOpts{
+		ExchangeToken: exchangeTokenWithProvider(opts.UpstreamServers, provider, providersByID),
+		MatchUpstream: upstreamProxy.MatchUpstream,
+		Signer:        signer,
+	})
 	if err != nil {
 		return alice.Chain{}, fmt.Errorf("error constructing request header injector: %v", err)
 	}
 
-	responseInjector, err := middleware.NewResponseHeaderInjector(opts.InjectResponseHeaders)
+	// Exchanged tokens are only sent to their upstream, never back to the client
+	responseInjector, err := middleware.NewResponseHeaderInjector(opts.InjectResponseHeaders, header.InjectorOpts{
+		Signer: signer,
+	})
 	if err != nil {
 		return alice.Chain{}, fmt.Errorf("error constructing request header injector: %v", err)
 	}
@@ -435,6 +613,55 @@ func buildProviderName(p providers.Provider, override string) string {
 	return p.Data().ProviderName
 }
 
+// buildProviders initialises each of the configured providers.
+// The first provider is returned as the default provider along with a map
+// of all providers keyed by their ID.
+func buildProviders(providerOpts options.Providers) (providers.Provider, map[string]providers.Provider, error) {
+	var defaultProvider providers.Provider
+	providersByID := make(map[string]providers.Provider, len(providerOpts))
+
+	for _, opts := range providerOpts {
+		provider, err := providers.NewProvider(opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error intiailising provider %q: %v", opts.ID, err)
+		}
+		if defaultProvider == nil {
+			defaultProvider = provider
+		}
+		providersByID[opts.ID] = provider
+	}
+
+	return defaultProvider, providersByID, nil
+}
+
+// buildSignInProviders lists the providers a user can choose from on the
+// sign in page.
+func buildSignInProviders(providerOpts options.Providers, providersByID map[string]providers.Provider) []pagewriter.SignInProvider {
+	signInProviders := make([]pagewriter.SignInProvider, 0, len(providerOpts))
+	for _, opts := range providerOpts {
+		signInProviders = append(signInProviders, pagewriter.SignInProvider{
+			ID:   opts.ID,
+			Name: buildProviderName(providersByID[opts.ID], opts.Name),
+		})
+	}
+	return signInProviders
+}
+
+// selectProvider returns the provider with the given ID.
+// An empty ID selects the default provider, an unknown ID returns nil.
+func selectProvider(defaultProvider providers.Provider, providersByID map[string]providers.Provider, providerID string) providers.Provider {
+	if providerID == "" {
+		return defaultProvider
+	}
+	return providersByID[providerID]
+}
+
+// getProvider returns the provider with the given ID, or nil if no such
+// provider is configured. An empty ID selects the default provider.
+func (p *OAuthProxy) getProvider(providerID string) providers.Provider {
+	return selectProvider(p.provider, p.providers, providerID)
+}
+
 // buildRoutesAllowlist builds an []allowedRoute  list from either the legacy
 // SkipAuthRegex option (paths only support) or newer SkipAuthRoutes option
 // (method=path support)
@@ -572,8 +799,9 @@ func (p *OAuthProxy) isAllowedRoute(req *http.Request) bool {
 	return false
 }
 
-func (p *OAuthProxy) isAPIPath(req *http.Request) bool {
-	for _, route := range p.apiRoutes {
+// isAPIPath checks if the request path matches one of the API routes
+func isAPIPath(apiRoutes []apiRoute, req *http.Request) bool {
+	for _, route := range apiRoutes {
 		if route.pathRegex.MatchString(req.URL.Path) {
 			return true
 		}
@@ -642,6 +870,7 @@ func (p *OAuthProxy) ManualSignIn(req *http.Request) (string, bool, int) {
 		return user, true, http.StatusOK
 	}
 	logger.PrintAuthf(user, req, logger.AuthFailure, "Invalid authentication via HtpasswdFile")
+	audit.LogUser(req, audit.BasicAuthFailure, user, "", audit.InvalidCredentials)
 	return "", false, http.StatusUnauthorized
 }
 
@@ -660,9 +889,11 @@ func (p *OAuthProxy) SignIn(rw http.ResponseWriter, req *http.Request) {
 		err = p.SaveSession(rw, req, session)
 		if err != nil {
 			logger.Printf("Error saving session: %v", err)
+			audit.Log(req, audit.LoginFailure, session, audit.SessionSaveFailed)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 			return
 		}
+		audit.Log(req, audit.Login, session, "")
 		http.Redirect(rw, req, redirect, http.StatusFound)
 	} else {
 		if p.SkipProviderButton {
@@ -693,15 +924,17 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	userInfo := struct {
-		User              string   `json:"user"`
-		Email             string   `json:"email"`
-		Groups            []string `json:"groups,omitempty"`
-		PreferredUsername string   `json:"preferredUsername,omitempty"`
+		User              string              `json:"user"`
+		Email             string              `json:"email"`
+		Groups            []string            `json:"groups,omitempty"`
+		PreferredUsername string              `json:"preferredUsername,omitempty"`
+		Claims            map[string][]string `json:"claims,omitempty"`
 	}{
 		User:              session.User,
 		Email:             session.Email,
 		Groups:            session.Groups,
 		PreferredUsername: session.PreferredUsername,
+		Claims:            session.Claims,
 	}
 
 	if err := json.NewEncoder(rw).Encode(userInfo); err != nil {
@@ -710,7 +943,19 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// SignOut sends a response to clear the authentication cookie
+// JWKS serves the key set that upstreams verify the JWTs signed by the proxy
+// with
+func (p *OAuthProxy) JWKS(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(p.jwtSigner.JWKS()); err != nil {
+		logger.Errorf("Error encoding JWKS: %v", err)
+	}
+}
+
+// SignOut sends a response to clear the authentication cookie.
+// When the provider supports RP-Initiated Logout, the user is then sent to the
+// provider to end their session there, before returning to the redirect.
 func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 	redirect, err := p.appDirector.GetRedirect(req)
 	if err != nil {
@@ -718,28 +963,165 @@ func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+
+	// Load the session before it is cleared so that its tokens can be revoked
+	// and the provider can be given the ID token as a hint
+	session, err := p.LoadCookiedSession(req)
+	if err != nil {
+		session = nil
+	}
+	logoutURL := p.getProviderLogoutURL(req, session, redirect)
+
 	err = p.ClearSessionCookie(rw, req)
 	if err != nil {
 		logger.Errorf("Error clearing session cookie: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	audit.Log(req, audit.Logout, session, "")
+	p.revokeSession(req, session)
+
+	if logoutURL != "" {
+		redirect = logoutURL
+	}
 	http.Redirect(rw, req, redirect, http.StatusFound)
 }
 
+// getProviderLogoutURL returns the URL to end the user's session with the
+// provider that authenticated them, returning to the redirect afterwards.
+// An empty string is returned if there is no session or the provider does not
+// support logout.
+func (p *OAuthProxy) getProviderLogoutURL(req *http.Request, session *sessionsapi.SessionState, redirect string) string {
+	if session == nil {
+		return ""
+	}
+
+	provider := p.getProvider(session.ProviderID)
+	if provider == nil {
+		return ""
+	}
+
+	postLogoutRedirect, err := url.Parse(redirect)
+	if err != nil {
+		logger.Errorf("Error parsing post logout redirect: %v", err)
+		return ""
+	}
+	return provider.GetLogoutURL(session, p.getAbsoluteURL(req, *postLogoutRedirect))
+}
+
+// revokeSession revokes the tokens of a cleared session with the provider
+// that issued them. Failures are logged but never prevent the session from
+// being cleared.
+func (p *OAuthProxy) revokeSession(req *http.Request, session *sessionsapi.SessionState) {
+	if session == nil {
+		return
+	}
+	provider := p.getProvider(session.ProviderID)
+	if provider == nil {
+		return
+	}
+
+	if err := provider.RevokeSession(req.Context(), session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthError, "Error revoking session tokens: %v", err)
+	}
+}
+
+// revokeOwnSession revokes the tokens of sessions loaded from the session
+// store. Sessions loaded from bearer tokens belong to the calling client, and
+// sessions without tokens, such as basic auth sessions, have nothing to revoke.
+func (p *OAuthProxy) revokeOwnSession(req *http.Request, session *sessionsapi.SessionState) {
+	if middlewareapi.GetRequestScope(req).BearerSession {
+		return
+	}
+	if session.AccessToken == "" && session.RefreshToken == "" {
+		return
+	}
+	p.revokeSession(req, session)
+}
+
+// BackChannelLogout receives OIDC Back-Channel Logout requests from the
+// providers and clears all sessions identified by the logout token.
+func (p *OAuthProxy) BackChannelLogout(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	store, ok := p.sessionStore.(sessionsapi.IndexedSessionStore)
+	if !ok {
+		http.Error(rw, "Back-channel logout requires a persistent session store", http.StatusNotImplemented)
+		return
+	}
+
+	rawLogoutToken := req.PostFormValue("logout_token")
+	if rawLogoutToken == "" {
+		http.Error(rw, "Missing logout_token", http.StatusBadRequest)
+		return
+	}
+
+	logoutToken, err := p.verifyLogoutToken(req.Context(), rawLogoutToken)
+	if err != nil {
+		logger.Errorf("Error verifying logout token: %v", err)
+		http.Error(rw, "Invalid logout_token", http.StatusBadRequest)
+		return
+	}
+
+	// A session ID identifies the sessions more precisely than the subject
+	if logoutToken.SessionID != "" {
+		err = store.ClearBySessionID(req.Context(), logoutToken.Issuer, logoutToken.SessionID)
+	} else {
+		err = store.ClearBySubject(req.Context(), logoutToken.Issuer, logoutToken.Subject)
+	}
+	if err != nil {
+		logger.Errorf("Error clearing sessions for back-channel logout: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Printf("Back-channel logout from %s cleared sessions of subject %q, sid %q", logoutToken.Issuer, logoutToken.Subject, logoutToken.SessionID)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// verifyLogoutToken verifies the logout token with the ID token verifier of
+// each provider, returning the claims from the first provider that accepts it.
+func (p *OAuthProxy) verifyLogoutToken(ctx context.Context, rawLogoutToken string) (*internaloidc.LogoutToken, error) {
+	err := errors.New("no provider supports OIDC verification")
+	for _, provider := range p.providers {
+		verifier := provider.Data().Verifier
+		if verifier == nil {
+			continue
+		}
+
+		var logoutToken *internaloidc.LogoutToken
+		logoutToken, err = internaloidc.VerifyLogoutToken(ctx, verifier, rawLogoutToken)
+		if err == nil {
+			return logoutToken, nil
+		}
+	}
+	return nil, err
+}
+
 // OAuthStart starts the OAuth2 authentication flow
 func (p *OAuthProxy) OAuthStart(rw http.ResponseWriter, req *http.Request) {
 	// start the flow permitting login URL query parameters to be overridden from the request URL
-	p.doOAuthStart(rw, req, req.URL.Query())
+	p.doOAuthStart(rw, req, req.URL.Query().Get(providerQueryParam), req.URL.Query())
 }
 
-func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, overrides url.Values) {
-	extraParams := p.provider.Data().LoginURLParams(overrides)
+func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, providerID string, overrides url.Values) {
 	prepareNoCache(rw)
 
+	provider := p.getProvider(providerID)
+	if provider == nil {
+		logger.Errorf("Unknown provider %q requested", providerID)
+		p.ErrorPage(rw, req, http.StatusBadRequest, fmt.Sprintf("unknown provider %q", providerID))
+		return
+	}
+	extraParams := provider.Data().LoginURLParams(overrides)
+
 	var codeChallenge, codeVerifier, codeChallengeMethod string
-	if p.provider.Data().CodeChallengeMethod != "" {
-		codeChallengeMethod = p.provider.Data().CodeChallengeMethod
+	if provider.Data().CodeChallengeMethod != "" {
+		codeChallengeMethod = provider.Data().CodeChallengeMethod
 		preEncodedCodeVerifier, err := encryption.Nonce(96)
 		if err != nil {
 			logger.Errorf("Unable to build random string: %v", err)
@@ -748,7 +1130,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		}
 		codeVerifier = base64.RawURLEncoding.EncodeToString(preEncodedCodeVerifier)
 
-		codeChallenge, err = encryption.GenerateCodeChallenge(p.provider.Data().CodeChallengeMethod, codeVerifier)
+		codeChallenge, err = encryption.GenerateCodeChallenge(provider.Data().CodeChallengeMethod, codeVerifier)
 		if err != nil {
 			logger.Errorf("Error creating code challenge: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -759,7 +1141,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		extraParams.Add("code_challenge_method", codeChallengeMethod)
 	}
 
-	csrf, err := cookies.NewCSRF(p.CookieOptions, codeVerifier)
+	csrf, err := cookies.NewCSRF(p.CookieOptions, codeVerifier, providerID)
 	if err != nil {
 		logger.Errorf("Error creating CSRF nonce: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -774,9 +1156,9 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 	}
 
 	callbackRedirect := p.getOAuthRedirectURI(req)
-	loginURL := p.provider.GetLoginURL(
+	loginURL := provider.GetLoginURL(
 		callbackRedirect,
-		encodeState(csrf.HashOAuthState(), appRedirect),
+		encodeState(csrf.HashOAuthState(), providerID, appRedirect),
 		csrf.HashOIDCNonce(),
 		extraParams,
 	)
@@ -805,6 +1187,7 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	errorString := req.Form.Get("error")
 	if errorString != "" {
 		logger.Errorf("Error while parsing OAuth2 callback: %s", errorString)
+		audit.LogUser(req, audit.LoginFailure, "", "", audit.ProviderError)
 		message := fmt.Sprintf("Login Failed: The upstream identity provider returned an error: %s", errorString)
 		// Set the debug message and override the non debug message to be the same for this case
 		p.ErrorPage(rw, req, http.StatusForbidden, message, message)
@@ -814,42 +1197,64 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	csrf, err := cookies.LoadCSRFCookie(req, p.CookieOptions)
 	if err != nil {
 		logger.Println(req, logger.AuthFailure, "Invalid authentication via OAuth2: unable to obtain CSRF cookie")
+		audit.LogUser(req, audit.CSRFMismatch, "", "", audit.MissingCSRFCookie)
 		p.ErrorPage(rw, req, http.StatusForbidden, err.Error(), "Login Failed: Unable to find a valid CSRF token. Please try again.")
 		return
 	}
 
-	session, err := p.redeemCode(req, csrf.GetCodeVerifier())
+	nonce, providerID, appRedirect, err := decodeState(req)
 	if err != nil {
-		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
+		logger.Errorf("Error while parsing OAuth2 state: %v", err)
+		audit.LogUser(req, audit.LoginFailure, "", "", audit.InvalidState)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	err = p.enrichSessionState(req.Context(), session)
+	if !csrf.CheckProviderID(providerID) {
+		logger.PrintAuthf("", req, logger.AuthFailure, "Invalid authentication via OAuth2: provider %q in state does not match the CSRF cookie, potential attack", providerID)
+		audit.LogUser(req, audit.CSRFMismatch, "", providerID, audit.StateMismatch)
+		p.ErrorPage(rw, req, http.StatusForbidden, "OAuth2 state provider mismatch, potential attack", "Login Failed: Unable to find a valid CSRF token. Please try again.")
+		return
+	}
+
+	provider := p.getProvider(providerID)
+	if provider == nil {
+		logger.Errorf("Unknown provider %q in OAuth2 state", providerID)
+		audit.LogUser(req, audit.LoginFailure, "", providerID, audit.UnknownProvider)
+		p.ErrorPage(rw, req, http.StatusBadRequest, fmt.Sprintf("unknown provider %q", providerID))
+		return
+	}
+
+	session, err := p.redeemCode(req, provider, csrf.GetCodeVerifier())
 	if err != nil {
-		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
+		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
+		audit.LogUser(req, audit.LoginFailure, "", providerID, audit.CodeRedemptionFailed)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	session.ProviderID = providerID
 
-	csrf.ClearCookie(rw, req)
-
-	nonce, appRedirect, err := decodeState(req)
+	err = p.enrichSessionState(req.Context(), session)
 	if err != nil {
-		logger.Errorf("Error while parsing OAuth2 state: %v", err)
+		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
+		audit.Log(req, audit.LoginFailure, session, audit.SessionEnrichmentFailed)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	csrf.ClearCookie(rw, req)
+
 	if !csrf.CheckOAuthState(nonce) {
 		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: CSRF token mismatch, potential attack")
+		audit.Log(req, audit.CSRFMismatch, session, audit.StateMismatch)
 		p.ErrorPage(rw, req, http.StatusForbidden, "CSRF token mismatch, potential attack", "Login Failed: Unable to find a valid CSRF token. Please try again.")
 		return
 	}
 
 	csrf.SetSessionNonce(session)
-	if !p.provider.ValidateSession(req.Context(), session) {
+	if !provider.ValidateSession(req.Context(), session) {
 		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session validation failed: %s", session)
+		audit.Log(req, audit.LoginFailure, session, audit.SessionValidationFailed)
 		p.ErrorPage(rw, req, http.StatusForbidden, "Session validation failed")
 		return
 	}
@@ -859,33 +1264,41 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	// set cookie, or deny
-	authorized, err := p.provider.Authorize(req.Context(), session)
+	authorized, err := provider.Authorize(req.Context(), session)
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
 	}
-	if p.Validator(session.Email) && authorized {
+	validEmail := p.Validator(session.Email)
+	if validEmail && authorized {
 		logger.PrintAuthf(session.Email, req, logger.AuthSuccess, "Authenticated via OAuth2: %s", session)
 		err := p.SaveSession(rw, req, session)
 		if err != nil {
 			logger.Errorf("Error saving session state for %s: %v", remoteAddr, err)
+			audit.Log(req, audit.LoginFailure, session, audit.SessionSaveFailed)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 			return
 		}
+		audit.Log(req, audit.Login, session, "")
 		http.Redirect(rw, req, appRedirect, http.StatusFound)
 	} else {
 		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Invalid authentication via OAuth2: unauthorized")
+		audit.Log(req, audit.LoginFailure, session, unauthorizedReason(validEmail))
 		p.ErrorPage(rw, req, http.StatusForbidden, "Invalid session: unauthorized")
 	}
 }
 
-func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessionsapi.SessionState, error) {
+func (p *OAuthProxy) redeemCode(req *http.Request, provider providers.Provider, codeVerifier string) (*sessionsapi.SessionState, error) {
 	code := req.Form.Get("code")
 	if code == "" {
 		return nil, providers.ErrMissingCode
 	}
 
 	redirectURI := p.getOAuthRedirectURI(req)
-	s, err := p.provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
+	ctx, span := tracing.StartSpan(req.Context(), "Provider.Redeem",
+		trace.WithAttributes(tracing.ProviderKey.String(provider.Data().ProviderName)),
+	)
+	s, err := provider.Redeem(ctx, redirectURI, code, codeVerifier)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -902,17 +1315,22 @@ func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessio
 }
 
 func (p *OAuthProxy) enrichSessionState(ctx context.Context, s *sessionsapi.SessionState) error {
+	provider := p.getProvider(s.ProviderID)
+	if provider == nil {
+		return fmt.Errorf("unknown provider %q", s.ProviderID)
+	}
+
 	var err error
 	if s.Email == "" {
 		// TODO(@NickMeves): Remove once all provider are updated to implement EnrichSession
 		// nolint:staticcheck
-		s.Email, err = p.provider.GetEmailAddress(ctx, s)
+		s.Email, err = provider.GetEmailAddress(ctx, s)
 		if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
 			return err
 		}
 	}
 
-	return p.provider.EnrichSession(ctx, s)
+	return provider.EnrichSession(ctx, s)
 }
 
 // AuthOnly checks whether the user is currently logged in (both authentication
@@ -931,7 +1349,27 @@ func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := p.authorizeBearerScopes(req, session, options.Upstream{}); err != nil {
+		p.insufficientScope(rw, options.Upstream{})
+		return
+	}
+
+	authzHeaders, err := p.authorizeExternally(req, session, "")
+	switch err {
+	case nil:
+	case ErrAccessDenied:
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	default:
+		logger.Errorf("Unexpected internal error: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
 	// we are authenticated
+	for name, value := range authzHeaders {
+		rw.Header().Set(name, value)
+	}
 	p.addHeadersForProxying(rw, session)
 	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusAccepted)
@@ -941,15 +1379,33 @@ func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 // Proxy proxies the user request if the user is authenticated else it prompts
 // them to authenticate
 func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
+	matched, _ := p.upstreamProxy.MatchUpstream(req)
 	session, err := p.getAuthenticatedSession(rw, req)
+	var authzHeaders map[string]string
+	if err == nil {
+		err = p.authorizeBearerScopes(req, session, matched)
+	}
+	if err == nil {
+		authzHeaders, err = p.authorizeExternally(req, session, matched.ID)
+	}
 	switch err {
 	case nil:
 		// we are authenticated
+		for name, value := range authzHeaders {
+			req.Header.Set(name, value)
+		}
 		p.addHeadersForProxying(rw, session)
 		p.headersChain.Then(p.upstreamProxy).ServeHTTP(rw, req)
 	case ErrNeedsLogin:
+		// gRPC clients expect a gRPC status rather than a login screen
+		if upstream.IsGRPCRequest(req) {
+			logger.Printf("No valid authentication in gRPC call. Access Denied.")
+			upstream.WriteGRPCStatus(rw, upstream.GRPCUnauthenticated, "no valid authentication in request")
+			return
+		}
+
 		// we need to send the user to a login screen
-		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
+		if p.forceJSONErrors || isAjax(req) || isAPIPath(p.apiRoutes, req) || matched.API {
 			logger.Printf("No valid authentication in request. Access Denied.")
 			// no point redirecting an AJAX request
 			p.errorJSON(rw, http.StatusUnauthorized)
@@ -961,12 +1417,23 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 			// start OAuth flow, but only with the default login URL params - do not
 			// consider this request's query params as potential overrides, since
 			// the user did not explicitly start the login flow
-			p.doOAuthStart(rw, req, nil)
+			p.doOAuthStart(rw, req, "", nil)
 		} else {
 			p.SignInPage(rw, req, http.StatusForbidden)
 		}
 
+	case ErrInsufficientScope:
+		if upstream.IsGRPCRequest(req) {
+			upstream.WriteGRPCStatus(rw, upstream.GRPCPermissionDenied, "the bearer token is not granted the required scopes")
+			return
+		}
+		p.insufficientScope(rw, matched)
+
 	case ErrAccessDenied:
+		if upstream.IsGRPCRequest(req) {
+			upstream.WriteGRPCStatus(rw, upstream.GRPCPermissionDenied, "the session failed authorization checks")
+			return
+		}
 		if p.forceJSONErrors {
 			p.errorJSON(rw, http.StatusForbidden)
 		} else {
@@ -980,6 +1447,21 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// isUnauthenticatedUpstreamRequest checks if the request is served by an
+// upstream that allows the request without a valid session
+func (p *OAuthProxy) isUnauthenticatedUpstreamRequest(req *http.Request, _ *mux.RouteMatch) bool {
+	matched, ok := p.upstreamProxy.MatchUpstream(req)
+	return ok && upstream.AllowsUnauthenticated(matched, req.Method)
+}
+
+// proxyUnauthenticated proxies requests to upstreams that allow requests
+// without a valid session. Headers are still added from the session if the
+// request has one.
+func (p *OAuthProxy) proxyUnauthenticated(rw http.ResponseWriter, req *http.Request) {
+	p.addHeadersForProxying(rw, middlewareapi.GetRequestScope(req).Session)
+	p.headersChain.Then(p.upstreamProxy).ServeHTTP(rw, req)
+}
+
 // See https://developers.google.com/web/fundamentals/performance/optimizing-content-efficiency/http-caching?hl=en
 var noCacheHeaders = map[string]string{
 	"Expires":         time.Unix(0, 0).Format(time.RFC1123),
@@ -1006,13 +1488,18 @@ func prepareNoCacheMiddleware(next http.Handler) http.Handler {
 // redirect clients to once authenticated.
 // This is usually the OAuthProxy callback URL.
 func (p *OAuthProxy) getOAuthRedirectURI(req *http.Request) string {
-	// if `p.redirectURL` already has a host, return it
-	if p.redirectURL.Host != "" {
-		return p.redirectURL.String()
+	return p.getAbsoluteURL(req, *p.redirectURL)
+}
+
+// getAbsoluteURL returns the URL as is if it already has a host, otherwise
+// the scheme and host are taken from the request.
+func (p *OAuthProxy) getAbsoluteURL(req *http.Request, rd url.URL) string {
+	// if `rd` already has a host, return it
+	if rd.Host != "" {
+		return rd.String()
 	}
 
 	// Otherwise figure out the scheme + host from the request
-	rd := *p.redirectURL
 	rd.Host = requestutil.GetRequestHost(req)
 	rd.Scheme = requestutil.GetRequestProto(req)
 
@@ -1047,9 +1534,15 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 	}
 
 	invalidEmail := session.Email != "" && !p.Validator(session.Email)
-	authorized, err := p.provider.Authorize(req.Context(), session)
-	if err != nil {
-		logger.Errorf("Error with authorization: %v", err)
+	authorized := false
+	var authorizeErr error
+	if provider := p.getProvider(session.ProviderID); provider != nil {
+		authorized, authorizeErr = provider.Authorize(req.Context(), session)
+		if authorizeErr != nil {
+			logger.Errorf("Error with authorization: %v", authorizeErr)
+		}
+	} else {
+		logger.Errorf("Error with authorization: unknown provider %q", session.ProviderID)
 	}
 
 	if invalidEmail || !authorized {
@@ -1059,12 +1552,91 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 		if err != nil {
 			logger.Errorf("Error clearing session cookie: %v", err)
 		}
+		audit.Log(req, audit.SessionCleared, session, unauthorizedReason(!invalidEmail))
+		// Failing to authorize the session says nothing about its tokens
+		if invalidEmail || authorizeErr == nil {
+			p.revokeOwnSession(req, session)
+		}
 		return nil, ErrAccessDenied
 	}
 
 	return session, nil
 }
 
+// unauthorizedReason returns the audit reason for a session that is not
+// authorized, depending on whether its email is allowed.
+func unauthorizedReason(validEmail bool) audit.Reason {
+	if !validEmail {
+		return audit.UnauthorizedEmail
+	}
+	return audit.Unauthorized
+}
+
+// authorizeExternally asks the external authorization endpoint, if one is
+// configured, whether the session may access the request to the upstream.
+// Returns the headers to add to an allowed request, or ErrAccessDenied if the
+// request is denied. Requests allowed without a session are not checked.
+func (p *OAuthProxy) authorizeExternally(req *http.Request, session *sessionsapi.SessionState, upstreamID string) (map[string]string, error) {
+	if p.externalAuthorizer == nil || session == nil {
+		return nil, nil
+	}
+
+	path := req.URL.Path
+	if uri, err := url.ParseRequestURI(requestutil.GetRequestURI(req)); err == nil {
+		path = uri.Path
+	}
+
+	decision, err := p.externalAuthorizer.Authorize(req.Context(), external.Input{
+		Claims:   external.NewClaims(session),
+		Method:   req.Method,
+		Path:     path,
+		Host:     requestutil.GetRequestHost(req),
+		Upstream: upstreamID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with external authorization: %v", err)
+	}
+	if !decision.Allow {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Request denied by external authorization")
+		return nil, ErrAccessDenied
+	}
+	return decision.Headers, nil
+}
+
+// requiredBearerScopes returns the scopes bearer tokens must be granted for
+// requests to the upstream.
+func (p *OAuthProxy) requiredBearerScopes(matched options.Upstream) []string {
+	return append(append([]string{}, p.bearerScopes...), matched.RequiredScopes...)
+}
+
+// authorizeBearerScopes checks that sessions loaded from bearer tokens are
+// granted the scopes required globally and by the upstream.
+// Returns ErrInsufficientScope if any required scope is missing.
+func (p *OAuthProxy) authorizeBearerScopes(req *http.Request, session *sessionsapi.SessionState, matched options.Upstream) error {
+	if session == nil || !middlewareapi.GetRequestScope(req).BearerSession {
+		return nil
+	}
+
+	granted := make(map[string]struct{}, len(session.Scopes))
+	for _, scope := range session.Scopes {
+		granted[scope] = struct{}{}
+	}
+	for _, scope := range p.requiredBearerScopes(matched) {
+		if _, ok := granted[scope]; !ok {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Bearer token is missing the required scope %q", scope)
+			return ErrInsufficientScope
+		}
+	}
+	return nil
+}
+
+// insufficientScope responds to requests with bearer tokens that are not
+// granted the required scopes with an RFC 6750 insufficient_scope error.
+func (p *OAuthProxy) insufficientScope(rw http.ResponseWriter, matched options.Upstream) {
+	rw.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"insufficient_scope\", scope=%q", strings.Join(p.requiredBearerScopes(matched), " ")))
+	http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
 // authOnlyAuthorize handles special authorization logic that is only done
 // on the AuthOnly endpoint for use with Nginx subrequest architectures.
 func authOnlyAuthorize(req *http.Request, s *sessionsapi.SessionState) bool {
@@ -1167,20 +1739,24 @@ func checkAllowedEmails(req *http.Request, s *sessionsapi.SessionState) bool {
 	return allowed
 }
 
-// encodedState builds the OAuth state param out of our nonce and
-// original application redirect
-func encodeState(nonce string, redirect string) string {
-	return fmt.Sprintf("%v:%v", nonce, redirect)
+// encodedState builds the OAuth state param out of our nonce, the ID of the
+// chosen provider and original application redirect
+func encodeState(nonce string, providerID string, redirect string) string {
+	return fmt.Sprintf("%v:%v:%v", nonce, url.QueryEscape(providerID), redirect)
 }
 
 // decodeState splits the reflected OAuth state response back into
-// the nonce and original application redirect
-func decodeState(req *http.Request) (string, string, error) {
-	state := strings.SplitN(req.Form.Get("state"), ":", 2)
-	if len(state) != 2 {
-		return "", "", errors.New("invalid length")
+// the nonce, provider ID and original application redirect
+func decodeState(req *http.Request) (string, string, string, error) {
+	state := strings.SplitN(req.Form.Get("state"), ":", 3)
+	if len(state) != 3 {
+		return "", "", "", errors.New("invalid length")
+	}
+	providerID, err := url.QueryUnescape(state[1])
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid provider: %v", err)
 	}
-	return state[0], state[1], nil
+	return state[0], providerID, state[2], nil
 }
 
 // addHeadersForProxying adds the appropriate headers the request / response for proxying
@@ -1,33 +1,50 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt"
 	"github.com/mbland/hmacauth"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authorization/external"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	sessionstests "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 )
 
 const (
@@ -64,6 +81,7 @@ type TestProvider struct {
 	EmailAddress   string
 	ValidToken     bool
 	GroupValidator func(string) bool
+	AuthorizeErr   error
 }
 
 var _ providers.Provider = (*TestProvider)(nil)
@@ -104,6 +122,13 @@ func (tp *TestProvider) ValidateSession(_ context.Context, _ *sessions.SessionSt
 	return tp.ValidToken
 }
 
+func (tp *TestProvider) Authorize(ctx context.Context, s *sessions.SessionState) (bool, error) {
+	if tp.AuthorizeErr != nil {
+		return false, tp.AuthorizeErr
+	}
+	return tp.ProviderData.Authorize(ctx, s)
+}
+
 func Test_redeemCode(t *testing.T) {
 	opts := baseTestOptions()
 	err := validation.Validate(opts)
@@ -115,7 +140,7 @@ func Test_redeemCode(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	_, err = proxy.redeemCode(req, "")
+	_, err = proxy.redeemCode(req, proxy.provider, "")
 	assert.Equal(t, providers.ErrMissingCode, err)
 }
 
@@ -405,7 +430,7 @@ func (patTest *PassAccessTokenTest) Close() {
 func (patTest *PassAccessTokenTest) getCallbackEndpoint() (httpCode int, cookie string) {
 	rw := httptest.NewRecorder()
 
-	csrf, err := cookies.NewCSRF(patTest.proxy.CookieOptions, "")
+	csrf, err := cookies.NewCSRF(patTest.proxy.CookieOptions, "", "")
 	if err != nil {
 		panic(err)
 	}
@@ -414,7 +439,7 @@ func (patTest *PassAccessTokenTest) getCallbackEndpoint() (httpCode int, cookie
 		http.MethodGet,
 		fmt.Sprintf(
 			"/oauth2/callback?code=callback_code&state=%s",
-			encodeState(csrf.HashOAuthState(), "%2F"),
+			encodeState(csrf.HashOAuthState(), "", "%2F"),
 		),
 		strings.NewReader(""),
 	)
@@ -710,6 +735,32 @@ func TestManualSignInCorrectCredentials(t *testing.T) {
 	assert.Equal(t, http.StatusFound, statusCode)
 }
 
+func TestManualSignInAuditEvents(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	audit.SetOutput(buf)
+	defer audit.SetOutput(nil)
+
+	assert.Equal(t, http.StatusUnauthorized, ManualSignInWithCredentials(t, "admin", ""))
+	assert.Equal(t, http.StatusFound, ManualSignInWithCredentials(t, "admin", "adminPass"))
+
+	var events []audit.Record
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		record := audit.Record{}
+		require.NoError(t, decoder.Decode(&record))
+		events = append(events, record)
+	}
+	require.Len(t, events, 2)
+
+	assert.Equal(t, audit.BasicAuthFailure, events[0].Event)
+	assert.Equal(t, "admin", events[0].User)
+	assert.Equal(t, audit.InvalidCredentials, events[0].Reason)
+
+	assert.Equal(t, audit.Login, events[1].Event)
+	assert.Equal(t, "admin", events[1].User)
+	assert.Equal(t, audit.Reason(""), events[1].Reason)
+}
+
 func TestSignInPageIncludesTargetRedirect(t *testing.T) {
 	sipTest, err := NewSignInPageTest(false)
 	if err != nil {
@@ -796,6 +847,478 @@ func TestSignInPageSkipProviderDirect(t *testing.T) {
 	}
 }
 
+func TestOAuthStartWithMultipleProviders(t *testing.T) {
+	opts := baseTestOptions()
+	secondProvider := opts.Providers[0]
+	secondProvider.ID = "second"
+	secondProvider.Name = "Second Provider"
+	secondProvider.LoginURL = "https://second.example.com/oauth/authorize"
+	opts.Providers = append(opts.Providers, secondProvider)
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name               string
+		query              string
+		expectedCode       int
+		expectedLoginHost  string
+		expectedProviderID string
+	}{
+		{
+			name:               "Without a provider uses the default provider",
+			query:              "",
+			expectedCode:       http.StatusFound,
+			expectedLoginHost:  "accounts.google.com",
+			expectedProviderID: "",
+		},
+		{
+			name:               "With the second provider",
+			query:              "?provider=second",
+			expectedCode:       http.StatusFound,
+			expectedLoginHost:  "second.example.com",
+			expectedProviderID: "second",
+		},
+		{
+			name:         "With an unknown provider",
+			query:        "?provider=unknown",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/oauth2/start"+tc.query, nil)
+			proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedCode, rw.Code)
+			if tc.expectedCode != http.StatusFound {
+				return
+			}
+
+			loginURL, err := url.Parse(rw.Header().Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLoginHost, loginURL.Host)
+
+			state := strings.SplitN(loginURL.Query().Get("state"), ":", 3)
+			require.Len(t, state, 3)
+			assert.Equal(t, tc.expectedProviderID, state[1])
+		})
+	}
+}
+
+func TestOAuthCallbackRejectsProviderMismatch(t *testing.T) {
+	redeemed := false
+	redeemServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		redeemed = true
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer redeemServer.Close()
+
+	opts := baseTestOptions()
+	secondProvider := opts.Providers[0]
+	secondProvider.ID = "second"
+	secondProvider.Name = "Second Provider"
+	secondProvider.RedeemURL = redeemServer.URL
+	opts.Providers = append(opts.Providers, secondProvider)
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The flow was started with the default provider, but the state claims
+	// the second provider.
+	csrf, err := cookies.NewCSRF(proxy.CookieOptions, "", "")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/oauth2/callback?code=callback_code&state=%s",
+			encodeState(csrf.HashOAuthState(), "second", "%2F"),
+		),
+		nil,
+	)
+	csrfCookie, err := csrf.SetCookie(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+	req.AddCookie(csrfCookie)
+
+	rw := httptest.NewRecorder()
+	proxy.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.False(t, redeemed, "code should not be sent to the second provider")
+}
+
+func TestGetAuthenticatedSessionUsesSessionProvider(t *testing.T) {
+	opts := baseTestOptions()
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restrictedProvider := NewTestProvider(&url.URL{Host: "localhost"}, "")
+	restrictedProvider.AllowedGroups = map[string]struct{}{"admins": {}}
+	proxy.provider = NewTestProvider(&url.URL{Host: "localhost"}, "")
+	proxy.providers = map[string]providers.Provider{
+		"restricted": restrictedProvider,
+	}
+
+	testCases := []struct {
+		name        string
+		providerID  string
+		expectedErr error
+	}{
+		{
+			name:        "Session from the default provider",
+			providerID:  "",
+			expectedErr: nil,
+		},
+		{
+			name:        "Session from a provider that denies the session",
+			providerID:  "restricted",
+			expectedErr: ErrAccessDenied,
+		},
+		{
+			name:        "Session from an unknown provider",
+			providerID:  "unknown",
+			expectedErr: ErrAccessDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := &sessions.SessionState{
+				Email:      "michael.bland@gsa.gov",
+				Groups:     []string{"users"},
+				ProviderID: tc.providerID,
+			}
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+				Session: session,
+			})
+
+			_, err := proxy.getAuthenticatedSession(rw, req)
+			assert.Equal(t, tc.expectedErr, err)
+		})
+	}
+}
+
+func TestSignOutRedirectsToProviderLogout(t *testing.T) {
+	testCases := []struct {
+		name             string
+		logoutURL        *url.URL
+		session          *sessions.SessionState
+		expectedLocation string
+	}{
+		{
+			name:             "Without a session",
+			logoutURL:        &url.URL{Scheme: "https", Host: "idp.example.com", Path: "/logout"},
+			session:          nil,
+			expectedLocation: "/foo",
+		},
+		{
+			name:             "With a provider that does not support logout",
+			logoutURL:        nil,
+			session:          &sessions.SessionState{Email: "john.doe@example.com", IDToken: "id_token"},
+			expectedLocation: "/foo",
+		},
+		{
+			name:             "With a provider that supports logout",
+			logoutURL:        &url.URL{Scheme: "https", Host: "idp.example.com", Path: "/logout"},
+			session:          &sessions.SessionState{Email: "john.doe@example.com", IDToken: "id_token"},
+			expectedLocation: "https://idp.example.com/logout?id_token_hint=id_token&post_logout_redirect_uri=https%3A%2F%2Fexample.com%2Ffoo",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pcTest, err := NewProcessCookieTestWithDefaults()
+			require.NoError(t, err)
+
+			testProvider := NewTestProvider(&url.URL{Host: "localhost"}, "")
+			testProvider.LogoutURL = tc.logoutURL
+			pcTest.proxy.provider = testProvider
+
+			pcTest.req = httptest.NewRequest("GET", "http://example.com/oauth2/sign_out?rd=%2Ffoo", nil)
+			if tc.session != nil {
+				require.NoError(t, pcTest.SaveSession(tc.session))
+			}
+
+			rw := httptest.NewRecorder()
+			pcTest.proxy.ServeHTTP(rw, pcTest.req)
+
+			assert.Equal(t, http.StatusFound, rw.Code)
+			assert.Equal(t, tc.expectedLocation, rw.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRevokeSessionWhenCleared(t *testing.T) {
+	var revokedTokens []string
+	revokeStatus := http.StatusOK
+	revokeServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		revokedTokens = append(revokedTokens, req.PostFormValue("token"))
+		rw.WriteHeader(revokeStatus)
+	}))
+	defer revokeServer.Close()
+	revokeURL, err := url.Parse(revokeServer.URL)
+	require.NoError(t, err)
+
+	newProxy := func(t *testing.T) (*ProcessCookieTest, *TestProvider) {
+		pcTest, err := NewProcessCookieTestWithDefaults()
+		require.NoError(t, err)
+
+		testProvider := NewTestProvider(&url.URL{Host: "localhost"}, "")
+		testProvider.RevokeURL = revokeURL
+		pcTest.proxy.provider = testProvider
+		return pcTest, testProvider
+	}
+
+	t.Run("On sign out", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusOK
+		pcTest, _ := newProxy(t)
+
+		pcTest.req = httptest.NewRequest("GET", "http://example.com/oauth2/sign_out?rd=%2Ffoo", nil)
+		require.NoError(t, pcTest.SaveSession(&sessions.SessionState{Email: "john.doe@example.com", RefreshToken: "refresh_token"}))
+
+		rw := httptest.NewRecorder()
+		pcTest.proxy.ServeHTTP(rw, pcTest.req)
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Equal(t, []string{"refresh_token"}, revokedTokens)
+	})
+
+	t.Run("On sign out when revocation fails", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusServiceUnavailable
+		pcTest, _ := newProxy(t)
+
+		pcTest.req = httptest.NewRequest("GET", "http://example.com/oauth2/sign_out?rd=%2Ffoo", nil)
+		require.NoError(t, pcTest.SaveSession(&sessions.SessionState{Email: "john.doe@example.com", AccessToken: "access_token"}))
+
+		rw := httptest.NewRecorder()
+		pcTest.proxy.ServeHTTP(rw, pcTest.req)
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		assert.Equal(t, "/foo", rw.Header().Get("Location"))
+		assert.Equal(t, []string{"access_token"}, revokedTokens)
+	})
+
+	t.Run("When an unauthorized session is cleared", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusOK
+		pcTest, testProvider := newProxy(t)
+		testProvider.AllowedGroups = map[string]struct{}{"admins": {}}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			Session: &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "access_token"},
+		})
+		_, err := pcTest.proxy.getAuthenticatedSession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, ErrAccessDenied, err)
+		assert.Equal(t, []string{"access_token"}, revokedTokens)
+	})
+
+	t.Run("When an unauthorized bearer session is denied", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusOK
+		pcTest, testProvider := newProxy(t)
+		testProvider.AllowedGroups = map[string]struct{}{"admins": {}}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			Session:       &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "access_token"},
+			BearerSession: true,
+		})
+		_, err := pcTest.proxy.getAuthenticatedSession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, ErrAccessDenied, err)
+		assert.Empty(t, revokedTokens)
+	})
+
+	t.Run("When an unauthorized session without tokens is cleared", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusOK
+		pcTest, testProvider := newProxy(t)
+		testProvider.AllowedGroups = map[string]struct{}{"admins": {}}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			Session: &sessions.SessionState{User: "john.doe"},
+		})
+		_, err := pcTest.proxy.getAuthenticatedSession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, ErrAccessDenied, err)
+		assert.Empty(t, revokedTokens)
+	})
+
+	t.Run("When authorizing a session fails", func(t *testing.T) {
+		revokedTokens, revokeStatus = nil, http.StatusOK
+		pcTest, testProvider := newProxy(t)
+		testProvider.AuthorizeErr = errors.New("groups endpoint unavailable")
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			Session: &sessions.SessionState{Email: "john.doe@example.com", AccessToken: "access_token"},
+		})
+		_, err := pcTest.proxy.getAuthenticatedSession(httptest.NewRecorder(), req)
+
+		assert.Equal(t, ErrAccessDenied, err)
+		assert.Empty(t, revokedTokens)
+	})
+}
+
+func TestBackChannelLogout(t *testing.T) {
+	const issuer = "https://issuer.example.com"
+	unsignedJWT := func(claims map[string]interface{}) string {
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		return "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+	}
+	logoutEvents := map[string]interface{}{
+		"http://schemas.openid.net/event/backchannel-logout": map[string]interface{}{},
+	}
+
+	testCases := []struct {
+		name               string
+		method             string
+		persistentStore    bool
+		logoutTokenClaims  map[string]interface{}
+		expectedStatusCode int
+		expectSessionFound bool
+	}{
+		{
+			name:            "With a logout token for the session ID",
+			method:          http.MethodPost,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sid": "session", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusOK,
+			expectSessionFound: false,
+		},
+		{
+			name:            "With a logout token for the subject",
+			method:          http.MethodPost,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sub": "subject", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusOK,
+			expectSessionFound: false,
+		},
+		{
+			name:            "With a logout token for another session ID",
+			method:          http.MethodPost,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sub": "subject", "sid": "other", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusOK,
+			expectSessionFound: true,
+		},
+		{
+			name:            "With a logout token from another issuer",
+			method:          http.MethodPost,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": "https://other.example.com", "aud": clientID, "sid": "session", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectSessionFound: true,
+		},
+		{
+			name:            "With a logout token without the logout event",
+			method:          http.MethodPost,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sid": "session",
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectSessionFound: true,
+		},
+		{
+			name:               "Without a logout token",
+			method:             http.MethodPost,
+			persistentStore:    true,
+			expectedStatusCode: http.StatusBadRequest,
+			expectSessionFound: true,
+		},
+		{
+			name:            "With a GET request",
+			method:          http.MethodGet,
+			persistentStore: true,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sid": "session", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusMethodNotAllowed,
+			expectSessionFound: true,
+		},
+		{
+			name:            "With a cookie session store",
+			method:          http.MethodPost,
+			persistentStore: false,
+			logoutTokenClaims: map[string]interface{}{
+				"iss": issuer, "aud": clientID, "sid": "session", "events": logoutEvents,
+			},
+			expectedStatusCode: http.StatusNotImplemented,
+			expectSessionFound: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pcTest, err := NewProcessCookieTestWithDefaults()
+			require.NoError(t, err)
+			if tc.persistentStore {
+				pcTest.proxy.sessionStore = persistence.NewManager(sessionstests.NewMockStore(), &pcTest.opts.Cookie)
+			}
+
+			testProvider := NewTestProvider(&url.URL{Host: "localhost"}, "")
+			testProvider.Verifier = internaloidc.NewVerifier(
+				oidc.NewVerifier(issuer, NoOpKeySet{}, &oidc.Config{ClientID: clientID, SkipExpiryCheck: true}),
+				internaloidc.IDTokenVerificationOptions{AudienceClaims: []string{"aud"}, ClientID: clientID},
+			)
+			pcTest.proxy.provider = testProvider
+			pcTest.proxy.providers = map[string]providers.Provider{"providerID": testProvider}
+
+			require.NoError(t, pcTest.SaveSession(&sessions.SessionState{
+				Email:   "john.doe@example.com",
+				IDToken: unsignedJWT(map[string]interface{}{"iss": issuer, "sub": "subject", "sid": "session"}),
+			}))
+
+			form := url.Values{}
+			if tc.logoutTokenClaims != nil {
+				form.Set("logout_token", unsignedJWT(tc.logoutTokenClaims))
+			}
+			req := httptest.NewRequest(tc.method, "/oauth2/backchannel_logout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rw := httptest.NewRecorder()
+			pcTest.proxy.ServeHTTP(rw, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rw.Code)
+			session, err := pcTest.LoadCookiedSession()
+			if tc.expectSessionFound {
+				assert.NoError(t, err)
+				assert.NotNil(t, session)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
 type ProcessCookieTest struct {
 	opts         *options.Options
 	proxy        *OAuthProxy
@@ -1030,6 +1553,18 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 			},
 			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"groups\":[\"example\",\"groups\"],\"preferredUsername\":\"john\"}\n",
 		},
+		{
+			name: "With extra claims",
+			session: &sessions.SessionState{
+				User:  "john.doe",
+				Email: "john.doe@example.com",
+				Claims: map[string][]string{
+					"department": {"engineering"},
+				},
+				AccessToken: "my_access_token",
+			},
+			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"claims\":{\"department\":[\"engineering\"]}}\n",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1919,23 +2454,7 @@ func TestGetJwtSession(t *testing.T) {
 	assert.Equal(t, test.rw.Header().Get("X-Auth-Request-Email"), "john@example.com")
 }
 
-func Test_prepareNoCache(t *testing.T) {
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		prepareNoCache(w)
-	})
-	mux := http.NewServeMux()
-	mux.Handle("/", handler)
-
-	rec := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	mux.ServeHTTP(rec, req)
-
-	for k, v := range noCacheHeaders {
-		assert.Equal(t, rec.Header().Get(k), v)
-	}
-}
-
-func Test_noCacheHeaders(t *testing.T) {
+func TestBearerRequiredScopes(t *testing.T) {
 	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte("upstream"))
 		if err != nil {
@@ -1944,19 +2463,174 @@ func Test_noCacheHeaders(t *testing.T) {
 	}))
 	t.Cleanup(upstreamServer.Close)
 
-	opts := baseTestOptions()
-	opts.UpstreamServers = options.UpstreamConfig{
-		Upstreams: []options.Upstream{
-			{
-				ID:   upstreamServer.URL,
-				Path: "/",
-				URI:  upstreamServer.URL,
-			},
-		},
+	keyset := NoOpKeySet{}
+	verifier := oidc.NewVerifier("https://issuer.example.com", keyset,
+		&oidc.Config{ClientID: "https://test.myapp.com", SkipExpiryCheck: true,
+			SkipClientIDCheck: true})
+	verificationOptions := internaloidc.IDTokenVerificationOptions{
+		AudienceClaims: []string{"aud"},
+		ClientID:       "https://test.myapp.com",
+		ExtraAudiences: []string{},
 	}
-	opts.SkipAuthRegex = []string{".*"}
-	err := validation.Validate(opts)
-	assert.NoError(t, err)
+	internalVerifier := internaloidc.NewVerifier(verifier, verificationOptions)
+
+	newProxy := func(t *testing.T, providerVerifier bool) *OAuthProxy {
+		opts := baseTestOptions()
+		opts.UpstreamServers = options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:   "default",
+					Path: "/",
+					URI:  upstreamServer.URL,
+				},
+				{
+					ID:             "admin",
+					Path:           "/admin/",
+					URI:            upstreamServer.URL,
+					RequiredScopes: []string{"admin"},
+				},
+			},
+		}
+		opts.SkipJwtBearerTokens = true
+		opts.RequiredBearerScopes = []string{"read"}
+		if providerVerifier {
+			opts.Providers[0].Type = options.OIDCProvider
+			opts.Providers[0].LoginURL = "https://issuer.example.com/authorize"
+			opts.Providers[0].RedeemURL = "https://issuer.example.com/token"
+			opts.Providers[0].OIDCConfig.IssuerURL = "https://issuer.example.com"
+			opts.Providers[0].OIDCConfig.JwksURL = "https://issuer.example.com/keys"
+			opts.Providers[0].OIDCConfig.SkipDiscovery = true
+		} else {
+			opts.SetJWTBearerVerifiers(append(opts.GetJWTBearerVerifiers(), internalVerifier))
+		}
+		err := validation.Validate(opts)
+		assert.NoError(t, err)
+		proxy, err := NewOAuthProxy(opts, func(_ string) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if providerVerifier {
+			// The session loaders share the provider, so this replaces the
+			// verifier built from the JWKs URL
+			proxy.provider.Data().Verifier = internalVerifier
+		}
+		return proxy
+	}
+
+	testCases := []struct {
+		name                    string
+		path                    string
+		scope                   string
+		expectedCode            int
+		expectedWWWAuthenticate string
+	}{
+		{
+			name:         "with the globally required scope",
+			path:         "/",
+			scope:        "read write",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:                    "without the globally required scope",
+			path:                    "/",
+			scope:                   "write",
+			expectedCode:            http.StatusForbidden,
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", scope="read"`,
+		},
+		{
+			name:         "with the scopes required by the upstream",
+			path:         "/admin/users",
+			scope:        "read admin",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:                    "without the scopes required by the upstream",
+			path:                    "/admin/users",
+			scope:                   "read",
+			expectedCode:            http.StatusForbidden,
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", scope="read admin"`,
+		},
+		{
+			name:                    "without any scopes",
+			path:                    "/",
+			expectedCode:            http.StatusForbidden,
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", scope="read"`,
+		},
+	}
+
+	verifierCases := map[string]bool{
+		"with an extra JWT issuer":   false,
+		"with the provider verifier": true,
+	}
+
+	for verifierName, providerVerifier := range verifierCases {
+		proxy := newProxy(t, providerVerifier)
+
+		for _, tc := range testCases {
+			t.Run(verifierName+" "+tc.name, func(t *testing.T) {
+				claims, err := json.Marshal(map[string]interface{}{
+					"sub":   "1234567890",
+					"aud":   "https://test.myapp.com",
+					"email": "john@example.com",
+					"iss":   "https://issuer.example.com",
+					"iat":   1553691215,
+					"exp":   1912151821,
+					"scope": tc.scope,
+				})
+				assert.NoError(t, err)
+				token := "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." +
+					base64.RawURLEncoding.EncodeToString(claims) + ".c2lnbmF0dXJl"
+
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+				proxy.ServeHTTP(rw, req)
+
+				assert.Equal(t, tc.expectedCode, rw.Code)
+				assert.Equal(t, tc.expectedWWWAuthenticate, rw.Header().Get("WWW-Authenticate"))
+			})
+		}
+	}
+}
+
+func Test_prepareNoCache(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prepareNoCache(w)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	mux.ServeHTTP(rec, req)
+
+	for k, v := range noCacheHeaders {
+		assert.Equal(t, rec.Header().Get(k), v)
+	}
+}
+
+func Test_noCacheHeaders(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte("upstream"))
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(upstreamServer.Close)
+
+	opts := baseTestOptions()
+	opts.UpstreamServers = options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID:   upstreamServer.URL,
+				Path: "/",
+				URI:  upstreamServer.URL,
+			},
+		},
+	}
+	opts.SkipAuthRegex = []string{".*"}
+	err := validation.Validate(opts)
+	assert.NoError(t, err)
 	proxy, err := NewOAuthProxy(opts, func(_ string) bool { return true })
 	if err != nil {
 		t.Fatal(err)
@@ -3258,3 +3932,624 @@ func TestAuthOnlyAllowedEmails(t *testing.T) {
 		})
 	}
 }
+
+// newExternalAuthzServer creates an authorization endpoint that allows
+// requests to /allowed, adding an X-Role header, and records the inputs it
+// was asked to authorize
+func newExternalAuthzServer(t *testing.T) (*httptest.Server, *[]external.Input) {
+	inputs := &[]external.Input{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input external.Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*inputs = append(*inputs, body.Input)
+
+		if body.Input.Path != "/allowed" {
+			_, _ = w.Write([]byte(`{"result": false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": {"allow": true, "headers": {"X-Role": "admin"}}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, inputs
+}
+
+func TestProxyExternalAuthorization(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedRole string
+	}{
+		{"Allowed", "/allowed", http.StatusOK, "admin"},
+		{"Denied", "/denied", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authzServer, inputs := newExternalAuthzServer(t)
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Upstream-Role", r.Header.Get("X-Role"))
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.ExternalAuthz.URL = authzServer.URL
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "app",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest("GET", "http://app.example.com"+tt.path, nil)
+			created := time.Now()
+			err = test.SaveSession(&sessions.SessionState{
+				Email:       "user@example.com",
+				Groups:      []string{"admins"},
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			})
+			assert.NoError(t, err)
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
+			assert.Equal(t, tt.expectedRole, test.rw.Header().Get("X-Upstream-Role"))
+			assert.Equal(t, []external.Input{
+				{
+					Claims: external.Claims{
+						Email:  "user@example.com",
+						Groups: []string{"admins"},
+					},
+					Method:   "GET",
+					Path:     tt.path,
+					Host:     "app.example.com",
+					Upstream: "app",
+				},
+			}, *inputs)
+		})
+	}
+}
+
+func TestAuthOnlyExternalAuthorization(t *testing.T) {
+	tests := []struct {
+		name         string
+		forwardedURI string
+		expectedCode int
+		expectedRole string
+	}{
+		{"Allowed", "/allowed?query", http.StatusAccepted, "admin"},
+		{"Denied", "/denied", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authzServer, inputs := newExternalAuthzServer(t)
+
+			test, err := NewAuthOnlyEndpointTest("", func(opts *options.Options) {
+				opts.ReverseProxy = true
+				opts.ExternalAuthz.URL = authzServer.URL
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req.Header.Set("X-Forwarded-Host", "app.example.com")
+			test.req.Header.Set("X-Forwarded-Uri", tt.forwardedURI)
+			created := time.Now()
+			err = test.SaveSession(&sessions.SessionState{
+				Email:       "user@example.com",
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			})
+			assert.NoError(t, err)
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
+			assert.Equal(t, tt.expectedRole, test.rw.Header().Get("X-Role"))
+			if assert.Len(t, *inputs, 1) {
+				assert.Equal(t, "app.example.com", (*inputs)[0].Host)
+				assert.Equal(t, strings.Split(tt.forwardedURI, "?")[0], (*inputs)[0].Path)
+				assert.Equal(t, "", (*inputs)[0].Upstream)
+			}
+		})
+	}
+}
+
+func TestProxyUpstreamAuthorization(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedJSON bool
+	}{
+		{"Without rules", "/", http.StatusOK, false},
+		{"Allowed", "/ops/", http.StatusOK, false},
+		{"Denied", "/admin/", http.StatusForbidden, false},
+		{"Denied API route", "/api/admin", http.StatusForbidden, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.APIRoutes = []string{"^/api/"}
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "app",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+						{
+							ID:   "ops",
+							Path: "/ops/",
+							URI:  upstreamServer.URL,
+							Authorization: &options.UpstreamAuthorization{
+								Groups: []string{"ops"},
+							},
+						},
+						{
+							ID:   "admin",
+							Path: "/admin/",
+							URI:  upstreamServer.URL,
+							Authorization: &options.UpstreamAuthorization{
+								Groups: []string{"admins"},
+							},
+						},
+						{
+							ID:   "api-admin",
+							Path: "/api/admin",
+							URI:  upstreamServer.URL,
+							Authorization: &options.UpstreamAuthorization{
+								Groups: []string{"admins"},
+							},
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, _ = http.NewRequest("GET", tt.path, nil)
+			created := time.Now()
+			require.NoError(t, test.SaveSession(&sessions.SessionState{
+				Email:       "user@example.com",
+				Groups:      []string{"ops"},
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			}))
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
+			if tt.expectedJSON {
+				assert.Equal(t, applicationJSON, test.rw.Header().Get("Content-Type"))
+				assert.Equal(t, "{}", test.rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyUnauthenticatedUpstreams(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		withSession  bool
+		expectedCode int
+		expectedUser string
+		expectedJSON bool
+	}{
+		{"Public upstream", "GET", "/public/", false, http.StatusOK, "", false},
+		{"Public upstream with a session", "GET", "/public/", true, http.StatusOK, "user@example.com", false},
+		{"Public method", "GET", "/docs/", false, http.StatusOK, "", false},
+		{"Method that is not public", "POST", "/docs/", false, http.StatusForbidden, "", false},
+		{"Private upstream", "GET", "/", false, http.StatusForbidden, "", false},
+		{"API upstream", "GET", "/api/", false, http.StatusUnauthorized, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				_, _ = w.Write([]byte(r.Header.Get("X-Forwarded-Email")))
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.InjectRequestHeaders = []options.Header{
+					{
+						Name: "X-Forwarded-Email",
+						Values: []options.HeaderValue{
+							{ClaimSource: &options.ClaimSource{Claim: "email"}},
+						},
+					},
+				}
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "app",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+						{
+							ID:                   "public",
+							Path:                 "/public/",
+							URI:                  upstreamServer.URL,
+							AllowUnauthenticated: true,
+						},
+						{
+							ID:                   "docs",
+							Path:                 "/docs/",
+							URI:                  upstreamServer.URL,
+							AllowUnauthenticated: true,
+							AllowedMethods:       []string{"GET"},
+						},
+						{
+							ID:   "api",
+							Path: "/api/",
+							URI:  upstreamServer.URL,
+							API:  true,
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, _ = http.NewRequest(tt.method, tt.path, nil)
+			if tt.withSession {
+				created := time.Now()
+				require.NoError(t, test.SaveSession(&sessions.SessionState{
+					Email:       "user@example.com",
+					AccessToken: "oauth_token",
+					CreatedAt:   &created,
+				}))
+			}
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.expectedUser, test.rw.Body.String())
+			}
+			if tt.expectedJSON {
+				assert.Equal(t, applicationJSON, test.rw.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestProxyGRPCCalls(t *testing.T) {
+	tests := []struct {
+		name               string
+		contentType        string
+		withSession        bool
+		expectedCode       int
+		expectedGRPCStatus string
+	}{
+		{"Unauthenticated gRPC call", "application/grpc", false, http.StatusOK, "16"},
+		{"Authenticated gRPC call", "application/grpc", true, http.StatusOK, ""},
+		{"Unauthenticated request", "", false, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "grpc",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, _ = http.NewRequest("POST", "/service/Method", nil)
+			test.req.Header.Set("Content-Type", tt.contentType)
+			if tt.withSession {
+				created := time.Now()
+				require.NoError(t, test.SaveSession(&sessions.SessionState{
+					Email:       "user@example.com",
+					AccessToken: "oauth_token",
+					CreatedAt:   &created,
+				}))
+			}
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
+			assert.Equal(t, tt.expectedGRPCStatus, test.rw.Header().Get("Grpc-Status"))
+		})
+	}
+}
+
+func TestExchangeTokenWithProvider(t *testing.T) {
+	var receivedForms []url.Values
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		receivedForms = append(receivedForms, req.PostForm)
+		rw.Header().Set("Content-Type", "application/json")
+		_, err := rw.Write([]byte(`{"access_token":"exchanged-` + req.PostForm.Get("client_id") + `","expires_in":60}`))
+		require.NoError(t, err)
+	}))
+	defer tokenServer.Close()
+	tokenURL, err := url.Parse(tokenServer.URL)
+	require.NoError(t, err)
+
+	newProvider := func(clientID string) providers.Provider {
+		return &TestProvider{
+			ProviderData: &providers.ProviderData{
+				ClientID:     clientID,
+				ClientSecret: "secret",
+				RedeemURL:    tokenURL,
+			},
+		}
+	}
+	defaultProvider := newProvider("default")
+	providersByID := map[string]providers.Provider{
+		"default": defaultProvider,
+		"other":   newProvider("other"),
+	}
+
+	exchangeToken := exchangeTokenWithProvider(options.UpstreamConfig{
+		Upstreams: []options.Upstream{
+			{
+				ID: "api",
+				TokenExchange: &options.TokenExchange{
+					Audience: "api-audience",
+					Scopes:   []string{"read"},
+				},
+			},
+			{
+				ID: "plain",
+			},
+		},
+	}, defaultProvider, providersByID)
+	ctx := context.Background()
+
+	t.Run("with the default provider", func(t *testing.T) {
+		receivedForms = nil
+		token, err := exchangeToken(ctx, &sessions.SessionState{AccessToken: "access_token"}, "api")
+		require.NoError(t, err)
+		assert.Equal(t, "exchanged-default", token.AccessToken)
+		require.Len(t, receivedForms, 1)
+		assert.Equal(t, "api-audience", receivedForms[0].Get("audience"))
+		assert.Equal(t, "read", receivedForms[0].Get("scope"))
+		assert.Equal(t, "access_token", receivedForms[0].Get("subject_token"))
+	})
+
+	t.Run("with the provider of the session", func(t *testing.T) {
+		token, err := exchangeToken(ctx, &sessions.SessionState{AccessToken: "access_token", ProviderID: "other"}, "api")
+		require.NoError(t, err)
+		assert.Equal(t, "exchanged-other", token.AccessToken)
+	})
+
+	t.Run("with an unknown provider", func(t *testing.T) {
+		_, err := exchangeToken(ctx, &sessions.SessionState{AccessToken: "access_token", ProviderID: "unknown"}, "api")
+		assert.EqualError(t, err, "unknown provider \"unknown\"")
+	})
+
+	t.Run("with an upstream without a token exchange", func(t *testing.T) {
+		_, err := exchangeToken(ctx, &sessions.SessionState{AccessToken: "access_token"}, "plain")
+		assert.EqualError(t, err, "upstream \"plain\" has no token exchange")
+	})
+}
+
+func TestTokenExchangeOnlyReachesItsUpstream(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, err := rw.Write([]byte(`{"access_token":"exchanged","expires_in":60}`))
+		require.NoError(t, err)
+	}))
+	defer tokenServer.Close()
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, err := rw.Write([]byte(req.Header.Get("X-Api-Token")))
+		require.NoError(t, err)
+	}))
+	defer upstreamServer.Close()
+
+	for _, tc := range []struct {
+		path          string
+		expectedToken string
+	}{
+		{path: "/api/", expectedToken: "Bearer exchanged"},
+		{path: "/web/", expectedToken: ""},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.Providers[0].RedeemURL = tokenServer.URL
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "api",
+							Path: "/api/",
+							URI:  upstreamServer.URL,
+							TokenExchange: &options.TokenExchange{
+								Audience: "api",
+							},
+						},
+						{
+							ID:   "web",
+							Path: "/web/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+				opts.InjectRequestHeaders = []options.Header{
+					{
+						Name: "X-Api-Token",
+						Values: []options.HeaderValue{
+							{
+								TokenExchange: &options.TokenExchangeSource{
+									Upstream: "api",
+									Prefix:   "Bearer ",
+								},
+							},
+						},
+					},
+				}
+			})
+			require.NoError(t, err)
+
+			test.req, _ = http.NewRequest(http.MethodGet, tc.path, nil)
+			created := time.Now()
+			require.NoError(t, test.SaveSession(&sessions.SessionState{
+				Email:       "user@example.com",
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			}))
+
+			test.rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, http.StatusOK, test.rw.Code)
+			assert.Equal(t, tc.expectedToken, test.rw.Body.String())
+		})
+	}
+}
+
+func TestCreateProviderSessionFromIntrospection(t *testing.T) {
+	introspectServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		active := req.PostForm.Get("token") == "active"
+		_, err := rw.Write([]byte(fmt.Sprintf(`{"active":%t,"sub":"user","scope":"read"}`, active)))
+		require.NoError(t, err)
+	}))
+	defer introspectServer.Close()
+	introspectURL, err := url.Parse(introspectServer.URL)
+	require.NoError(t, err)
+
+	loader := createProviderSessionFromIntrospection("introspect", &TestProvider{
+		ProviderData: &providers.ProviderData{
+			ClientID:      "client",
+			ClientSecret:  "secret",
+			IntrospectURL: introspectURL,
+		},
+	})
+
+	t.Run("with an active token", func(t *testing.T) {
+		session, err := loader(context.Background(), "active")
+		require.NoError(t, err)
+		assert.Equal(t, "user", session.User)
+		assert.Equal(t, []string{"read"}, session.Scopes)
+		assert.Equal(t, "introspect", session.ProviderID)
+	})
+
+	t.Run("with an inactive token", func(t *testing.T) {
+		session, err := loader(context.Background(), "inactive")
+		assert.EqualError(t, err, "introspected token is not active")
+		assert.Nil(t, session)
+	})
+}
+
+func TestSignedJWTHeaderAndJWKSEndpoint(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "upstream-jwt-key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(r.Header.Get("X-Signed-JWT")))
+	}))
+	t.Cleanup(upstreamServer.Close)
+
+	test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+		opts.UpstreamJWTKeyFile = keyFile
+		opts.InjectRequestHeaders = []options.Header{
+			{
+				Name: "X-Signed-JWT",
+				Values: []options.HeaderValue{
+					{
+						SignedJWT: &options.SignedJWTSource{
+							Audience: "app",
+							Claims:   []string{"email"},
+						},
+					},
+				},
+			},
+		}
+		opts.UpstreamServers = options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:   "app",
+					Path: "/",
+					URI:  upstreamServer.URL,
+				},
+			},
+		}
+	})
+	require.NoError(t, err)
+
+	// The JWKS is served without a session
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth2/jwks.json", nil)
+	test.proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, applicationJSON, rw.Header().Get("Content-Type"))
+
+	var jwks jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "ES256", jwks.Keys[0].Algorithm)
+
+	test.req, _ = http.NewRequest("GET", "/", nil)
+	created := time.Now()
+	require.NoError(t, test.SaveSession(&sessions.SessionState{
+		User:        "user",
+		Email:       "user@example.com",
+		AccessToken: "oauth_token",
+		CreatedAt:   &created,
+	}))
+	test.rw = httptest.NewRecorder()
+	test.proxy.ServeHTTP(test.rw, test.req)
+	require.Equal(t, http.StatusOK, test.rw.Code)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(test.rw.Body.String(), claims, func(token *jwt.Token) (interface{}, error) {
+		keys := jwks.Key(token.Header["kid"].(string))
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("unknown key %q", token.Header["kid"])
+		}
+		return keys[0].Key, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user", claims["sub"])
+	assert.Equal(t, "app", claims["aud"])
+	assert.Equal(t, "user@example.com", claims["email"])
+}
@@ -39,6 +39,10 @@ type RequestScope struct {
 	// it was loaded or not.
 	SessionRevalidated bool
 
+	// BearerSession indicates whether the session was loaded from a bearer
+	// token in the Authorization header rather than the session store.
+	BearerSession bool
+
 	// Upstream tracks which upstream was used for this request
 	Upstream string
 }
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -20,11 +21,13 @@ type VerifyFunc func(ctx context.Context, token string) (*oidc.IDToken, error)
 func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
 		var claims struct {
-			Subject           string   `json:"sub"`
-			Email             string   `json:"email"`
-			Verified          *bool    `json:"email_verified"`
-			PreferredUsername string   `json:"preferred_username"`
-			Groups            []string `json:"groups"`
+			Subject           string      `json:"sub"`
+			Email             string      `json:"email"`
+			Verified          *bool       `json:"email_verified"`
+			PreferredUsername string      `json:"preferred_username"`
+			Groups            []string    `json:"groups"`
+			Scope             string      `json:"scope"`
+			Scp               interface{} `json:"scp"`
 		}
 
 		idToken, err := verify(ctx, token)
@@ -49,6 +52,7 @@ func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 			User:              claims.Subject,
 			Groups:            claims.Groups,
 			PreferredUsername: claims.PreferredUsername,
+			Scopes:            ParseScopes(claims.Scope, claims.Scp),
 			AccessToken:       token,
 			IDToken:           token,
 			RefreshToken:      "",
@@ -58,3 +62,24 @@ func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 		return newSession, nil
 	}
 }
+
+// ParseScopes returns the scopes granted to a token, from the space separated
+// `scope` claim (RFC 8693) or the `scp` claim used by some providers, which
+// may be a string or a list of strings.
+func ParseScopes(scope string, scp interface{}) []string {
+	scopes := strings.Fields(scope)
+	switch s := scp.(type) {
+	case string:
+		scopes = append(scopes, strings.Fields(s)...)
+	case []interface{}:
+		for _, v := range s {
+			if str, ok := v.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+	return scopes
+}
@@ -41,6 +41,14 @@ type AlphaOptions struct {
 	// To use the secure server you must configure a TLS certificate and key.
 	MetricsServer Server `json:"metricsServer,omitempty"`
 
+	// AdminServer is used to configure the HTTP(S) server for the admin API.
+	// The admin API lists and revokes the sessions in a persistent (redis, sql
+	// or bolt) session store, requests must be authenticated with the admin token.
+	// You may choose to run both HTTP and HTTPS servers simultaneously.
+	// This can be done by setting the BindAddress and the SecureBindAddress simultaneously.
+	// To use the secure server you must configure a TLS certificate and key.
+	AdminServer Server `json:"adminServer,omitempty"`
+
 	// Providers is used to configure multiple providers.
 	Providers Providers `json:"providers,omitempty"`
 }
@@ -53,6 +61,7 @@ func (a *AlphaOptions) MergeInto(opts *Options) {
 	opts.InjectResponseHeaders = a.InjectResponseHeaders
 	opts.Server = a.Server
 	opts.MetricsServer = a.MetricsServer
+	opts.AdminServer = a.AdminServer
 	opts.Providers = a.Providers
 }
 
@@ -64,5 +73,6 @@ func (a *AlphaOptions) ExtractFrom(opts *Options) {
 	a.InjectResponseHeaders = opts.InjectResponseHeaders
 	a.Server = opts.Server
 	a.MetricsServer = opts.MetricsServer
+	a.AdminServer = opts.AdminServer
 	a.Providers = opts.Providers
 }
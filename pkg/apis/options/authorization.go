@@ -0,0 +1,21 @@
+package options
+
+import "time"
+
+// ExternalAuthzOptions contains configuration for authorizing authenticated
+// requests with an external policy endpoint, such as an Open Policy Agent.
+type ExternalAuthzOptions struct {
+	URL      string        `flag:"authz-url" cfg:"authz_url"`
+	Timeout  time.Duration `flag:"authz-timeout" cfg:"authz_timeout"`
+	CacheTTL time.Duration `flag:"authz-cache-ttl" cfg:"authz_cache_ttl"`
+}
+
+// externalAuthzDefaults creates an ExternalAuthzOptions populating each field
+// with its default value
+func externalAuthzDefaults() ExternalAuthzOptions {
+	return ExternalAuthzOptions{
+		URL:      "",
+		Timeout:  5 * time.Second,
+		CacheTTL: 30 * time.Second,
+	}
+}
@@ -1,5 +1,10 @@
 package options
 
+import "time"
+
+// DefaultSignedJWTExpiry is the default lifetime of signed JWTs
+const DefaultSignedJWTExpiry = 5 * time.Minute
+
 // Header represents an individual header that will be added to a request or
 // response header.
 type Header struct {
@@ -25,6 +30,24 @@ type HeaderValue struct {
 
 	// Allow users to load the value from a session claim
 	*ClaimSource `json:",omitempty"`
+
+	// Allow users to load the value from an access token exchanged for an
+	// upstream
+	TokenExchange *TokenExchangeSource `json:"tokenExchange,omitempty"`
+
+	// Allow users to load the value from a JWT signed by the proxy
+	SignedJWT *SignedJWTSource `json:"signedJWT,omitempty"`
+
+	// Template allows users to build the value from a Go template.
+	// The template is evaluated against the session claims (eg.
+	// `{{.email}}`, `{{.groups}}` or any configured extra claim), the ID of
+	// the session's provider as `{{.provider}}` and the request as
+	// `{{.request.host}}`, `{{.request.path}}` and `{{.request.id}}`.
+	// The functions `join`, `dict` and `json` can be used to format values,
+	// eg. `{{.groups | join ","}}` or `{{json (dict "email" .email)}}`.
+	// Values are only added for requests with a session, and are skipped if
+	// they are empty or reference a claim that is not in the session.
+	Template string `json:"template,omitempty"`
 }
 
 // ClaimSource allows loading a header value from a claim within the session
@@ -42,3 +65,44 @@ type ClaimSource struct {
 	// basicAuthPassword will be used as the password value.
 	BasicAuthPassword *SecretSource `json:"basicAuthPassword,omitempty"`
 }
+
+// TokenExchangeSource allows loading a header value from the access token
+// that the session's access token is exchanged for with the token exchange
+// of an upstream
+type TokenExchangeSource struct {
+	// Upstream is the ID of the upstream whose token exchange should be used.
+	// The header is only injected into requests proxied to this upstream,
+	// and cannot be used in injectResponseHeaders.
+	Upstream string `json:"upstream,omitempty"`
+
+	// Prefix is an optional prefix that will be prepended to the exchanged
+	// token, eg. "Bearer ".
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// SignedJWTSource allows loading a header value from a short-lived JWT
+// containing claims of the session, signed with the key configured by
+// upstream_jwt_key_file. Upstreams can verify the JWT with the keys published
+// at the proxy's /oauth2/jwks.json endpoint.
+type SignedJWTSource struct {
+	// Issuer is the optional value of the JWT's iss claim.
+	Issuer string `json:"issuer,omitempty"`
+
+	// Audience is the optional value of the JWT's aud claim, eg. the URL of
+	// the upstream.
+	Audience string `json:"audience,omitempty"`
+
+	// Claims are the names of the session claims to include in the JWT.
+	// The session's user is always included as the sub claim.
+	// Claims with multiple values, and the groups claim, are included as
+	// arrays.
+	Claims []string `json:"claims,omitempty"`
+
+	// Expiry is the lifetime of the JWT.
+	// Defaults to 5 minutes.
+	Expiry *Duration `json:"expiry,omitempty"`
+
+	// Prefix is an optional prefix that will be prepended to the JWT,
+	// eg. "Bearer ".
+	Prefix string `json:"prefix,omitempty"`
+}
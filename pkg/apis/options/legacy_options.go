@@ -3,6 +3,7 @@ package options
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -56,6 +57,7 @@ func NewLegacyOptions() *LegacyOptions {
 			OIDCGroupsClaim:       "groups",
 			OIDCAudienceClaims:    []string{"aud"},
 			OIDCExtraAudiences:    []string{},
+			OIDCExtraClaims:       []string{},
 			InsecureOIDCSkipNonce: true,
 		},
 
@@ -80,10 +82,11 @@ func (l *LegacyOptions) ToOptions() (*Options, error) {
 		return nil, fmt.Errorf("error converting upstreams: %v", err)
 	}
 	l.Options.UpstreamServers = upstreams
+	l.convertRoutes()
 
 	l.Options.InjectRequestHeaders, l.Options.InjectResponseHeaders = l.LegacyHeaders.convert()
 
-	l.Options.Server, l.Options.MetricsServer = l.LegacyServer.convert()
+	l.Options.Server, l.Options.MetricsServer, l.Options.AdminServer = l.LegacyServer.convert()
 
 	l.Options.LegacyPreferEmailToUser = l.LegacyHeaders.PreferEmailToUser
 
@@ -182,6 +185,144 @@ func (l *LegacyUpstreams) convert() (UpstreamConfig, error) {
 	return upstreams, nil
 }
 
+// convertRoutes moves the skip auth and API routes that match exactly the
+// paths of upstreams onto those upstreams, so that they follow the upstreams
+// if their paths change. Routes that also match paths of other upstreams, or
+// only some paths of an upstream, cannot be converted and are left in place.
+func (l *LegacyOptions) convertRoutes() {
+	upstreams := l.Options.UpstreamServers.Upstreams
+
+	skipAuthRegex := l.Options.SkipAuthRegex[:0]
+	for _, path := range l.Options.SkipAuthRegex {
+		if !allowUnauthenticated(upstreams, "", path) {
+			skipAuthRegex = append(skipAuthRegex, path)
+		}
+	}
+	l.Options.SkipAuthRegex = skipAuthRegex
+
+	skipAuthRoutes := l.Options.SkipAuthRoutes[:0]
+	for _, route := range l.Options.SkipAuthRoutes {
+		// Negated routes match the paths of every other upstream
+		if strings.Contains(route, "!=") {
+			skipAuthRoutes = append(skipAuthRoutes, route)
+			continue
+		}
+
+		method, path := "", route
+		if parts := strings.SplitN(route, "=", 2); len(parts) == 2 {
+			method, path = strings.ToUpper(parts[0]), parts[1]
+		}
+		if !allowUnauthenticated(upstreams, method, path) {
+			skipAuthRoutes = append(skipAuthRoutes, route)
+		}
+	}
+	l.Options.SkipAuthRoutes = skipAuthRoutes
+
+	apiRoutes := l.Options.APIRoutes[:0]
+	for _, path := range l.Options.APIRoutes {
+		matched := upstreamsForRoute(upstreams, path)
+		for _, i := range matched {
+			upstreams[i].API = true
+		}
+		if len(matched) == 0 {
+			apiRoutes = append(apiRoutes, path)
+		}
+	}
+	l.Options.APIRoutes = apiRoutes
+}
+
+// allowUnauthenticated allows unauthenticated requests with the method, or
+// any method if empty, to the upstreams that the path regex matches.
+// Returns false if the regex does not match the paths of any upstreams.
+func allowUnauthenticated(upstreams []Upstream, method string, path string) bool {
+	matched := upstreamsForRoute(upstreams, path)
+	for _, i := range matched {
+		upstream := &upstreams[i]
+		switch {
+		case upstream.AllowUnauthenticated && len(upstream.AllowedMethods) == 0:
+			// All methods are already allowed
+		case method == "":
+			upstream.AllowUnauthenticated = true
+			upstream.AllowedMethods = nil
+		case !upstream.AllowUnauthenticated:
+			upstream.AllowUnauthenticated = true
+			upstream.AllowedMethods = []string{method}
+		case !containsString(upstream.AllowedMethods, method):
+			upstream.AllowedMethods = append(upstream.AllowedMethods, method)
+		}
+	}
+	return len(matched) > 0
+}
+
+// upstreamsForRoute returns the indexes of the upstreams that serve the paths
+// matched by the route regex, if the regex matches only paths of those
+// upstreams. Only anchored literal paths can be converted, eg. `^/path$` for
+// the upstream of the path `/path`, or `^/path/` and `^/path/.*` for the
+// upstream of the path `/path/` and any upstreams with paths below it.
+func upstreamsForRoute(upstreams []Upstream, route string) []int {
+	if !strings.HasPrefix(route, "^") {
+		return nil
+	}
+	path := strings.TrimPrefix(route, "^")
+
+	exact := false
+	switch {
+	case strings.HasSuffix(path, ".*$"):
+		path = strings.TrimSuffix(path, ".*$")
+	case strings.HasSuffix(path, ".*"):
+		path = strings.TrimSuffix(path, ".*")
+	case strings.HasSuffix(path, "$"):
+		path = strings.TrimSuffix(path, "$")
+		exact = true
+	}
+
+	compiled, err := regexp.Compile(path)
+	if err != nil {
+		return nil
+	}
+	path, complete := compiled.LiteralPrefix()
+	if !complete || path == "" {
+		return nil
+	}
+
+	// Without a trailing slash, the route would match paths such as
+	// `/pathname` that belong to other upstreams
+	if !exact && !strings.HasSuffix(path, "/") {
+		return nil
+	}
+
+	matched := []int{}
+	covered := false
+	for i, upstream := range upstreams {
+		if upstream.RewriteTarget != "" {
+			continue
+		}
+		switch {
+		case exact && upstream.Path == path && !strings.HasSuffix(path, "/"):
+			return []int{i}
+		case !exact && strings.HasPrefix(upstream.Path, path):
+			matched = append(matched, i)
+			covered = covered || upstream.Path == path
+		}
+	}
+
+	// The route also matches paths of other upstreams unless an upstream
+	// serves every path below it
+	if !covered {
+		return nil
+	}
+	return matched
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 type LegacyHeaders struct {
 	PassBasicAuth     bool `flag:"pass-basic-auth" cfg:"pass_basic_auth"`
 	PassAccessToken   bool `flag:"pass-access-token" cfg:"pass_access_token"`
@@ -451,12 +592,17 @@ type LegacyServer struct {
 	MetricsSecureAddress string   `flag:"metrics-secure-address" cfg:"metrics_secure_address"`
 	MetricsTLSCertFile   string   `flag:"metrics-tls-cert-file" cfg:"metrics_tls_cert_file"`
 	MetricsTLSKeyFile    string   `flag:"metrics-tls-key-file" cfg:"metrics_tls_key_file"`
+	AdminAddress         string   `flag:"admin-address" cfg:"admin_address"`
+	AdminSecureAddress   string   `flag:"admin-secure-address" cfg:"admin_secure_address"`
+	AdminTLSCertFile     string   `flag:"admin-tls-cert-file" cfg:"admin_tls_cert_file"`
+	AdminTLSKeyFile      string   `flag:"admin-tls-key-file" cfg:"admin_tls_key_file"`
 	HTTPAddress          string   `flag:"http-address" cfg:"http_address"`
 	HTTPSAddress         string   `flag:"https-address" cfg:"https_address"`
 	TLSCertFile          string   `flag:"tls-cert-file" cfg:"tls_cert_file"`
 	TLSKeyFile           string   `flag:"tls-key-file" cfg:"tls_key_file"`
 	TLSMinVersion        string   `flag:"tls-min-version" cfg:"tls_min_version"`
 	TLSCipherSuites      []string `flag:"tls-cipher-suite" cfg:"tls_cipher_suites"`
+	EnableH2C            bool     `flag:"enable-h2c" cfg:"enable_h2c"`
 }
 
 func legacyServerFlagset() *pflag.FlagSet {
@@ -466,12 +612,17 @@ func legacyServerFlagset() *pflag.FlagSet {
 	flagSet.String("metrics-secure-address", "", "the address /metrics will be served on for HTTPS clients (e.g. \":9100\")")
 	flagSet.String("metrics-tls-cert-file", "", "path to certificate file for secure metrics server")
 	flagSet.String("metrics-tls-key-file", "", "path to private key file for secure metrics server")
+	flagSet.String("admin-address", "", "the address the admin API will be served on (e.g. \":9200\")")
+	flagSet.String("admin-secure-address", "", "the address the admin API will be served on for HTTPS clients (e.g. \":9200\")")
+	flagSet.String("admin-tls-cert-file", "", "path to certificate file for secure admin server")
+	flagSet.String("admin-tls-key-file", "", "path to private key file for secure admin server")
 	flagSet.String("http-address", "127.0.0.1:4180", "[http://]<addr>:<port> or unix://<path> to listen on for HTTP clients")
 	flagSet.String("https-address", ":443", "<addr>:<port> to listen on for HTTPS clients")
 	flagSet.String("tls-cert-file", "", "path to certificate file")
 	flagSet.String("tls-key-file", "", "path to private key file")
 	flagSet.String("tls-min-version", "", "minimal TLS version for HTTPS clients (either \"TLS1.2\" or \"TLS1.3\")")
 	flagSet.StringSlice("tls-cipher-suite", []string{}, "restricts TLS cipher suites to those listed (e.g. TLS_RSA_WITH_RC4_128_SHA) (may be given multiple times)")
+	flagSet.Bool("enable-h2c", false, "enable HTTP/2 over cleartext (h2c) for HTTP clients")
 
 	return flagSet
 }
@@ -511,11 +662,14 @@ type LegacyProvider struct {
 	OIDCGroupsClaim                    string   `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
 	OIDCAudienceClaims                 []string `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
 	OIDCExtraAudiences                 []string `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
+	OIDCExtraClaims                    []string `flag:"oidc-extra-claim" cfg:"oidc_extra_claims"`
 	LoginURL                           string   `flag:"login-url" cfg:"login_url"`
 	RedeemURL                          string   `flag:"redeem-url" cfg:"redeem_url"`
 	ProfileURL                         string   `flag:"profile-url" cfg:"profile_url"`
 	ProtectedResource                  string   `flag:"resource" cfg:"resource"`
 	ValidateURL                        string   `flag:"validate-url" cfg:"validate_url"`
+	LogoutURL                          string   `flag:"logout-url" cfg:"logout_url"`
+	RevokeURL                          string   `flag:"revoke-url" cfg:"revoke_url"`
 	Scope                              string   `flag:"scope" cfg:"scope"`
 	Prompt                             string   `flag:"prompt" cfg:"prompt"`
 	ApprovalPrompt                     string   `flag:"approval-prompt" cfg:"approval_prompt"` // Deprecated by OIDC 1.0
@@ -523,6 +677,9 @@ type LegacyProvider struct {
 	AllowedGroups                      []string `flag:"allowed-group" cfg:"allowed_groups"`
 	AllowedRoles                       []string `flag:"allowed-role" cfg:"allowed_roles"`
 
+	IntrospectURL           string        `flag:"introspect-url" cfg:"introspect_url"`
+	IntrospectCacheDuration time.Duration `flag:"introspect-cache-duration" cfg:"introspect_cache_duration"`
+
 	AcrValues  string `flag:"acr-values" cfg:"acr_values"`
 	JWTKey     string `flag:"jwt-key" cfg:"jwt_key"`
 	JWTKeyFile string `flag:"jwt-key-file" cfg:"jwt_key_file"`
@@ -567,11 +724,16 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.String("oidc-email-claim", OIDCEmailClaim, "which OIDC claim contains the user's email")
 	flagSet.StringSlice("oidc-audience-claim", OIDCAudienceClaims, "which OIDC claims are used as audience to verify against client id")
 	flagSet.StringSlice("oidc-extra-audience", []string{}, "additional audiences allowed to pass audience verification")
+	flagSet.StringSlice("oidc-extra-claim", []string{}, "additional claims (or paths to nested claims, eg: address.country) to store in the session for header injection and the userinfo endpoint")
 	flagSet.String("login-url", "", "Authentication endpoint")
 	flagSet.String("redeem-url", "", "Token redemption endpoint")
 	flagSet.String("profile-url", "", "Profile access endpoint")
 	flagSet.String("resource", "", "The resource that is protected (Azure AD only)")
 	flagSet.String("validate-url", "", "Access token validation endpoint")
+	flagSet.String("logout-url", "", "End session endpoint users are redirected to on sign out")
+	flagSet.String("revoke-url", "", "Token revocation endpoint tokens are revoked at on sign out")
+	flagSet.String("introspect-url", "", "Token introspection endpoint opaque bearer tokens are verified at")
+	flagSet.Duration("introspect-cache-duration", DefaultIntrospectCacheDuration, "maximum duration token introspection results are cached for (0 to disable)")
 	flagSet.String("scope", "", "OAuth scope specification")
 	flagSet.String("prompt", "", "OIDC prompt")
 	flagSet.String("approval-prompt", "force", "OAuth approval_prompt")
@@ -590,10 +752,11 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	return flagSet
 }
 
-func (l LegacyServer) convert() (Server, Server) {
+func (l LegacyServer) convert() (Server, Server, Server) {
 	appServer := Server{
 		BindAddress:       l.HTTPAddress,
 		SecureBindAddress: l.HTTPSAddress,
+		H2C:               l.EnableH2C,
 	}
 	if l.TLSKeyFile != "" || l.TLSCertFile != "" {
 		appServer.TLS = &TLS{
@@ -631,7 +794,22 @@ func (l LegacyServer) convert() (Server, Server) {
 		}
 	}
 
-	return appServer, metricsServer
+	adminServer := Server{
+		BindAddress:       l.AdminAddress,
+		SecureBindAddress: l.AdminSecureAddress,
+	}
+	if l.AdminTLSKeyFile != "" || l.AdminTLSCertFile != "" {
+		adminServer.TLS = &TLS{
+			Key: &SecretSource{
+				FromFile: l.AdminTLSKeyFile,
+			},
+			Cert: &SecretSource{
+				FromFile: l.AdminTLSCertFile,
+			},
+		}
+	}
+
+	return appServer, metricsServer, adminServer
 }
 
 func (l *LegacyProvider) convert() (Providers, error) {
@@ -648,6 +826,8 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		ProfileURL:          l.ProfileURL,
 		ProtectedResource:   l.ProtectedResource,
 		ValidateURL:         l.ValidateURL,
+		LogoutURL:           l.LogoutURL,
+		RevokeURL:           l.RevokeURL,
 		Scope:               l.Scope,
 		AllowedGroups:       l.AllowedGroups,
 		CodeChallengeMethod: l.CodeChallengeMethod,
@@ -666,6 +846,13 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		GroupsClaim:                    l.OIDCGroupsClaim,
 		AudienceClaims:                 l.OIDCAudienceClaims,
 		ExtraAudiences:                 l.OIDCExtraAudiences,
+		ExtraClaims:                    l.OIDCExtraClaims,
+	}
+
+	if l.IntrospectURL != "" {
+		introspectCacheDuration := Duration(l.IntrospectCacheDuration)
+		provider.IntrospectURL = l.IntrospectURL
+		provider.IntrospectCacheDuration = &introspectCacheDuration
 	}
 
 	// Support for legacy configuration option
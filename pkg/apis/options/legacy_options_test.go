@@ -123,6 +123,7 @@ var _ = Describe("Legacy Options", func() {
 			opts.Providers[0].OIDCConfig.InsecureSkipNonce = true
 			opts.Providers[0].OIDCConfig.AudienceClaims = []string{"aud"}
 			opts.Providers[0].OIDCConfig.ExtraAudiences = []string{}
+			opts.Providers[0].OIDCConfig.ExtraClaims = []string{}
 			opts.Providers[0].LoginURLParameters = []LoginURLParameter{
 				{Name: "approval_prompt", Default: []string{"force"}},
 			}
@@ -133,6 +134,105 @@ var _ = Describe("Legacy Options", func() {
 		})
 	})
 
+	Context("Legacy Routes", func() {
+		type convertRoutesTableInput struct {
+			skipAuthRegex          []string
+			skipAuthRoutes         []string
+			apiRoutes              []string
+			expectedUpstreams      []Upstream
+			expectedSkipAuthRegex  []string
+			expectedSkipAuthRoutes []string
+			expectedAPIRoutes      []string
+		}
+
+		upstreams := func() []Upstream {
+			return []Upstream{
+				{ID: "/", Path: "/"},
+				{ID: "/public/", Path: "/public/"},
+				{ID: "/public/static/", Path: "/public/static/"},
+				{ID: "/health", Path: "/health"},
+			}
+		}
+
+		DescribeTable("convertRoutes",
+			func(in *convertRoutesTableInput) {
+				legacyOpts := &LegacyOptions{}
+				legacyOpts.Options.UpstreamServers.Upstreams = upstreams()
+				legacyOpts.Options.SkipAuthRegex = in.skipAuthRegex
+				legacyOpts.Options.SkipAuthRoutes = in.skipAuthRoutes
+				legacyOpts.Options.APIRoutes = in.apiRoutes
+
+				legacyOpts.convertRoutes()
+				Expect(legacyOpts.Options.UpstreamServers.Upstreams).To(Equal(in.expectedUpstreams))
+				Expect(legacyOpts.Options.SkipAuthRegex).To(Equal(in.expectedSkipAuthRegex))
+				Expect(legacyOpts.Options.SkipAuthRoutes).To(Equal(in.expectedSkipAuthRoutes))
+				Expect(legacyOpts.Options.APIRoutes).To(Equal(in.expectedAPIRoutes))
+			},
+			Entry("with no routes", &convertRoutesTableInput{
+				expectedUpstreams: upstreams(),
+			}),
+			Entry("with skip auth regexes for upstream paths", &convertRoutesTableInput{
+				skipAuthRegex: []string{"^/public/", "^/health$"},
+				expectedUpstreams: []Upstream{
+					{ID: "/", Path: "/"},
+					{ID: "/public/", Path: "/public/", AllowUnauthenticated: true},
+					{ID: "/public/static/", Path: "/public/static/", AllowUnauthenticated: true},
+					{ID: "/health", Path: "/health", AllowUnauthenticated: true},
+				},
+				expectedSkipAuthRegex: []string{},
+			}),
+			Entry("with skip auth routes with methods", &convertRoutesTableInput{
+				skipAuthRoutes: []string{"GET=^/public/static/.*", "head=^/public/static/.*$", "GET=^/health$"},
+				expectedUpstreams: []Upstream{
+					{ID: "/", Path: "/"},
+					{ID: "/public/", Path: "/public/"},
+					{ID: "/public/static/", Path: "/public/static/", AllowUnauthenticated: true, AllowedMethods: []string{"GET", "HEAD"}},
+					{ID: "/health", Path: "/health", AllowUnauthenticated: true, AllowedMethods: []string{"GET"}},
+				},
+				expectedSkipAuthRoutes: []string{},
+			}),
+			Entry("with skip auth routes with and without methods", &convertRoutesTableInput{
+				skipAuthRoutes: []string{"GET=^/public/static/", "^/public/"},
+				expectedUpstreams: []Upstream{
+					{ID: "/", Path: "/"},
+					{ID: "/public/", Path: "/public/", AllowUnauthenticated: true},
+					{ID: "/public/static/", Path: "/public/static/", AllowUnauthenticated: true},
+					{ID: "/health", Path: "/health"},
+				},
+				expectedSkipAuthRoutes: []string{},
+			}),
+			Entry("with routes that do not match whole upstreams", &convertRoutesTableInput{
+				skipAuthRegex:          []string{"^/public/static/images/", "^/healthz$", "/public/", "^/public", "^/public/.*\\.png$"},
+				skipAuthRoutes:         []string{"GET!=^/public/", "GET=^/public/index.html$"},
+				apiRoutes:              []string{"^/api/"},
+				expectedUpstreams:      upstreams(),
+				expectedSkipAuthRegex:  []string{"^/public/static/images/", "^/healthz$", "/public/", "^/public", "^/public/.*\\.png$"},
+				expectedSkipAuthRoutes: []string{"GET!=^/public/", "GET=^/public/index.html$"},
+				expectedAPIRoutes:      []string{"^/api/"},
+			}),
+			Entry("with API routes for upstream paths", &convertRoutesTableInput{
+				apiRoutes: []string{"^/public/static/", "^/api/"},
+				expectedUpstreams: []Upstream{
+					{ID: "/", Path: "/"},
+					{ID: "/public/", Path: "/public/"},
+					{ID: "/public/static/", Path: "/public/static/", API: true},
+					{ID: "/health", Path: "/health"},
+				},
+				expectedAPIRoutes: []string{"^/api/"},
+			}),
+			Entry("with a route for every path", &convertRoutesTableInput{
+				skipAuthRoutes: []string{"OPTIONS=^/"},
+				expectedUpstreams: []Upstream{
+					{ID: "/", Path: "/", AllowUnauthenticated: true, AllowedMethods: []string{"OPTIONS"}},
+					{ID: "/public/", Path: "/public/", AllowUnauthenticated: true, AllowedMethods: []string{"OPTIONS"}},
+					{ID: "/public/static/", Path: "/public/static/", AllowUnauthenticated: true, AllowedMethods: []string{"OPTIONS"}},
+					{ID: "/health", Path: "/health", AllowUnauthenticated: true, AllowedMethods: []string{"OPTIONS"}},
+				},
+				expectedSkipAuthRoutes: []string{},
+			}),
+		)
+	})
+
 	Context("Legacy Upstreams", func() {
 		type convertUpstreamsTableInput struct {
 			upstreamStrings   []string
@@ -793,6 +893,7 @@ var _ = Describe("Legacy Options", func() {
 			legacyServer          LegacyServer
 			expectedAppServer     Server
 			expectedMetricsServer Server
+			expectedAdminServer   Server
 		}
 
 		const (
@@ -800,6 +901,8 @@ var _ = Describe("Legacy Options", func() {
 			insecureMetricsAddr = ":9090"
 			secureAddr          = ":443"
 			secureMetricsAddr   = ":9443"
+			insecureAdminAddr   = ":9200"
+			secureAdminAddr     = ":9243"
 			crtPath             = "tls.crt"
 			keyPath             = "tls.key"
 			minVersion          = "TLS1.3"
@@ -830,11 +933,12 @@ var _ = Describe("Legacy Options", func() {
 			},
 		}
 
-		DescribeTable("should convert to app and metrics servers",
+		DescribeTable("should convert to app, metrics and admin servers",
 			func(in legacyServersTableInput) {
-				appServer, metricsServer := in.legacyServer.convert()
+				appServer, metricsServer, adminServer := in.legacyServer.convert()
 				Expect(appServer).To(Equal(in.expectedAppServer))
 				Expect(metricsServer).To(Equal(in.expectedMetricsServer))
+				Expect(adminServer).To(Equal(in.expectedAdminServer))
 			},
 			Entry("with default options only starts app HTTP server", legacyServersTableInput{
 				legacyServer: LegacyServer{
@@ -916,6 +1020,37 @@ var _ = Describe("Legacy Options", func() {
 					TLS:               tlsConfig,
 				},
 			}),
+			Entry("with admin HTTP and HTTPS addresses", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:        insecureAddr,
+					HTTPSAddress:       secureAddr,
+					AdminAddress:       insecureAdminAddr,
+					AdminSecureAddress: secureAdminAddr,
+				},
+				expectedAppServer: Server{
+					BindAddress: insecureAddr,
+				},
+				expectedAdminServer: Server{
+					BindAddress:       insecureAdminAddr,
+					SecureBindAddress: secureAdminAddr,
+				},
+			}),
+			Entry("with admin HTTPS and tls cert/key", legacyServersTableInput{
+				legacyServer: LegacyServer{
+					HTTPAddress:        insecureAddr,
+					HTTPSAddress:       secureAddr,
+					AdminSecureAddress: secureAdminAddr,
+					AdminTLSKeyFile:    keyPath,
+					AdminTLSCertFile:   crtPath,
+				},
+				expectedAppServer: Server{
+					BindAddress: insecureAddr,
+				},
+				expectedAdminServer: Server{
+					SecureBindAddress: secureAdminAddr,
+					TLS:               tlsConfig,
+				},
+			}),
 		)
 	})
 
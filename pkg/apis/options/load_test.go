@@ -37,14 +37,15 @@ var _ = Describe("Load", func() {
 		},
 
 		LegacyProvider: LegacyProvider{
-			ProviderType:          "google",
-			AzureTenant:           "common",
-			ApprovalPrompt:        "force",
-			UserIDClaim:           "email",
-			OIDCEmailClaim:        "email",
-			OIDCGroupsClaim:       "groups",
-			OIDCAudienceClaims:    []string{"aud"},
-			InsecureOIDCSkipNonce: true,
+			ProviderType:            "google",
+			AzureTenant:             "common",
+			ApprovalPrompt:          "force",
+			UserIDClaim:             "email",
+			OIDCEmailClaim:          "email",
+			OIDCGroupsClaim:         "groups",
+			OIDCAudienceClaims:      []string{"aud"},
+			InsecureOIDCSkipNonce:   true,
+			IntrospectCacheDuration: DefaultIntrospectCacheDuration,
 		},
 
 		Options: Options{
@@ -57,6 +58,8 @@ var _ = Describe("Load", func() {
 			Templates:          templatesDefaults(),
 			SkipAuthPreflight:  false,
 			Logging:            loggingDefaults(),
+			ExternalAuthz:      externalAuthzDefaults(),
+			Tracing:            tracingDefaults(),
 		},
 	}
 
@@ -13,12 +13,14 @@ type Logging struct {
 	RequestFormat   string         `flag:"request-logging-format" cfg:"request_logging_format"`
 	StandardEnabled bool           `flag:"standard-logging" cfg:"standard_logging"`
 	StandardFormat  string         `flag:"standard-logging-format" cfg:"standard_logging_format"`
+	Format          string         `flag:"logging-format" cfg:"logging_format"`
 	ErrToInfo       bool           `flag:"errors-to-info-log" cfg:"errors_to_info_log"`
 	ExcludePaths    []string       `flag:"exclude-logging-path" cfg:"exclude_logging_paths"`
 	LocalTime       bool           `flag:"logging-local-time" cfg:"logging_local_time"`
 	SilencePing     bool           `flag:"silence-ping-logging" cfg:"silence_ping_logging"`
 	RequestIDHeader string         `flag:"request-id-header" cfg:"request_id_header"`
 	File            LogFileOptions `cfg:",squash"`
+	Audit           AuditOptions   `cfg:",squash"`
 }
 
 // LogFileOptions contains options for configuring logging to a file
@@ -30,6 +32,13 @@ type LogFileOptions struct {
 	Compress   bool   `flag:"logging-compress" cfg:"logging_compress"`
 }
 
+// AuditOptions contains options for configuring the audit log of session
+// lifecycle events, which is disabled unless a file or syslog address is set
+type AuditOptions struct {
+	Filename string `flag:"audit-logging-filename" cfg:"audit_logging_filename"`
+	Syslog   string `flag:"audit-logging-syslog" cfg:"audit_logging_syslog"`
+}
+
 func loggingFlagSet() *pflag.FlagSet {
 	flagSet := pflag.NewFlagSet("logging", pflag.ExitOnError)
 
@@ -39,6 +48,7 @@ func loggingFlagSet() *pflag.FlagSet {
 	flagSet.String("standard-logging-format", logger.DefaultStandardLoggingFormat, "Template for standard log lines")
 	flagSet.Bool("request-logging", true, "Log HTTP requests")
 	flagSet.String("request-logging-format", logger.DefaultRequestLoggingFormat, "Template for HTTP request log lines")
+	flagSet.String("logging-format", string(logger.TextFormat), "Format of all log lines: 'text' to use the logging format templates, or 'json' for JSON objects")
 	flagSet.Bool("errors-to-info-log", false, "Log errors to the standard logging channel instead of stderr")
 
 	flagSet.StringSlice("exclude-logging-path", []string{}, "Exclude logging requests to paths (eg: '/path1,/path2,/path3')")
@@ -52,6 +62,9 @@ func loggingFlagSet() *pflag.FlagSet {
 	flagSet.Int("logging-max-backups", 0, "Maximum number of old log files to retain; 0 to disable")
 	flagSet.Bool("logging-compress", false, "Should rotated log files be compressed using gzip")
 
+	flagSet.String("audit-logging-filename", "", "File to write audit events to, rotated as the log file")
+	flagSet.String("audit-logging-syslog", "", "Syslog daemon to send audit events to: 'local', or the address of the daemon (eg: 'udp://syslog.example.com:514')")
+
 	return flagSet
 }
 
@@ -68,6 +81,7 @@ func loggingDefaults() Logging {
 		RequestFormat:   logger.DefaultRequestLoggingFormat,
 		StandardEnabled: true,
 		StandardFormat:  logger.DefaultStandardLoggingFormat,
+		Format:          string(logger.TextFormat),
 		ErrToInfo:       false,
 		File: LogFileOptions{
 			Filename:   "",
@@ -76,5 +90,9 @@ func loggingDefaults() Logging {
 			MaxBackups: 0,
 			Compress:   false,
 		},
+		Audit: AuditOptions{
+			Filename: "",
+			Syslog:   "",
+		},
 	}
 }
@@ -1,11 +1,17 @@
 package options
 
+import "time"
+
 const (
 	// OIDCEmailClaim is the generic email claim used by the OIDC provider.
 	OIDCEmailClaim = "email"
 
 	// OIDCGroupsClaim is the generic groups claim used by the OIDC provider.
 	OIDCGroupsClaim = "groups"
+
+	// DefaultIntrospectCacheDuration is the default maximum duration token
+	// introspection results are cached for.
+	DefaultIntrospectCacheDuration = 5 * time.Minute
 )
 
 // OIDCAudienceClaims is the generic audience claim list used by the OIDC provider.
@@ -72,6 +78,24 @@ type Provider struct {
 	ProtectedResource string `json:"resource,omitempty"`
 	// ValidateURL is the access token validation endpoint
 	ValidateURL string `json:"validateURL,omitempty"`
+	// LogoutURL is the end session endpoint users are redirected to on sign out.
+	// When OIDC discovery is enabled, the discovered end_session_endpoint is used instead.
+	LogoutURL string `json:"logoutURL,omitempty"`
+	// RevokeURL is the token revocation endpoint (RFC 7009) the session's
+	// tokens are revoked at on sign out.
+	// When OIDC discovery is enabled, the discovered revocation_endpoint is used instead.
+	RevokeURL string `json:"revokeURL,omitempty"`
+	// IntrospectURL is the token introspection endpoint (RFC 7662) opaque bearer
+	// tokens are verified at, using the client credentials of the provider.
+	// JWTs from issuers with a verifier are not introspected.
+	// Requires SkipJwtBearerTokens.
+	IntrospectURL string `json:"introspectURL,omitempty"`
+	// IntrospectCacheDuration is the maximum duration introspection results are
+	// cached for. Results are never cached beyond the expiry of the token, and
+	// inactive tokens and tokens rejected with a 4xx status are cached for 10
+	// seconds.
+	// Defaults to 5 minutes, a duration of 0 disables caching.
+	IntrospectCacheDuration *Duration `json:"introspectCacheDuration,omitempty"`
 	// Scope is the OAuth scope specification
 	Scope string `json:"scope,omitempty"`
 	// AllowedGroups is a list of restrict logins to members of this group
@@ -225,6 +249,10 @@ type OIDCOptions struct {
 	// ExtraAudiences is a list of additional audiences that are allowed
 	// to pass verification in addition to the client id.
 	ExtraAudiences []string `json:"extraAudiences,omitempty"`
+	// ExtraClaims is a list of additional claims to store in the session,
+	// so that they can be injected into headers and are returned by the
+	// userinfo endpoint. Nested claims can be given as a path, eg: `address.country`
+	ExtraClaims []string `json:"extraClaims,omitempty"`
 }
 
 type LoginGovOptions struct {
@@ -13,6 +13,11 @@ type Server struct {
 	// TLS contains the information for loading the certificate and key for the
 	// secure traffic and further configuration for the TLS server.
 	TLS *TLS
+
+	// H2C enables HTTP/2 over cleartext on the BindAddress, for clients such as
+	// gRPC clients that use HTTP/2 without TLS.
+	// HTTP/2 is always negotiated on the SecureBindAddress.
+	H2C bool
 }
 
 // TLS contains the information for loading a TLS certificate and key
@@ -1,10 +1,15 @@
 package options
 
+import "time"
+
 // SessionOptions contains configuration options for the SessionStore providers.
 type SessionOptions struct {
-	Type   string             `flag:"session-store-type" cfg:"session_store_type"`
-	Cookie CookieStoreOptions `cfg:",squash"`
-	Redis  RedisStoreOptions  `cfg:",squash"`
+	Type   string              `flag:"session-store-type" cfg:"session_store_type"`
+	Cookie CookieStoreOptions  `cfg:",squash"`
+	Redis  RedisStoreOptions   `cfg:",squash"`
+	SQL    SQLStoreOptions     `cfg:",squash"`
+	Bolt   BoltStoreOptions    `cfg:",squash"`
+	Cache  SessionCacheOptions `cfg:",squash"`
 }
 
 // CookieSessionStoreType is used to indicate the CookieSessionStore should be
@@ -15,6 +20,14 @@ var CookieSessionStoreType = "cookie"
 // used for storing sessions.
 var RedisSessionStoreType = "redis"
 
+// SQLSessionStoreType is used to indicate the SQL SessionStore should be
+// used for storing sessions.
+var SQLSessionStoreType = "sql"
+
+// BoltSessionStoreType is used to indicate the bolt SessionStore should be
+// used for storing sessions.
+var BoltSessionStoreType = "bolt"
+
 // CookieStoreOptions contains configuration options for the CookieSessionStore.
 type CookieStoreOptions struct {
 	Minimal bool `flag:"session-cookie-minimal" cfg:"session_cookie_minimal"`
@@ -35,11 +48,36 @@ type RedisStoreOptions struct {
 	IdleTimeout            int      `flag:"redis-connection-idle-timeout" cfg:"redis_connection_idle_timeout"`
 }
 
+// SQLStoreOptions contains configuration options for the SQL SessionStore.
+type SQLStoreOptions struct {
+	Driver string `flag:"sql-driver" cfg:"sql_driver"`
+	DSN    string `flag:"sql-dsn" cfg:"sql_dsn"`
+}
+
+// BoltStoreOptions contains configuration options for the bolt SessionStore.
+type BoltStoreOptions struct {
+	Path string `flag:"bolt-path" cfg:"bolt_path"`
+}
+
+// SessionCacheOptions contains configuration options for the in-memory cache
+// in front of persistent session stores.
+type SessionCacheOptions struct {
+	Size              int           `flag:"session-cache-size" cfg:"session_cache_size"`
+	TTL               time.Duration `flag:"session-cache-ttl" cfg:"session_cache_ttl"`
+	RedisInvalidation bool          `flag:"session-cache-redis-invalidation" cfg:"session_cache_redis_invalidation"`
+}
+
 func sessionOptionsDefaults() SessionOptions {
 	return SessionOptions{
 		Type: CookieSessionStoreType,
 		Cookie: CookieStoreOptions{
 			Minimal: false,
 		},
+		SQL: SQLStoreOptions{
+			Driver: "postgres",
+		},
+		Cache: SessionCacheOptions{
+			TTL: 5 * time.Second,
+		},
 	}
 }
@@ -0,0 +1,19 @@
+package options
+
+// TracingOptions contains configuration for exporting OpenTelemetry traces of
+// requests to a collector over OTLP, which is disabled unless an endpoint is set.
+type TracingOptions struct {
+	OTLPEndpoint string  `flag:"tracing-otlp-endpoint" cfg:"tracing_otlp_endpoint"`
+	ServiceName  string  `flag:"tracing-service-name" cfg:"tracing_service_name"`
+	SampleRatio  float64 `flag:"tracing-sample-ratio" cfg:"tracing_sample_ratio"`
+}
+
+// tracingDefaults creates a TracingOptions populating each field with its
+// default value
+func tracingDefaults() TracingOptions {
+	return TracingOptions{
+		OTLPEndpoint: "",
+		ServiceName:  "oauth2-proxy",
+		SampleRatio:  1,
+	}
+}
@@ -8,6 +8,44 @@ const (
 
 	// DefaultUpstreamTimeout is the maximum duration a network dial to a upstream server for a response.
 	DefaultUpstreamTimeout = 30 * time.Second
+
+	// DefaultEjectionDuration is the default value for the LoadBalancer EjectionDuration.
+	DefaultEjectionDuration = 30 * time.Second
+
+	// DefaultHealthCheckInterval is the default value for the HealthCheck Interval.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultHealthCheckTimeout is the default value for the HealthCheck Timeout.
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	// DefaultRetryAttempts is the default value for the Retry Attempts.
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBackoff is the default value for the Retry Backoff.
+	DefaultRetryBackoff = 100 * time.Millisecond
+
+	// DefaultCircuitBreakerFailureThreshold is the default value for the
+	// CircuitBreaker FailureThreshold.
+	DefaultCircuitBreakerFailureThreshold = 5
+
+	// DefaultCircuitBreakerOpenDuration is the default value for the
+	// CircuitBreaker OpenDuration.
+	DefaultCircuitBreakerOpenDuration = 30 * time.Second
+)
+
+// Load balancing strategies for upstreams with multiple URIs.
+const (
+	// RoundRobinStrategy sends requests to each backend in turn.
+	RoundRobinStrategy = "roundRobin"
+
+	// LeastConnectionsStrategy sends requests to the backend with the fewest
+	// requests in flight.
+	LeastConnectionsStrategy = "leastConnections"
+
+	// UserHashStrategy sends the requests of a user to the same backend, using
+	// consistent hashing on the user of the session. Requests without a
+	// session are sent to each backend in turn.
+	UserHashStrategy = "userHash"
 )
 
 // UpstreamConfig is a collection of definitions for upstream servers.
@@ -29,7 +67,8 @@ type Upstream struct {
 	ID string `json:"id,omitempty"`
 
 	// Path is used to map requests to the upstream server.
-	// The closest match will take precedence and all Paths must be unique.
+	// The closest match will take precedence and all Paths must be unique
+	// for the Hosts of the upstream.
 	// Path can also take a pattern when used with RewriteTarget.
 	// Path segments can be captured and matched using regular experessions.
 	// Eg:
@@ -38,6 +77,15 @@ type Upstream struct {
 	// - `^/baz/(.*)$`: Match any path prefixed with `/baz` and capture the remaining path for use with RewriteTarget
 	Path string `json:"path,omitempty"`
 
+	// Hosts restricts the upstream to requests for one of the hosts, matched
+	// together with the Path. The port of the request is ignored.
+	// A host may be a wildcard matching any subdomain, eg. `*.apps.example.com`.
+	// Exact hosts take precedence over wildcards, and upstreams with hosts take
+	// precedence over upstreams without hosts.
+	// Paths must be unique per host.
+	// Defaults to any host.
+	Hosts []string `json:"hosts,omitempty"`
+
 	// RewriteTarget allows users to rewrite the request path before it is sent to
 	// the upstream server.
 	// Use the Path to capture segments for reuse within the rewrite target.
@@ -49,15 +97,33 @@ type Upstream struct {
 	// The URI of the upstream server. This may be an HTTP(S) server of a File
 	// based URL. It may include a path, in which case all requests will be served
 	// under that path.
+	// Servers using HTTP/2 over cleartext, such as gRPC servers, use the `h2c`
+	// or `grpc` schemes. Responses from these servers are streamed to the
+	// client, including trailers, and FlushInterval has no effect.
+	// Servers listening on a unix domain socket use the `unix` scheme with the
+	// absolute path of the socket. A path following the `.sock` file is
+	// prefixed to the path of upstream requests.
 	// Eg:
 	// - http://localhost:8080
 	// - https://service.localhost
 	// - https://service.localhost/path
 	// - file://host/path
+	// - grpc://localhost:9090
+	// - unix:///var/run/app.sock
+	// - unix:///var/run/app.sock/api
 	// If the URI's path is "/base" and the incoming request was for "/dir",
 	// the upstream request will be for "/base/dir".
 	URI string `json:"uri,omitempty"`
 
+	// URIs are the URIs of further HTTP(S) servers serving the same content as
+	// the server of URI. Requests are balanced between the servers of URI and
+	// URIs following the LoadBalancer strategy.
+	URIs []string `json:"uris,omitempty"`
+
+	// LoadBalancer configures how requests are balanced between the servers of
+	// URI and URIs, and how unhealthy servers are detected.
+	LoadBalancer *LoadBalancer `json:"loadBalancer,omitempty"`
+
 	// InsecureSkipTLSVerify will skip TLS verification of upstream HTTPS hosts.
 	// This option is insecure and will allow potential Man-In-The-Middle attacks
 	// betweem OAuth2 Proxy and the usptream server.
@@ -91,4 +157,167 @@ type Upstream struct {
 	// Timeout is the maximum duration the server will wait for a response from the upstream server.
 	// Defaults to 30 seconds.
 	Timeout *Duration `json:"timeout,omitempty"`
+
+	// TokenExchange exchanges the session's access token for an access token
+	// issued for this upstream, following RFC 8693.
+	// The exchanged token can be injected into a header with a tokenExchange
+	// header value referencing the ID of this upstream.
+	TokenExchange *TokenExchange `json:"tokenExchange,omitempty"`
+
+	// Retry retries requests with idempotent methods that fail to connect to
+	// the upstream server, or that receive one of the retryable status codes.
+	Retry *Retry `json:"retry,omitempty"`
+
+	// CircuitBreaker stops requests to the upstream server after consecutive
+	// failures, giving the server time to recover.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// AllowUnauthenticated allows requests to this upstream without a valid
+	// session. The session of the request is still loaded when present, so
+	// that headers can be injected from it.
+	// Defaults to false.
+	AllowUnauthenticated bool `json:"allowUnauthenticated,omitempty"`
+
+	// AllowedMethods restricts AllowUnauthenticated to requests with one of
+	// the HTTP methods, eg. `GET`. Requests with other methods require a valid
+	// session.
+	// Defaults to all methods.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// API marks this upstream as an API. Unauthenticated requests to an API
+	// receive a 401 response instead of being redirected to sign in.
+	// Defaults to false.
+	API bool `json:"api,omitempty"`
+
+	// Authorization restricts the sessions that may access this upstream,
+	// in addition to the global authorization rules.
+	// Requests with sessions that fail the rules receive a 403 response.
+	Authorization *UpstreamAuthorization `json:"authorization,omitempty"`
+
+	// RequiredScopes are the scopes that bearer tokens must be granted to
+	// access the upstream, in addition to the globally required bearer scopes.
+	// Requests failing the check receive a 403 response with an RFC 6750
+	// `insufficient_scope` error.
+	// Sessions that were not loaded from bearer tokens are not checked.
+	RequiredScopes []string `json:"requiredScopes,omitempty"`
+}
+
+// LoadBalancer configures the balancing of requests between the servers of an
+// upstream.
+type LoadBalancer struct {
+	// Strategy is the strategy used to choose the server of a request.
+	// One of `roundRobin`, `leastConnections` or `userHash`.
+	// Defaults to `roundRobin`.
+	Strategy string `json:"strategy,omitempty"`
+
+	// EjectionDuration is the period a server receives no requests for after
+	// a request to it failed to connect.
+	// Defaults to 30 seconds.
+	EjectionDuration *Duration `json:"ejectionDuration,omitempty"`
+
+	// HealthCheck enables active health checks of the servers.
+	// Servers failing the health check receive no requests until they pass.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+}
+
+// HealthCheck configures the active health checks of the servers of an
+// upstream. A server is healthy when it responds with a 2xx or 3xx status.
+type HealthCheck struct {
+	// Path is the path requested from each server, eg. `/healthz`.
+	Path string `json:"path,omitempty"`
+
+	// Interval is the period between health checks.
+	// Defaults to 10 seconds.
+	Interval *Duration `json:"interval,omitempty"`
+
+	// Timeout is the maximum duration of a health check.
+	// Defaults to 5 seconds.
+	Timeout *Duration `json:"timeout,omitempty"`
+}
+
+// Retry configures the retries of requests with idempotent methods, eg. `GET`,
+// `HEAD`, `OPTIONS`, `PUT` or `DELETE`.
+type Retry struct {
+	// Attempts is the maximum number of attempts of a request, including the
+	// first attempt.
+	// Defaults to 3.
+	Attempts int `json:"attempts,omitempty"`
+
+	// Backoff is the delay before the first retry. The delay doubles with each
+	// further retry.
+	// Defaults to 100 milliseconds.
+	Backoff *Duration `json:"backoff,omitempty"`
+
+	// StatusCodes are the response status codes that are retried, eg. `503`.
+	// Requests that fail to connect to the upstream server are always retried.
+	StatusCodes []int `json:"statusCodes,omitempty"`
+}
+
+// CircuitBreaker configures the circuit breaker of an upstream. Each server of
+// a load balanced upstream has its own circuit breaker.
+// Requests fail when they fail to connect to the server or receive a 5xx
+// response, after any retries.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failed requests that open
+	// the circuit breaker. While the circuit breaker is open, requests are not
+	// sent to the server.
+	// Defaults to 5.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// OpenDuration is the period the circuit breaker stays open for. A single
+	// request is then sent to the server, closing the circuit breaker if it
+	// succeeds or opening it again if it fails.
+	// Defaults to 30 seconds.
+	OpenDuration *Duration `json:"openDuration,omitempty"`
+
+	// StaticCode is the response code of requests while the circuit breaker is
+	// open. If not set, the proxy error page is rendered instead.
+	StaticCode *int `json:"staticCode,omitempty"`
+
+	// StaticBody is the body of the static response while the circuit breaker
+	// is open. This option can only be used with StaticCode set.
+	StaticBody string `json:"staticBody,omitempty"`
+}
+
+// UpstreamAuthorization is the set of rules that a session must satisfy to
+// access an upstream. A session must satisfy every rule that is set.
+type UpstreamAuthorization struct {
+	// Groups restricts access to sessions that are a member of at least one
+	// of the groups.
+	Groups []string `json:"groups,omitempty"`
+
+	// EmailDomains restricts access to sessions with an email address in one
+	// of the domains.
+	// Prefix a domain with a `.` to also allow its subdomains, eg.
+	// `.example.com`.
+	EmailDomains []string `json:"emailDomains,omitempty"`
+
+	// Emails restricts access to sessions with one of the email addresses.
+	Emails []string `json:"emails,omitempty"`
+
+	// Claims restricts access to sessions with claims matching each of the
+	// matchers.
+	Claims []ClaimMatcher `json:"claims,omitempty"`
+}
+
+// ClaimMatcher matches sessions where a claim has one of the values.
+type ClaimMatcher struct {
+	// Claim is the name of the session claim, eg. `preferred_username` or a
+	// claim configured with `oidcConfig.extraClaims`.
+	Claim string `json:"claim,omitempty"`
+
+	// Values are the values the claim is allowed to have.
+	Values []string `json:"values,omitempty"`
+}
+
+// TokenExchange configures the access token that is requested from the
+// provider's token endpoint in an RFC 8693 token exchange.
+type TokenExchange struct {
+	// Audience is the logical name of the upstream that the exchanged token
+	// should be issued for.
+	Audience string `json:"audience,omitempty"`
+
+	// Scopes are the scopes requested for the exchanged token.
+	// If not set, the provider decides the scopes of the token.
+	Scopes []string `json:"scopes,omitempty"`
 }
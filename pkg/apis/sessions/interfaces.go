@@ -14,6 +14,36 @@ type SessionStore interface {
 	Clear(rw http.ResponseWriter, req *http.Request) error
 }
 
+// IndexedSessionStore is a SessionStore that indexes sessions by their user
+// and the claims of their ID Token, allowing them to be listed and cleared
+// without a request from the user agent holding the session, e.g. for OIDC
+// Back-Channel Logout or by an administrator.
+type IndexedSessionStore interface {
+	SessionStore
+	// ClearBySubject clears all sessions of the subject from the issuer
+	ClearBySubject(ctx context.Context, issuer, subject string) error
+	// ClearBySessionID clears all sessions created in the issuer's session
+	// identified by the sid claim
+	ClearBySessionID(ctx context.Context, issuer, sessionID string) error
+	// ClearByUser clears all sessions of the user
+	ClearByUser(ctx context.Context, user string) error
+	// ClearByTicketID clears the session stored under the ticket ID
+	ClearByTicketID(ctx context.Context, ticketID string) error
+	// ListSessions lists all stored sessions. The listed sessions only
+	// describe the user and lifetime of the session, they never hold tokens.
+	ListSessions(ctx context.Context) ([]*StoredSession, error)
+}
+
+// StoredSession is a session listed from an IndexedSessionStore
+type StoredSession struct {
+	TicketID string
+	Session  *SessionState
+}
+
+// ErrInvalidTicketID is returned when clearing a session by a ticket ID that
+// doesn't have the format of a ticket ID
+var ErrInvalidTicketID = errors.New("invalid session ticket ID")
+
 var ErrLockNotObtained = errors.New("lock: not obtained")
 var ErrNotLocked = errors.New("tried to release not existing lock")
 
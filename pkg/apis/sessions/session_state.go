@@ -30,6 +30,18 @@ type SessionState struct {
 	Groups            []string `msgpack:"g,omitempty"`
 	PreferredUsername string   `msgpack:"pu,omitempty"`
 
+	// Claims holds the values of the extra claims configured for the
+	// provider, keyed by the claim name or path they were extracted from.
+	Claims map[string][]string `msgpack:"cl,omitempty"`
+
+	// Scopes are the scopes granted to the bearer token the session was
+	// loaded from, when known.
+	Scopes []string `msgpack:"sc,omitempty"`
+
+	// ProviderID is the ID of the provider that authenticated the session.
+	// An empty ProviderID refers to the default provider.
+	ProviderID string `msgpack:"pid,omitempty"`
+
 	// Internal helpers, not serialized
 	Clock clock.Clock `msgpack:"-"`
 	Lock  Lock        `msgpack:"-"`
@@ -121,6 +133,9 @@ func (s *SessionState) String() string {
 	if len(s.Groups) > 0 {
 		o += fmt.Sprintf(" groups:%v", s.Groups)
 	}
+	if s.ProviderID != "" {
+		o += fmt.Sprintf(" provider:%s", s.ProviderID)
+	}
 	return o + "}"
 }
 
@@ -150,7 +165,9 @@ func (s *SessionState) GetClaim(claim string) []string {
 	case "preferred_username":
 		return []string{s.PreferredUsername}
 	default:
-		return []string{}
+		values := make([]string, len(s.Claims[claim]))
+		copy(values, s.Claims[claim])
+		return values
 	}
 }
 
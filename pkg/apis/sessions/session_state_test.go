@@ -224,6 +224,20 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
 			Groups:            []string{"group-a", "group-b"},
 		},
+		"With claims": {
+			Email:             "username@example.com",
+			User:              "username",
+			PreferredUsername: "preferred.username",
+			AccessToken:       "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			IDToken:           "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			CreatedAt:         &created,
+			ExpiresOn:         &expires,
+			RefreshToken:      "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			Claims: map[string][]string{
+				"department":      {"engineering"},
+				"address.country": {"NO"},
+			},
+		},
 	}
 
 	for _, secretSize := range []int{16, 24, 32} {
@@ -268,6 +282,29 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 	}
 }
 
+func TestGetClaim(t *testing.T) {
+	s := &SessionState{
+		Email:  "username@example.com",
+		Groups: []string{"group-a", "group-b"},
+		Claims: map[string][]string{
+			"department": {"engineering"},
+			"email":      {"other@example.com"},
+		},
+	}
+
+	assert.Equal(t, []string{"username@example.com"}, s.GetClaim("email"))
+	assert.Equal(t, []string{"group-a", "group-b"}, s.GetClaim("groups"))
+	assert.Equal(t, []string{"engineering"}, s.GetClaim("department"))
+	assert.Equal(t, []string{}, s.GetClaim("unknown"))
+
+	// Claims are copied so that the session can't be changed through them
+	s.GetClaim("department")[0] = "changed"
+	assert.Equal(t, []string{"engineering"}, s.GetClaim("department"))
+
+	var nilSession *SessionState
+	assert.Equal(t, []string{}, nilSession.GetClaim("department"))
+}
+
 func compareSessionStates(t *testing.T, expected *SessionState, actual *SessionState) {
 	if expected.CreatedAt != nil {
 		assert.NotNil(t, actual.CreatedAt)
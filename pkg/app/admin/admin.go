@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	sessionsPath = "/sessions"
+	sessionPath  = "/sessions/{ticketID}"
+
+	userQueryParam = "user"
+)
+
+// HandlerOpts are the requirements for constructing the admin API handler.
+type HandlerOpts struct {
+	// SessionStore is the store the sessions are listed from and cleared in
+	SessionStore sessionsapi.IndexedSessionStore
+
+	// Token is the bearer token every request must be authenticated with
+	Token string
+}
+
+// sessionInfo is the JSON representation of a listed session
+type sessionInfo struct {
+	TicketID          string     `json:"ticketID"`
+	User              string     `json:"user,omitempty"`
+	Email             string     `json:"email,omitempty"`
+	Groups            []string   `json:"groups,omitempty"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	CreatedAt         *time.Time `json:"createdAt,omitempty"`
+	ExpiresOn         *time.Time `json:"expiresOn,omitempty"`
+	Provider          string     `json:"provider,omitempty"`
+}
+
+// NewHandler constructs the admin API handler. It lists the stored sessions
+// and clears them by user or ticket ID:
+// - GET /sessions lists all sessions, or only those of the `user` query parameter
+// - DELETE /sessions?user=<user> clears all sessions of the user
+// - DELETE /sessions/<ticketID> clears a single session
+func NewHandler(opts HandlerOpts) http.Handler {
+	h := &handler{
+		store: opts.SessionStore,
+		token: opts.Token,
+	}
+
+	r := mux.NewRouter()
+	r.Use(h.authenticate)
+	r.Path(sessionsPath).Methods(http.MethodGet).HandlerFunc(h.listSessions)
+	r.Path(sessionsPath).Methods(http.MethodDelete).HandlerFunc(h.clearUserSessions)
+	r.Path(sessionPath).Methods(http.MethodDelete).HandlerFunc(h.clearSession)
+	return r
+}
+
+// handler implements the admin API.
+type handler struct {
+	store sessionsapi.IndexedSessionStore
+	token string
+}
+
+// authenticate rejects requests that do not carry the admin token as a
+// bearer token.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// bearerToken extracts the token from an Authorization header using the
+// Bearer scheme. Any other scheme, or a token without a scheme, is rejected.
+func bearerToken(req *http.Request) (string, bool) {
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(parts[1]), true
+}
+
+// listSessions writes the stored sessions as JSON, ordered by creation time
+func (h *handler) listSessions(rw http.ResponseWriter, req *http.Request) {
+	storedSessions, err := h.store.ListSessions(req.Context())
+	if err != nil {
+		logger.Errorf("Error listing sessions: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	user := req.URL.Query().Get(userQueryParam)
+	infos := []sessionInfo{}
+	for _, stored := range storedSessions {
+		if user != "" && stored.Session.User != user {
+			continue
+		}
+		infos = append(infos, sessionInfo{
+			TicketID:          stored.TicketID,
+			User:              stored.Session.User,
+			Email:             stored.Session.Email,
+			Groups:            stored.Session.Groups,
+			PreferredUsername: stored.Session.PreferredUsername,
+			CreatedAt:         stored.Session.CreatedAt,
+			ExpiresOn:         stored.Session.ExpiresOn,
+			Provider:          stored.Session.ProviderID,
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].CreatedAt != nil && (infos[j].CreatedAt == nil || infos[i].CreatedAt.Before(*infos[j].CreatedAt))
+	})
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(rw).Encode(infos); err != nil {
+		logger.Errorf("Error encoding sessions: %v", err)
+	}
+}
+
+// clearUserSessions clears all sessions of the user given in the query
+func (h *handler) clearUserSessions(rw http.ResponseWriter, req *http.Request) {
+	user := req.URL.Query().Get(userQueryParam)
+	if user == "" {
+		http.Error(rw, "Missing user", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.ClearByUser(req.Context(), user); err != nil {
+		logger.Errorf("Error clearing sessions of user %q: %v", user, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	logger.Printf("Admin API cleared all sessions of user %q", user)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// clearSession clears the session of the ticket ID in the path
+func (h *handler) clearSession(rw http.ResponseWriter, req *http.Request) {
+	ticketID := mux.Vars(req)["ticketID"]
+
+	err := h.store.ClearByTicketID(req.Context(), ticketID)
+	if errors.Is(err, sessionsapi.ErrInvalidTicketID) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error clearing session %s: %v", ticketID, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	logger.Printf("Admin API cleared session %s", ticketID)
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admin API", func() {
+	const adminToken = "admin-token"
+
+	var store *persistence.Manager
+	var handler http.Handler
+	var firstCreated, secondCreated time.Time
+
+	saveSession := func(s *sessionsapi.SessionState) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		Expect(store.Save(httptest.NewRecorder(), req, s)).To(Succeed())
+	}
+
+	listSessions := func(query string) []sessionInfo {
+		req := httptest.NewRequest(http.MethodGet, "/sessions"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		infos := []sessionInfo{}
+		Expect(json.Unmarshal(rw.Body.Bytes(), &infos)).To(Succeed())
+		return infos
+	}
+
+	doRequest := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	BeforeEach(func() {
+		store = persistence.NewManager(tests.NewMockStore(), &options.Cookie{
+			Name:   "_oauth2_proxy",
+			Secret: "0123456789abcdefghijklmnopqrstuv",
+			Expire: time.Hour,
+		})
+		handler = NewHandler(HandlerOpts{
+			SessionStore: store,
+			Token:        adminToken,
+		})
+
+		firstCreated = time.Now().Add(-time.Minute).Truncate(time.Second)
+		secondCreated = time.Now().Truncate(time.Second)
+		expires := secondCreated.Add(time.Hour)
+
+		// Saved out of order to check the listing is sorted
+		saveSession(&sessionsapi.SessionState{
+			User:        "jane.doe",
+			Email:       "jane.doe@example.com",
+			AccessToken: "access-token",
+			CreatedAt:   &secondCreated,
+			ExpiresOn:   &expires,
+			ProviderID:  "second-provider",
+		})
+		saveSession(&sessionsapi.SessionState{
+			User:        "john.doe",
+			Email:       "john.doe@example.com",
+			Groups:      []string{"admins"},
+			AccessToken: "access-token",
+			CreatedAt:   &firstCreated,
+			ExpiresOn:   &expires,
+			ProviderID:  "first-provider",
+		})
+	})
+
+	Context("without a valid token", func() {
+		It("rejects requests without a token", func() {
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/sessions", nil))
+			Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rw.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		})
+
+		It("rejects requests with the wrong token", func() {
+			req := httptest.NewRequest(http.MethodDelete, "/sessions?user=john.doe", nil)
+			req.Header.Set("Authorization", "Bearer wrong-token")
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+			Expect(listSessions("")).To(HaveLen(2))
+		})
+
+		It("rejects the admin token without the Bearer scheme", func() {
+			req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+			req.Header.Set("Authorization", adminToken)
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rw.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		})
+
+		It("rejects the admin token with another scheme", func() {
+			req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+			req.Header.Set("Authorization", "Basic "+adminToken)
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rw.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		})
+	})
+
+	Context("listing sessions", func() {
+		It("lists all sessions ordered by creation", func() {
+			infos := listSessions("")
+			Expect(infos).To(HaveLen(2))
+
+			Expect(infos[0].TicketID).ToNot(BeEmpty())
+			Expect(infos[0].User).To(Equal("john.doe"))
+			Expect(infos[0].Email).To(Equal("john.doe@example.com"))
+			Expect(infos[0].Groups).To(ConsistOf("admins"))
+			Expect(infos[0].CreatedAt.Equal(firstCreated)).To(BeTrue())
+			Expect(infos[0].ExpiresOn).ToNot(BeNil())
+			Expect(infos[0].Provider).To(Equal("first-provider"))
+
+			Expect(infos[1].User).To(Equal("jane.doe"))
+			Expect(infos[1].CreatedAt.Equal(secondCreated)).To(BeTrue())
+			Expect(infos[1].Provider).To(Equal("second-provider"))
+		})
+
+		It("filters the sessions by user", func() {
+			infos := listSessions("?user=jane.doe")
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].User).To(Equal("jane.doe"))
+		})
+
+		It("never includes tokens", func() {
+			rw := doRequest(http.MethodGet, "/sessions")
+			Expect(rw.Body.String()).ToNot(ContainSubstring("access-token"))
+		})
+	})
+
+	Context("revoking sessions", func() {
+		It("revokes all sessions of a user", func() {
+			rw := doRequest(http.MethodDelete, "/sessions?user=john.doe")
+			Expect(rw.Code).To(Equal(http.StatusNoContent))
+
+			infos := listSessions("")
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].User).To(Equal("jane.doe"))
+		})
+
+		It("requires a user to revoke sessions by user", func() {
+			rw := doRequest(http.MethodDelete, "/sessions")
+			Expect(rw.Code).To(Equal(http.StatusBadRequest))
+			Expect(listSessions("")).To(HaveLen(2))
+		})
+
+		It("revokes a session by ticket ID", func() {
+			ticketID := listSessions("?user=jane.doe")[0].TicketID
+
+			rw := doRequest(http.MethodDelete, "/sessions/"+ticketID)
+			Expect(rw.Code).To(Equal(http.StatusNoContent))
+
+			infos := listSessions("")
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].User).To(Equal("john.doe"))
+		})
+
+		It("rejects invalid ticket IDs", func() {
+			rw := doRequest(http.MethodDelete, "/sessions/_oauth2_proxy-sessions")
+			Expect(rw.Code).To(Equal(http.StatusBadRequest))
+			Expect(listSessions("")).To(HaveLen(2))
+		})
+	})
+})
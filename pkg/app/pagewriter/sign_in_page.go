@@ -20,6 +20,15 @@ import (
 //go:embed default_logo.svg
 var defaultLogoData string
 
+// SignInProvider describes a provider a user may sign in with.
+type SignInProvider struct {
+	// ID is the provider ID passed to the OAuth start endpoint.
+	ID string
+
+	// Name is the name of the provider displayed on its login button.
+	Name string
+}
+
 // signInPageWriter is used to render sign-in pages.
 type signInPageWriter struct {
 	// Template is the sign-in page HTML template.
@@ -34,6 +43,9 @@ type signInPageWriter struct {
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	providerName string
 
+	// Providers lists the providers a user may choose from on the sign-in page.
+	providers []SignInProvider
+
 	// SignInMessage is the messge displayed above the login button.
 	signInMessage string
 
@@ -59,6 +71,7 @@ func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Req
 	/* #nosec G203 */
 	t := struct {
 		ProviderName  string
+		Providers     []SignInProvider
 		SignInMessage template.HTML
 		StatusCode    int
 		CustomLogin   bool
@@ -69,6 +82,7 @@ func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Req
 		LogoData      template.HTML
 	}{
 		ProviderName:  s.providerName,
+		Providers:     s.providers,
 		SignInMessage: template.HTML(s.signInMessage),
 		StatusCode:    statusCode,
 		CustomLogin:   s.displayLoginForm,
@@ -61,6 +61,23 @@ var _ = Describe("SignIn Page", func() {
 				Expect(string(body)).To(Equal("/prefix/ My Provider Sign In Here Custom Footer Text v0.0.0-test /redirect true Logo Data"))
 			})
 
+			It("Writes the providers to the template", func() {
+				tmpl, err := template.New("").Parse("{{range .Providers}}{{.ID}}={{.Name}} {{end}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.providers = []SignInProvider{
+					{ID: "first", Name: "First Provider"},
+					{ID: "second", Name: "Second Provider"},
+				}
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := ioutil.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("first=First Provider second=Second Provider "))
+			})
+
 			It("Writes an error if the template can't be rendered", func() {
 				// Overwrite the template with something bad
 				tmpl, err := template.New("").Parse("{{.Unknown}}")
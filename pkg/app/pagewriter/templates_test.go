@@ -44,6 +44,7 @@ var _ = Describe("Templates", func() {
 				// For default sign_in template
 				SignInMessage string
 				ProviderName  string
+				Providers     []SignInProvider
 				CustomLogin   bool
 				LogoData      string
 
@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// Event is the type of an audit event
+type Event string
+
+// Reason is a code for the reason of an audit event
+type Reason string
+
+const (
+	// Login is recorded when a user logs in and their session is saved
+	Login Event = "login"
+	// LoginFailure is recorded when a login fails
+	LoginFailure Event = "login_failure"
+	// Logout is recorded when a user signs out and their session is cleared
+	Logout Event = "logout"
+	// Refresh is recorded when the tokens of a session are refreshed
+	Refresh Event = "refresh"
+	// RefreshFailure is recorded when refreshing the tokens of a session fails
+	RefreshFailure Event = "refresh_failure"
+	// SessionCleared is recorded when a session is cleared because it is no
+	// longer authorized
+	SessionCleared Event = "session_cleared"
+	// CSRFMismatch is recorded when the CSRF token of a login does not match
+	CSRFMismatch Event = "csrf_mismatch"
+	// BasicAuthFailure is recorded when basic auth credentials are invalid
+	BasicAuthFailure Event = "basic_auth_failure"
+
+	// ProviderError means the identity provider returned an error
+	ProviderError Reason = "provider_error"
+	// InvalidState means the OAuth state could not be decoded
+	InvalidState Reason = "invalid_state"
+	// UnknownProvider means the OAuth state named an unknown provider
+	UnknownProvider Reason = "unknown_provider"
+	// CodeRedemptionFailed means the authorization code could not be redeemed
+	CodeRedemptionFailed Reason = "code_redemption_failed"
+	// SessionEnrichmentFailed means the session could not be enriched with
+	// details of the user
+	SessionEnrichmentFailed Reason = "session_enrichment_failed"
+	// SessionValidationFailed means the provider did not validate the session
+	SessionValidationFailed Reason = "session_validation_failed"
+	// SessionSaveFailed means the session could not be saved
+	SessionSaveFailed Reason = "session_save_failed"
+	// Unauthorized means the user is not authorized by the provider
+	Unauthorized Reason = "unauthorized"
+	// UnauthorizedEmail means the email of the user is not allowed
+	UnauthorizedEmail Reason = "unauthorized_email"
+	// MissingCSRFCookie means the CSRF cookie of a login was not found
+	MissingCSRFCookie Reason = "missing_csrf_cookie"
+	// StateMismatch means the state of a login does not match its CSRF cookie
+	StateMismatch Reason = "state_mismatch"
+	// InvalidCredentials means the basic auth credentials are not valid
+	InvalidCredentials Reason = "invalid_credentials"
+)
+
+// Record is an audit event as written to the audit log, as a JSON object per
+// line.
+type Record struct {
+	Timestamp string `json:"timestamp"`
+	Event     Event  `json:"event"`
+	User      string `json:"user"`
+	Email     string `json:"email"`
+	Provider  string `json:"provider"`
+	Client    string `json:"client"`
+	RequestID string `json:"request_id"`
+	Reason    Reason `json:"reason,omitempty"`
+}
+
+// GetClientFunc returns the apparent "real client IP" as a string.
+type GetClientFunc = func(r *http.Request) string
+
+// A Logger writes audit events to an io.Writer, such as a file or syslog.
+// Events are only written once an output is set.
+// A Logger can be used simultaneously from multiple goroutines; it guarantees
+// to serialize access to the Writer.
+type Logger struct {
+	mu            sync.Mutex
+	writer        io.Writer
+	getClientFunc GetClientFunc
+}
+
+// New creates a new Logger with no output.
+func New() *Logger {
+	return &Logger{
+		getClientFunc: func(r *http.Request) string {
+			return ip.GetClientString(nil, r, false)
+		},
+	}
+}
+
+var std = New()
+
+// Log writes the event for the request to the audit log, with the user, email
+// and provider of the session, which may be nil.
+func (l *Logger) Log(req *http.Request, event Event, session *sessionsapi.SessionState, reason Reason) {
+	if session == nil {
+		l.LogUser(req, event, "", "", reason)
+		return
+	}
+
+	l.write(req, Record{
+		Event:    event,
+		User:     session.User,
+		Email:    session.Email,
+		Provider: session.ProviderID,
+		Reason:   reason,
+	})
+}
+
+// LogUser writes the event for the request to the audit log, for a user
+// without a session.
+func (l *Logger) LogUser(req *http.Request, event Event, user, provider string, reason Reason) {
+	l.write(req, Record{
+		Event:    event,
+		User:     user,
+		Provider: provider,
+		Reason:   reason,
+	})
+}
+
+func (l *Logger) write(req *http.Request, record Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.writer == nil {
+		return
+	}
+
+	record.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	record.Client = l.getClientFunc(req)
+	if scope := middlewareapi.GetRequestScope(req); scope != nil {
+		record.RequestID = scope.RequestID
+	}
+
+	var buff = new(bytes.Buffer)
+	encoder := json.NewEncoder(buff)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(record); err != nil {
+		logger.Errorf("Error encoding audit event: %v", err)
+		return
+	}
+
+	// Failing to write an audit event must not fail the request
+	if _, err := l.writer.Write(buff.Bytes()); err != nil {
+		logger.Errorf("Error writing audit event: %v", err)
+	}
+}
+
+// SetOutput sets the output destination of the audit log.
+// A nil writer disables the audit log.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writer = w
+}
+
+// SetGetClientFunc sets the function which determines the apparent "real client IP".
+func (l *Logger) SetGetClientFunc(f GetClientFunc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.getClientFunc = f
+}
+
+// These functions utilize the standard audit logger.
+
+// Log writes the event for the request to the standard audit log, with the
+// user, email and provider of the session, which may be nil.
+func Log(req *http.Request, event Event, session *sessionsapi.SessionState, reason Reason) {
+	std.Log(req, event, session, reason)
+}
+
+// LogUser writes the event for the request to the standard audit log, for a
+// user without a session.
+func LogUser(req *http.Request, event Event, user, provider string, reason Reason) {
+	std.LogUser(req, event, user, provider, reason)
+}
+
+// SetOutput sets the output destination of the standard audit log.
+// A nil writer disables the audit log.
+func SetOutput(w io.Writer) {
+	std.SetOutput(w)
+}
+
+// SetGetClientFunc sets the function which determines the apparent IP address
+// set by a reverse proxy for the standard audit log.
+func SetGetClientFunc(f GetClientFunc) {
+	std.SetGetClientFunc(f)
+}
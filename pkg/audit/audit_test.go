@@ -0,0 +1,134 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit Suite", func() {
+	var l *audit.Logger
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = bytes.NewBuffer(nil)
+		l = audit.New()
+		l.SetOutput(out)
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/callback", nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		return middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			RequestID: "11111111-2222-4333-8444-555555555555",
+		})
+	}
+
+	decode := func(line string) audit.Record {
+		record := audit.Record{}
+		Expect(json.Unmarshal([]byte(line), &record)).To(Succeed())
+		_, err := time.Parse(time.RFC3339Nano, record.Timestamp)
+		Expect(err).ToNot(HaveOccurred())
+		record.Timestamp = ""
+		return record
+	}
+
+	It("writes events with the details of the session", func() {
+		session := &sessionsapi.SessionState{
+			User:       "123456",
+			Email:      "john@example.com",
+			ProviderID: "oidc",
+		}
+		l.Log(newRequest(), audit.Login, session, "")
+
+		Expect(out.String()).To(HaveSuffix("}\n"))
+		Expect(decode(out.String())).To(Equal(audit.Record{
+			Event:     audit.Login,
+			User:      "123456",
+			Email:     "john@example.com",
+			Provider:  "oidc",
+			Client:    "192.0.2.1",
+			RequestID: "11111111-2222-4333-8444-555555555555",
+		}))
+	})
+
+	It("writes events for users without a session", func() {
+		l.LogUser(newRequest(), audit.BasicAuthFailure, "admin", "", audit.InvalidCredentials)
+
+		Expect(decode(out.String())).To(Equal(audit.Record{
+			Event:     audit.BasicAuthFailure,
+			User:      "admin",
+			Client:    "192.0.2.1",
+			RequestID: "11111111-2222-4333-8444-555555555555",
+			Reason:    audit.InvalidCredentials,
+		}))
+	})
+
+	It("writes events without a session or request scope", func() {
+		l.SetGetClientFunc(func(*http.Request) string { return "203.0.113.1" })
+		l.Log(httptest.NewRequest(http.MethodGet, "/", nil), audit.Logout, nil, "")
+
+		Expect(decode(out.String())).To(Equal(audit.Record{
+			Event:  audit.Logout,
+			Client: "203.0.113.1",
+		}))
+	})
+
+	It("does not write events without an output", func() {
+		l.SetOutput(nil)
+		l.Log(newRequest(), audit.Logout, nil, "")
+		Expect(out.Len()).To(Equal(0))
+	})
+
+	Context("NewSyslogWriter", func() {
+		It("sends events to the syslog daemon", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			writer, err := audit.NewSyslogWriter("udp://" + conn.LocalAddr().String())
+			Expect(err).ToNot(HaveOccurred())
+			l.SetOutput(writer)
+			l.LogUser(newRequest(), audit.LoginFailure, "", "oidc", audit.ProviderError)
+
+			buf := make([]byte, 4096)
+			Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The message follows the syslog header, with the auth private
+			// facility and info severity
+			message := string(buf[:n])
+			Expect(message).To(HavePrefix("<86>"))
+			Expect(message).To(ContainSubstring("oauth2-proxy"))
+			Expect(decode(message[strings.Index(message, "{"):])).To(Equal(audit.Record{
+				Event:     audit.LoginFailure,
+				Provider:  "oidc",
+				Client:    "192.0.2.1",
+				RequestID: "11111111-2222-4333-8444-555555555555",
+				Reason:    audit.ProviderError,
+			}))
+		})
+
+		DescribeTable("rejects invalid addresses",
+			func(address, expectedError string) {
+				_, err := audit.NewSyslogWriter(address)
+				Expect(err).To(MatchError(expectedError))
+			},
+			Entry("with an unknown scheme", "http://syslog.example.com", "syslog address \"http://syslog.example.com\" must be \"local\" or use one of the tcp, udp, unix or unixgram schemes"),
+			Entry("without a host", "udp://", "syslog address \"udp://\" has no host"),
+			Entry("without a socket path", "unix://", "syslog address \"unix://\" has no socket path"),
+		)
+	})
+})
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+)
+
+const (
+	// syslogLocal is the syslog address of the local syslog daemon
+	syslogLocal = "local"
+
+	// syslogTag is the tag of audit events sent to syslog
+	syslogTag = "oauth2-proxy"
+)
+
+// NewSyslogWriter connects to the syslog daemon at the address, which is
+// either `local` for the local syslog daemon, or a URI of the network and
+// address of the daemon, eg. `udp://syslog.example.com:514`.
+// Audit events are sent with the auth private facility at the info severity.
+func NewSyslogWriter(address string) (io.Writer, error) {
+	if address == syslogLocal {
+		return dialSyslog("", "")
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse syslog address: %v", err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "udp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("syslog address %q has no host", address)
+		}
+		return dialSyslog(u.Scheme, u.Host)
+	case "unix", "unixgram":
+		if u.Path == "" {
+			return nil, fmt.Errorf("syslog address %q has no socket path", address)
+		}
+		return dialSyslog(u.Scheme, u.Path)
+	default:
+		return nil, fmt.Errorf("syslog address %q must be %q or use one of the tcp, udp, unix or unixgram schemes", address, syslogLocal)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// dialSyslog connects to the syslog daemon at the address on the network,
+// or the local syslog daemon if both are empty.
+func dialSyslog(network, address string) (io.Writer, error) {
+	return syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, syslogTag)
+}
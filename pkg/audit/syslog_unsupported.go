@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog is not supported as there is no syslog package on this platform.
+func dialSyslog(_, _ string) (io.Writer, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,186 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// sweepInterval is how often expired decisions are removed from the cache
+// when new decisions are added
+const sweepInterval = time.Minute
+
+// Input is the document describing the request that is sent to the
+// authorization endpoint. It is wrapped in an "input" field, so that it can
+// be posted to the data API of an Open Policy Agent as is.
+type Input struct {
+	Claims   Claims `json:"claims"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Host     string `json:"host"`
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Claims are the claims of the authenticated session
+type Claims struct {
+	User              string   `json:"user,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Provider          string   `json:"provider,omitempty"`
+}
+
+// NewClaims extracts the claims of the session
+func NewClaims(s *sessionsapi.SessionState) Claims {
+	return Claims{
+		User:              s.User,
+		Email:             s.Email,
+		Groups:            s.Groups,
+		PreferredUsername: s.PreferredUsername,
+		Provider:          s.ProviderID,
+	}
+}
+
+// Decision is the response of the authorization endpoint. Headers are only
+// added to allowed requests.
+type Decision struct {
+	Allow   bool              `json:"allow"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// UnmarshalJSON accepts either a decision object, or a boolean for policies
+// that only allow or deny
+func (d *Decision) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &d.Allow); err == nil {
+		return nil
+	}
+
+	type decision Decision
+	return json.Unmarshal(data, (*decision)(d))
+}
+
+type cachedDecision struct {
+	decision  *Decision
+	expiresAt time.Time
+}
+
+// Authorizer asks an external HTTP endpoint, such as an Open Policy Agent,
+// whether an authenticated session may access a request. Decisions are
+// cached per input for the configured TTL.
+type Authorizer struct {
+	url      string
+	timeout  time.Duration
+	cacheTTL time.Duration
+	clock    clock.Clock
+
+	mu        sync.Mutex
+	decisions map[[sha256.Size]byte]cachedDecision
+	lastSweep time.Time
+}
+
+// NewAuthorizer creates an Authorizer for the configured endpoint
+func NewAuthorizer(opts options.ExternalAuthzOptions) *Authorizer {
+	return &Authorizer{
+		url:       opts.URL,
+		timeout:   opts.Timeout,
+		cacheTTL:  opts.CacheTTL,
+		decisions: map[[sha256.Size]byte]cachedDecision{},
+	}
+}
+
+// Authorize returns the decision of the authorization endpoint for the
+// input, or the cached decision for an identical input.
+func (a *Authorizer) Authorize(ctx context.Context, input Input) (*Decision, error) {
+	body, err := json.Marshal(struct {
+		Input Input `json:"input"`
+	}{Input: input})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding authorization input: %v", err)
+	}
+	key := sha256.Sum256(body)
+
+	if decision, ok := a.cached(key); ok {
+		return decision, nil
+	}
+
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	result := requests.New(a.url).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewReader(body)).
+		SetHeader("Content-Type", "application/json").
+		Do()
+	if result.Error() != nil {
+		return nil, result.Error()
+	}
+
+	// Open Policy Agents wrap the decision in a "result" field, which is
+	// missing when the policy is undefined and the request is denied
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := result.UnmarshalInto(&response); err != nil {
+		return nil, fmt.Errorf("error parsing authorization decision: %v", err)
+	}
+	data := result.Body()
+	if response.Result != nil {
+		data = response.Result
+	}
+	decision := &Decision{}
+	if err := json.Unmarshal(data, decision); err != nil {
+		return nil, fmt.Errorf("error parsing authorization decision: %v", err)
+	}
+
+	a.add(key, decision)
+	return decision, nil
+}
+
+func (a *Authorizer) cached(key [sha256.Size]byte) (*Decision, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cached, ok := a.decisions[key]
+	if !ok || !a.clock.Now().Before(cached.expiresAt) {
+		return nil, false
+	}
+	return cached.decision, true
+}
+
+// add caches the decision, removing expired decisions at most once per
+// sweep interval to bound the size of the cache
+func (a *Authorizer) add(key [sha256.Size]byte, decision *Decision) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	if now.Sub(a.lastSweep) >= sweepInterval {
+		for k, d := range a.decisions {
+			if !now.Before(d.expiresAt) {
+				delete(a.decisions, k)
+			}
+		}
+		a.lastSweep = now
+	}
+	a.decisions[key] = cachedDecision{
+		decision:  decision,
+		expiresAt: now.Add(a.cacheTTL),
+	}
+}
@@ -0,0 +1,154 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorizer Suite", func() {
+	ctx := context.Background()
+
+	var server *httptest.Server
+	var response string
+	var status int
+	var inputs []Input
+
+	input := Input{
+		Claims: NewClaims(&sessionsapi.SessionState{
+			User:       "user",
+			Email:      "user@example.com",
+			Groups:     []string{"admins"},
+			ProviderID: "oidc",
+		}),
+		Method:   "GET",
+		Path:     "/api/items",
+		Host:     "app.example.com",
+		Upstream: "api",
+	}
+
+	newAuthorizer := func(cacheTTL time.Duration) *Authorizer {
+		authorizer := NewAuthorizer(options.ExternalAuthzOptions{
+			URL:      server.URL,
+			Timeout:  time.Second,
+			CacheTTL: cacheTTL,
+		})
+		authorizer.clock.Set(time.Now())
+		return authorizer
+	}
+
+	BeforeEach(func() {
+		response = `{"result": true}`
+		status = http.StatusOK
+		inputs = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.Method).To(Equal("POST"))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+
+			var body struct {
+				Input Input `json:"input"`
+			}
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			inputs = append(inputs, body.Input)
+
+			rw.WriteHeader(status)
+			rw.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("parses the decision",
+		func(body string, expected *Decision) {
+			response = body
+			decision, err := newAuthorizer(0).Authorize(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decision).To(Equal(expected))
+			Expect(inputs).To(Equal([]Input{input}))
+		},
+		Entry("with an allowed result", `{"result": true}`, &Decision{Allow: true}),
+		Entry("with a denied result", `{"result": false}`, &Decision{Allow: false}),
+		Entry("with an undefined result", `{}`, &Decision{Allow: false}),
+		Entry("with a result object",
+			`{"result": {"allow": true, "headers": {"X-Role": "admin"}}}`,
+			&Decision{Allow: true, Headers: map[string]string{"X-Role": "admin"}}),
+		Entry("with a decision object",
+			`{"allow": true, "headers": {"X-Role": "admin"}}`,
+			&Decision{Allow: true, Headers: map[string]string{"X-Role": "admin"}}),
+	)
+
+	It("fails when the endpoint does not respond with a 200", func() {
+		status = http.StatusInternalServerError
+		_, err := newAuthorizer(0).Authorize(ctx, input)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails when the decision is invalid", func() {
+		response = `{"result": "yes"}`
+		_, err := newAuthorizer(0).Authorize(ctx, input)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with a decision cache", func() {
+		var authorizer *Authorizer
+
+		BeforeEach(func() {
+			authorizer = newAuthorizer(time.Minute)
+		})
+
+		It("caches decisions per input until the TTL", func() {
+			for i := 0; i < 2; i++ {
+				decision, err := authorizer.Authorize(ctx, input)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decision.Allow).To(BeTrue())
+			}
+			Expect(inputs).To(HaveLen(1))
+
+			other := input
+			other.Path = "/api/other"
+			_, err := authorizer.Authorize(ctx, other)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(2))
+
+			Expect(authorizer.clock.Add(time.Minute)).To(Succeed())
+			_, err = authorizer.Authorize(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(3))
+		})
+
+		It("does not cache errors", func() {
+			status = http.StatusInternalServerError
+			_, err := authorizer.Authorize(ctx, input)
+			Expect(err).To(HaveOccurred())
+
+			status = http.StatusOK
+			decision, err := authorizer.Authorize(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decision.Allow).To(BeTrue())
+		})
+
+		It("removes expired decisions", func() {
+			_, err := authorizer.Authorize(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(authorizer.clock.Add(2 * time.Minute)).To(Succeed())
+			other := input
+			other.Method = "POST"
+			_, err = authorizer.Authorize(ctx, other)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorizer.decisions).To(HaveLen(1))
+		})
+	})
+})
@@ -20,6 +20,7 @@ type CSRF interface {
 	HashOIDCNonce() string
 	CheckOAuthState(string) bool
 	CheckOIDCNonce(string) bool
+	CheckProviderID(string) bool
 	GetCodeVerifier() string
 
 	SetSessionNonce(s *sessions.SessionState)
@@ -44,6 +45,11 @@ type csrf struct {
 	// authentication code.
 	CodeVerifier string `msgpack:"cv,omitempty"`
 
+	// ProviderID holds the ID of the provider the authentication flow was
+	// started with. The provider ID reflected in the OAuth2 state parameter
+	// must match this before the code is redeemed.
+	ProviderID string `msgpack:"p,omitempty"`
+
 	cookieOpts *options.Cookie
 	time       clock.Clock
 }
@@ -51,8 +57,8 @@ type csrf struct {
 // csrtStateTrim will indicate the length of the state trimmed for the name of the csrf cookie
 const csrfStateLength int = 9
 
-// NewCSRF creates a CSRF with random nonces
-func NewCSRF(opts *options.Cookie, codeVerifier string) (CSRF, error) {
+// NewCSRF creates a CSRF with random nonces for the given provider
+func NewCSRF(opts *options.Cookie, codeVerifier string, providerID string) (CSRF, error) {
 	state, err := encryption.Nonce(32)
 	if err != nil {
 		return nil, err
@@ -66,6 +72,7 @@ func NewCSRF(opts *options.Cookie, codeVerifier string) (CSRF, error) {
 		OAuthState:   state,
 		OIDCNonce:    nonce,
 		CodeVerifier: codeVerifier,
+		ProviderID:   providerID,
 
 		cookieOpts: opts,
 	}, nil
@@ -121,6 +128,12 @@ func (c *csrf) CheckOIDCNonce(hashed string) bool {
 	return encryption.CheckNonce(c.OIDCNonce, hashed)
 }
 
+// CheckProviderID compares the provider ID the flow was started with
+// against the provider ID reflected in the OAuth state
+func (c *csrf) CheckProviderID(providerID string) bool {
+	return c.ProviderID == providerID
+}
+
 // SetSessionNonce sets the OIDCNonce on a SessionState
 func (c *csrf) SetSessionNonce(s *sessions.SessionState) {
 	s.Nonce = c.OIDCNonce
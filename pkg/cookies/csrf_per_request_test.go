@@ -35,7 +35,7 @@ var _ = Describe("CSRF Cookie with non-fixed name Tests", func() {
 		}
 
 		var err error
-		publicCSRF, err = NewCSRF(cookieOpts, "verifier")
+		publicCSRF, err = NewCSRF(cookieOpts, "verifier", "provider")
 		Expect(err).ToNot(HaveOccurred())
 
 		privateCSRF = publicCSRF.(*csrf)
@@ -50,7 +50,7 @@ var _ = Describe("CSRF Cookie with non-fixed name Tests", func() {
 		})
 
 		It("makes unique nonces between multiple CSRFs", func() {
-			other, err := NewCSRF(cookieOpts, "verifier")
+			other, err := NewCSRF(cookieOpts, "verifier", "provider")
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(privateCSRF.OAuthState).ToNot(Equal(other.(*csrf).OAuthState))
@@ -34,7 +34,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 		}
 
 		var err error
-		publicCSRF, err = NewCSRF(cookieOpts, "verifier")
+		publicCSRF, err = NewCSRF(cookieOpts, "verifier", "provider")
 		Expect(err).ToNot(HaveOccurred())
 
 		privateCSRF = publicCSRF.(*csrf)
@@ -49,7 +49,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 		})
 
 		It("makes unique nonces between multiple CSRFs", func() {
-			other, err := NewCSRF(cookieOpts, "verifier")
+			other, err := NewCSRF(cookieOpts, "verifier", "provider")
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(privateCSRF.OAuthState).ToNot(Equal(other.(*csrf).OAuthState))
@@ -79,6 +79,14 @@ var _ = Describe("CSRF Cookie Tests", func() {
 		})
 	})
 
+	Context("CheckProviderID", func() {
+		It("only matches the provider the flow was started with", func() {
+			Expect(publicCSRF.CheckProviderID("provider")).To(BeTrue())
+			Expect(publicCSRF.CheckProviderID("other")).To(BeFalse())
+			Expect(publicCSRF.CheckProviderID("")).To(BeFalse())
+		})
+	})
+
 	Context("SetSessionNonce", func() {
 		It("sets the session.Nonce", func() {
 			session := &sessions.SessionState{}
@@ -105,6 +113,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			Expect(decoded).ToNot(BeNil())
 			Expect(decoded.OAuthState).To(Equal([]byte(csrfState)))
 			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
+			Expect(decoded.ProviderID).To(Equal("provider"))
 		})
 
 		It("signs the encoded cookie value", func() {
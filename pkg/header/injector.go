@@ -10,25 +10,49 @@ import (
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 )
 
+// Injector adds header values to the header of a request, or of the
+// response to it, from the session of the request
 type Injector interface {
-	Inject(http.Header, *sessionsapi.SessionState)
+	Inject(*http.Request, http.Header, *sessionsapi.SessionState)
 }
 
 type injector struct {
 	valueInjectors []valueInjector
 }
 
-func (i injector) Inject(header http.Header, session *sessionsapi.SessionState) {
+func (i injector) Inject(req *http.Request, header http.Header, session *sessionsapi.SessionState) {
 	for _, injector := range i.valueInjectors {
-		injector.inject(header, session)
+		injector.inject(req, header, session)
 	}
 }
 
-func NewInjector(headers []options.Header) (Injector, error) {
+// InjectorOpts contains the dependencies of header values that are not
+// loaded from the session alone. They may be left empty if no header values
+// need them.
+type InjectorOpts struct {
+	// ExchangeToken is used for token exchange header values
+	ExchangeToken TokenExchangeFunc
+
+	// MatchUpstream returns the upstream a request is proxied to. Token
+	// exchange header values are only injected into requests to the upstream
+	// the token was exchanged for.
+	MatchUpstream func(*http.Request) (options.Upstream, bool)
+
+	// Signer is used for signed JWT header values
+	Signer *Signer
+}
+
+// NewInjector builds an Injector for the headers.
+func NewInjector(headers []options.Header, opts InjectorOpts) (Injector, error) {
+	var tokens *tokenExchangeCache
+	if opts.ExchangeToken != nil {
+		tokens = newTokenExchangeCache(opts.ExchangeToken)
+	}
+
 	injectors := []valueInjector{}
 	for _, header := range headers {
 		for _, value := range header.Values {
-			injector, err := newValueinjector(header.Name, value)
+			injector, err := newValueinjector(header.Name, value, tokens, opts)
 			if err != nil {
 				return nil, fmt.Errorf("error building injector for header %q: %v", header.Name, err)
 			}
@@ -40,29 +64,52 @@ func NewInjector(headers []options.Header) (Injector, error) {
 }
 
 type valueInjector interface {
-	inject(http.Header, *sessionsapi.SessionState)
+	inject(*http.Request, http.Header, *sessionsapi.SessionState)
 }
 
-func newValueinjector(name string, value options.HeaderValue) (valueInjector, error) {
+func newValueinjector(name string, value options.HeaderValue, tokens *tokenExchangeCache, opts InjectorOpts) (valueInjector, error) {
 	switch {
-	case value.SecretSource != nil && value.ClaimSource == nil:
+	case !HasSingleSource(value):
+		return nil, fmt.Errorf("header %q value has multiple entries: only one entry per value is allowed", name)
+	case value.SecretSource != nil:
 		return newSecretInjector(name, value.SecretSource)
-	case value.SecretSource == nil && value.ClaimSource != nil:
+	case value.ClaimSource != nil:
 		return newClaimInjector(name, value.ClaimSource)
+	case value.TokenExchange != nil:
+		return newTokenExchangeInjector(name, value.TokenExchange, tokens, opts.MatchUpstream)
+	case value.SignedJWT != nil:
+		return newSignedJWTInjector(name, value.SignedJWT, opts.Signer)
 	default:
-		return nil, fmt.Errorf("header %q value has multiple entries: only one entry per value is allowed", name)
+		return newTemplateInjector(name, value.Template)
+	}
+}
+
+// HasSingleSource checks that exactly one source is set for the header value
+func HasSingleSource(value options.HeaderValue) bool {
+	sources := 0
+	for _, set := range []bool{
+		value.SecretSource != nil,
+		value.ClaimSource != nil,
+		value.TokenExchange != nil,
+		value.SignedJWT != nil,
+		value.Template != "",
+	} {
+		if set {
+			sources++
+		}
 	}
+	return sources == 1
 }
 
 type injectorFunc struct {
-	injectFunc func(http.Header, *sessionsapi.SessionState)
+	injectFunc func(*http.Request, http.Header, *sessionsapi.SessionState)
 }
 
-func (i *injectorFunc) inject(header http.Header, session *sessionsapi.SessionState) {
-	i.injectFunc(header, session)
+func (i *injectorFunc) inject(req *http.Request, header http.Header, session *sessionsapi.SessionState) {
+	i.injectFunc(req, header, session)
 }
 
-func newInjectorFunc(injectFunc func(header http.Header, session *sessionsapi.SessionState)) valueInjector {
+func newInjectorFunc(injectFunc func(req *http.Request, header http.Header, session *sessionsapi.SessionState)) valueInjector {
 	return &injectorFunc{injectFunc: injectFunc}
 }
 
@@ -72,7 +119,7 @@ func newSecretInjector(name string, source *options.SecretSource) (valueInjector
 		return nil, fmt.Errorf("error getting secret value: %v", err)
 	}
 
-	return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+	return newInjectorFunc(func(_ *http.Request, header http.Header, session *sessionsapi.SessionState) {
 		header.Add(name, string(value))
 	}), nil
 }
@@ -84,7 +131,7 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 		if err != nil {
 			return nil, fmt.Errorf("error loading basicAuthPassword: %v", err)
 		}
-		return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+		return newInjectorFunc(func(_ *http.Request, header http.Header, session *sessionsapi.SessionState) {
 			claimValues := session.GetClaim(source.Claim)
 			for _, claim := range claimValues {
 				if claim == "" {
@@ -95,7 +142,7 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 			}
 		}), nil
 	case source.Prefix != "":
-		return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+		return newInjectorFunc(func(_ *http.Request, header http.Header, session *sessionsapi.SessionState) {
 			claimValues := session.GetClaim(source.Claim)
 			for _, claim := range claimValues {
 				if claim == "" {
@@ -105,7 +152,7 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 			}
 		}), nil
 	default:
-		return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+		return newInjectorFunc(func(_ *http.Request, header http.Header, session *sessionsapi.SessionState) {
 			claimValues := session.GetClaim(source.Claim)
 			for _, claim := range claimValues {
 				if claim == "" {
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -24,7 +25,7 @@ var _ = Describe("Injector Suite", func() {
 
 		DescribeTable("creates an injector",
 			func(in newInjectorTableInput) {
-				injector, err := NewInjector(in.headers)
+				injector, err := NewInjector(in.headers, InjectorOpts{})
 				if in.expectedErr != nil {
 					Expect(err).To(MatchError(in.expectedErr))
 					Expect(injector).To(BeNil())
@@ -35,7 +36,7 @@ var _ = Describe("Injector Suite", func() {
 				Expect(injector).ToNot(BeNil())
 
 				headers := in.initialHeaders.Clone()
-				injector.Inject(headers, in.session)
+				injector.Inject(httptest.NewRequest("GET", "/", nil), headers, in.session)
 				Expect(headers).To(Equal(in.expectedHeaders))
 			},
 			Entry("with no configured headers", newInjectorTableInput{
@@ -120,6 +121,33 @@ var _ = Describe("Injector Suite", func() {
 				},
 				expectedErr: nil,
 			}),
+			Entry("with an extra claim valued header", newInjectorTableInput{
+				headers: []options.Header{
+					{
+						Name: "Department",
+						Values: []options.HeaderValue{
+							{
+								ClaimSource: &options.ClaimSource{
+									Claim: "department",
+								},
+							},
+						},
+					},
+				},
+				initialHeaders: http.Header{
+					"foo": []string{"bar", "baz"},
+				},
+				session: &sessionsapi.SessionState{
+					Claims: map[string][]string{
+						"department": {"engineering"},
+					},
+				},
+				expectedHeaders: http.Header{
+					"foo":        []string{"bar", "baz"},
+					"Department": []string{"engineering"},
+				},
+				expectedErr: nil,
+			}),
 			Entry("with a claim valued header and a nil session", newInjectorTableInput{
 				headers: []options.Header{
 					{
@@ -0,0 +1,218 @@
+package header
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Signer signs the JWTs of signed JWT header values with a key held by the
+// proxy. Its public key is published as a JWKS so that upstreams can verify
+// the JWTs.
+type Signer struct {
+	method jwt.SigningMethod
+	key    crypto.Signer
+	keyID  string
+}
+
+// NewSigner creates a Signer from a PEM encoded private key. RSA keys sign
+// with RS256 and ECDSA P-256 keys with ES256.
+func NewSigner(pemKey []byte) (*Signer, error) {
+	var method jwt.SigningMethod
+	var key crypto.Signer
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemKey); err == nil {
+		method, key = jwt.SigningMethodRS256, rsaKey
+	} else if ecKey, err := jwt.ParseECPrivateKeyFromPEM(pemKey); err == nil {
+		if ecKey.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA keys must use the P-256 curve")
+		}
+		method, key = jwt.SigningMethodES256, ecKey
+	} else {
+		return nil, errors.New("key must be a PEM encoded RSA or ECDSA private key")
+	}
+
+	// Identify the key by its RFC 7638 thumbprint
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("error computing key thumbprint: %v", err)
+	}
+
+	return &Signer{
+		method: method,
+		key:    key,
+		keyID:  base64.RawURLEncoding.EncodeToString(thumbprint),
+	}, nil
+}
+
+// JWKS returns the key set containing the public key of the signer
+func (s *Signer) JWKS() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Key:       s.key.Public(),
+				KeyID:     s.keyID,
+				Algorithm: s.method.Alg(),
+				Use:       "sig",
+			},
+		},
+	}
+}
+
+// Sign signs the claims, identifying the signing key in the kid header
+func (s *Signer) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.key)
+}
+
+// signedJWTCache caches the JWTs signed for a signed JWT header value until
+// shortly before they expire. JWTs are cached by their session claims, so
+// that sessions with changed claims get a new JWT.
+type signedJWTCache struct {
+	signer *Signer
+	source *options.SignedJWTSource
+	expiry time.Duration
+	clock  clock.Clock
+
+	mu        sync.Mutex
+	tokens    map[string]cachedToken
+	lastSweep time.Time
+}
+
+func newSignedJWTCache(signer *Signer, source *options.SignedJWTSource) *signedJWTCache {
+	expiry := options.DefaultSignedJWTExpiry
+	if source.Expiry != nil {
+		expiry = source.Expiry.Duration()
+	}
+
+	return &signedJWTCache{
+		signer: signer,
+		source: source,
+		expiry: expiry,
+		tokens: map[string]cachedToken{},
+	}
+}
+
+// get returns the JWT signed for the session, signing a new JWT if no
+// unexpired JWT is cached
+func (c *signedJWTCache) get(session *sessionsapi.SessionState) (string, error) {
+	claims := c.sessionClaims(session)
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error encoding claims: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	key := hex.EncodeToString(hash[:])
+
+	now := c.clock.Now()
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	expiresAt := now.Add(c.expiry)
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	token, err := c.signer.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+
+	c.add(key, cachedToken{
+		value:     token,
+		expiresAt: expiresAt.Add(-tokenExpiryLeeway),
+	})
+	return token, nil
+}
+
+// sessionClaims builds the claims of the JWT that are the same for every JWT
+// signed for the session
+func (c *signedJWTCache) sessionClaims(session *sessionsapi.SessionState) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	for _, claim := range c.source.Claims {
+		values := []string{}
+		for _, value := range session.GetClaim(claim) {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+
+		switch {
+		case len(values) == 0:
+			continue
+		case len(values) == 1 && claim != "groups":
+			claims[claim] = values[0]
+		default:
+			claims[claim] = values
+		}
+	}
+
+	if session.User != "" {
+		claims["sub"] = session.User
+	}
+	if c.source.Issuer != "" {
+		claims["iss"] = c.source.Issuer
+	}
+	if c.source.Audience != "" {
+		claims["aud"] = c.source.Audience
+	}
+	return claims
+}
+
+// add caches the token, removing expired tokens at most once per sweep
+// interval to bound the size of the cache
+func (c *signedJWTCache) add(key string, token cachedToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if !now.Before(token.expiresAt) {
+		return
+	}
+	if now.Sub(c.lastSweep) >= tokenSweepInterval {
+		for k, t := range c.tokens {
+			if !now.Before(t.expiresAt) {
+				delete(c.tokens, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.tokens[key] = token
+}
+
+func newSignedJWTInjector(name string, source *options.SignedJWTSource, signer *Signer) (valueInjector, error) {
+	if signer == nil {
+		return nil, errors.New("signed JWTs require a signing key")
+	}
+
+	tokens := newSignedJWTCache(signer, source)
+	return newInjectorFunc(func(_ *http.Request, header http.Header, session *sessionsapi.SessionState) {
+		if session == nil {
+			return
+		}
+
+		token, err := tokens.get(session)
+		if err != nil {
+			logger.Errorf("Error signing JWT for header %q: %v", name, err)
+			return
+		}
+		header.Add(name, source.Prefix+token)
+	}), nil
+}
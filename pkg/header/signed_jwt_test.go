@@ -0,0 +1,197 @@
+package header
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signed JWT Suite", func() {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	rsaPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	})
+
+	newECPEM := func(curve elliptic.Curve) []byte {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		der, err := x509.MarshalECPrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	}
+
+	session := &sessionsapi.SessionState{
+		User:   "user",
+		Email:  "user@example.com",
+		Groups: []string{"admins"},
+		Claims: map[string][]string{
+			"roles": {"reader", "writer"},
+		},
+	}
+
+	Context("NewSigner", func() {
+		DescribeTable("selects the signing method for the key",
+			func(pemKey []byte, alg string) {
+				signer, err := NewSigner(pemKey)
+				Expect(err).ToNot(HaveOccurred())
+
+				jwks := signer.JWKS()
+				Expect(jwks.Keys).To(HaveLen(1))
+				Expect(jwks.Keys[0].Algorithm).To(Equal(alg))
+				Expect(jwks.Keys[0].KeyID).To(Equal(signer.keyID))
+				Expect(jwks.Keys[0].IsPublic()).To(BeTrue())
+			},
+			Entry("with an RSA key", rsaPEM, "RS256"),
+			Entry("with an ECDSA P-256 key", newECPEM(elliptic.P256()), "ES256"),
+		)
+
+		It("fails with an ECDSA key on another curve", func() {
+			_, err := NewSigner(newECPEM(elliptic.P384()))
+			Expect(err).To(MatchError("ECDSA keys must use the P-256 curve"))
+		})
+
+		It("fails without a private key", func() {
+			_, err := NewSigner([]byte("not a key"))
+			Expect(err).To(MatchError("key must be a PEM encoded RSA or ECDSA private key"))
+		})
+	})
+
+	Context("with a signed JWT header", func() {
+		var signer *Signer
+		var source *options.SignedJWTSource
+
+		parse := func(token string) jwt.MapClaims {
+			claims := jwt.MapClaims{}
+			parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+				Expect(t.Header["kid"]).To(Equal(signer.keyID))
+				return &rsaKey.PublicKey, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.Method).To(Equal(jwt.SigningMethodRS256))
+			return claims
+		}
+
+		BeforeEach(func() {
+			signer, err = NewSigner(rsaPEM)
+			Expect(err).ToNot(HaveOccurred())
+
+			expiry := options.Duration(time.Minute)
+			source = &options.SignedJWTSource{
+				Issuer:   "https://proxy.example.com",
+				Audience: "https://api.example.com",
+				Claims:   []string{"email", "groups", "roles", "missing"},
+				Expiry:   &expiry,
+				Prefix:   "Bearer ",
+			}
+		})
+
+		It("injects a JWT with the session claims", func() {
+			injector, err := NewInjector([]options.Header{
+				{
+					Name:   "Authorization",
+					Values: []options.HeaderValue{{SignedJWT: source}},
+				},
+			}, InjectorOpts{Signer: signer})
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := http.Header{}
+			injector.Inject(req, headers, session)
+			Expect(headers.Values("Authorization")).To(HaveLen(1))
+			Expect(headers.Get("Authorization")).To(HavePrefix("Bearer "))
+
+			claims := parse(strings.TrimPrefix(headers.Get("Authorization"), "Bearer "))
+			Expect(claims["exp"].(float64) - claims["iat"].(float64)).To(Equal(60.0))
+			delete(claims, "exp")
+			delete(claims, "iat")
+			Expect(claims).To(Equal(jwt.MapClaims{
+				"sub":    "user",
+				"iss":    "https://proxy.example.com",
+				"aud":    "https://api.example.com",
+				"email":  "user@example.com",
+				"groups": []interface{}{"admins"},
+				"roles":  []interface{}{"reader", "writer"},
+			}))
+		})
+
+		It("does not inject a JWT without a session", func() {
+			injector, err := NewInjector([]options.Header{
+				{
+					Name:   "Authorization",
+					Values: []options.HeaderValue{{SignedJWT: source}},
+				},
+			}, InjectorOpts{Signer: signer})
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := http.Header{}
+			injector.Inject(req, headers, nil)
+			Expect(headers).To(BeEmpty())
+		})
+
+		It("fails without a signer", func() {
+			_, err := NewInjector([]options.Header{
+				{
+					Name:   "Authorization",
+					Values: []options.HeaderValue{{SignedJWT: source}},
+				},
+			}, InjectorOpts{})
+			Expect(err).To(MatchError("error building injector for header \"Authorization\": signed JWTs require a signing key"))
+		})
+
+		Context("with the JWT cache", func() {
+			var cache *signedJWTCache
+
+			BeforeEach(func() {
+				cache = newSignedJWTCache(signer, source)
+				cache.clock.Set(time.Now())
+			})
+
+			It("caches JWTs per session until shortly before they expire", func() {
+				token, err := cache.get(session)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cache.clock.Add(time.Second)).To(Succeed())
+				cached, err := cache.get(session)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cached).To(Equal(token))
+
+				other, err := cache.get(&sessionsapi.SessionState{User: "other"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(other).ToNot(Equal(token))
+				Expect(cache.tokens).To(HaveLen(2))
+
+				Expect(cache.clock.Add(time.Minute - tokenExpiryLeeway)).To(Succeed())
+				renewed, err := cache.get(session)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(renewed).ToNot(Equal(token))
+			})
+
+			It("removes expired JWTs", func() {
+				_, err := cache.get(session)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cache.clock.Add(2 * time.Minute)).To(Succeed())
+				_, err = cache.get(&sessionsapi.SessionState{User: "other"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cache.tokens).To(HaveLen(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,128 @@
+package header
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// templateFuncs are the functions available to header value templates
+var templateFuncs = template.FuncMap{
+	"join": templateJoin,
+	"dict": templateDict,
+	"json": templateJSON,
+}
+
+// ParseTemplate parses the template of a templated header value.
+// Executing the template fails if it references a claim that is not in the
+// session.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("header").Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+}
+
+func newTemplateInjector(name string, text string) (valueInjector, error) {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %v", err)
+	}
+
+	return newInjectorFunc(func(req *http.Request, header http.Header, session *sessionsapi.SessionState) {
+		if session == nil {
+			return
+		}
+
+		var value strings.Builder
+		if err := tmpl.Execute(&value, templateData(req, session)); err != nil {
+			logger.Errorf("Error evaluating template for header %q: %v", name, err)
+			return
+		}
+		if strings.ContainsAny(value.String(), "\r\n") {
+			logger.Errorf("Error evaluating template for header %q: value contains a line break", name)
+			return
+		}
+		if value.Len() > 0 {
+			header.Add(name, value.String())
+		}
+	}), nil
+}
+
+// templateData builds the data templates are evaluated against from the
+// session claims and the request
+func templateData(req *http.Request, session *sessionsapi.SessionState) map[string]interface{} {
+	data := map[string]interface{}{}
+	for claim, values := range session.Claims {
+		if len(values) == 1 {
+			data[claim] = values[0]
+		} else {
+			data[claim] = session.GetClaim(claim)
+		}
+	}
+	for _, claim := range []string{"user", "email", "preferred_username", "access_token", "id_token"} {
+		data[claim] = session.GetClaim(claim)[0]
+	}
+	data["groups"] = session.GetClaim("groups")
+	data["provider"] = session.ProviderID
+
+	path := req.URL.Path
+	if uri, err := url.Parse(requestutil.GetRequestURI(req)); err == nil {
+		path = uri.Path
+	}
+	var requestID string
+	if scope := middlewareapi.GetRequestScope(req); scope != nil {
+		requestID = scope.RequestID
+	}
+	data["request"] = map[string]string{
+		"host": requestutil.GetRequestHost(req),
+		"path": path,
+		"id":   requestID,
+	}
+	return data
+}
+
+// templateJoin joins the values of a claim with the separator. Claims with a
+// single value are returned as is.
+func templateJoin(sep string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []string:
+		return strings.Join(v, sep), nil
+	default:
+		return "", fmt.Errorf("cannot join value of type %T", value)
+	}
+}
+
+// templateDict builds a map from pairs of keys and values
+func templateDict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict requires pairs of keys and values")
+	}
+
+	dict := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", pairs[i])
+		}
+		dict[key] = pairs[i+1]
+	}
+	return dict, nil
+}
+
+// templateJSON encodes the value as JSON
+func templateJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,128 @@
+package header
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Template Suite", func() {
+	type templateTableInput struct {
+		template       string
+		session        *sessionsapi.SessionState
+		expectedValues []string
+	}
+
+	session := &sessionsapi.SessionState{
+		User:              "user",
+		Email:             "user@example.com",
+		PreferredUsername: "jdoe",
+		Groups:            []string{"admins", "devs"},
+		ProviderID:        "keycloak",
+		Claims: map[string][]string{
+			"team":  {"platform"},
+			"roles": {"reader", "writer"},
+		},
+	}
+
+	DescribeTable("injects the evaluated template",
+		func(in templateTableInput) {
+			injector, err := NewInjector([]options.Header{
+				{
+					Name:   "X-Template",
+					Values: []options.HeaderValue{{Template: in.template}},
+				},
+			}, InjectorOpts{})
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "http://app.example.com/some/path?query=1", nil)
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+				RequestID: "11111111-2222-4333-8444-555555555555",
+			})
+
+			headers := http.Header{}
+			injector.Inject(req, headers, in.session)
+			Expect(headers.Values("X-Template")).To(Equal(in.expectedValues))
+		},
+		Entry("with claims and literals", templateTableInput{
+			template:       "{{.preferred_username}}@{{.provider}}",
+			session:        session,
+			expectedValues: []string{"jdoe@keycloak"},
+		}),
+		Entry("with request data", templateTableInput{
+			template:       "{{.request.host}} {{.request.path}} {{.request.id}}",
+			session:        session,
+			expectedValues: []string{"app.example.com /some/path 11111111-2222-4333-8444-555555555555"},
+		}),
+		Entry("with joined groups", templateTableInput{
+			template:       `{{.groups | join ","}}`,
+			session:        session,
+			expectedValues: []string{"admins,devs"},
+		}),
+		Entry("with a JSON object of claims", templateTableInput{
+			template:       `{{json (dict "email" .email "team" .team "roles" .roles)}}`,
+			session:        session,
+			expectedValues: []string{`{"email":"user@example.com","roles":["reader","writer"],"team":"platform"}`},
+		}),
+		Entry("with an optional claim", templateTableInput{
+			template:       `{{with index . "department"}}{{.}}{{else}}none{{end}}`,
+			session:        session,
+			expectedValues: []string{"none"},
+		}),
+		Entry("with a missing claim", templateTableInput{
+			template:       "{{.department}}",
+			session:        session,
+			expectedValues: nil,
+		}),
+		Entry("with an empty value", templateTableInput{
+			template:       "{{.id_token}}",
+			session:        session,
+			expectedValues: nil,
+		}),
+		Entry("with a line break", templateTableInput{
+			template:       "{{.user}}\n{{.email}}",
+			session:        session,
+			expectedValues: nil,
+		}),
+		Entry("without a session", templateTableInput{
+			template:       "{{.request.host}}",
+			session:        nil,
+			expectedValues: nil,
+		}),
+	)
+
+	It("uses the forwarded host and path of proxied requests", func() {
+		injector, err := NewInjector([]options.Header{
+			{
+				Name:   "X-Template",
+				Values: []options.HeaderValue{{Template: "{{.request.host}}{{.request.path}}"}},
+			},
+		}, InjectorOpts{})
+		Expect(err).ToNot(HaveOccurred())
+
+		req := httptest.NewRequest("GET", "http://internal/auth", nil)
+		req.Header.Set("X-Forwarded-Host", "app.example.com")
+		req.Header.Set("X-Forwarded-Uri", "/some/path?query=1")
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{ReverseProxy: true})
+
+		headers := http.Header{}
+		injector.Inject(req, headers, session)
+		Expect(headers.Get("X-Template")).To(Equal("app.example.com/some/path"))
+	})
+
+	It("fails to build an injector with an invalid template", func() {
+		_, err := NewInjector([]options.Header{
+			{
+				Name:   "X-Template",
+				Values: []options.HeaderValue{{Template: "{{.user"}},
+			},
+		}, InjectorOpts{})
+		Expect(err).To(MatchError(ContainSubstring("error building injector for header \"X-Template\": error parsing template: ")))
+	})
+})
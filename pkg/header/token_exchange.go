@@ -0,0 +1,141 @@
+package header
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenExpiryLeeway is how long before their expiry exchanged tokens are
+	// exchanged again, so that upstreams never receive an expired token
+	tokenExpiryLeeway = 10 * time.Second
+
+	// tokenSweepInterval is how often expired tokens are removed from the
+	// cache when new tokens are added
+	tokenSweepInterval = time.Minute
+)
+
+// TokenExchangeFunc exchanges the access token of a session for an access
+// token issued for the upstream with the given ID.
+type TokenExchangeFunc func(ctx context.Context, session *sessionsapi.SessionState, upstream string) (*oauth2.Token, error)
+
+// tokenCacheKey identifies an exchanged token by the access token it was
+// exchanged for and the upstream it was issued for
+type tokenCacheKey struct {
+	subject  string
+	upstream string
+}
+
+type cachedToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+// tokenExchangeCache caches exchanged tokens per session and upstream until
+// they expire. The session is identified by its access token, so that a
+// refreshed session exchanges its new access token.
+type tokenExchangeCache struct {
+	exchangeToken TokenExchangeFunc
+	clock         clock.Clock
+
+	mu        sync.Mutex
+	tokens    map[tokenCacheKey]cachedToken
+	lastSweep time.Time
+}
+
+func newTokenExchangeCache(exchangeToken TokenExchangeFunc) *tokenExchangeCache {
+	return &tokenExchangeCache{
+		exchangeToken: exchangeToken,
+		tokens:        map[tokenCacheKey]cachedToken{},
+	}
+}
+
+// get returns the token exchanged for the session and upstream, exchanging
+// the session's access token if no unexpired token is cached
+func (c *tokenExchangeCache) get(ctx context.Context, session *sessionsapi.SessionState, upstream string) (string, error) {
+	subject := sha256.Sum256([]byte(session.AccessToken))
+	key := tokenCacheKey{
+		subject:  hex.EncodeToString(subject[:]),
+		upstream: upstream,
+	}
+
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(cached.expiresAt) {
+		return cached.value, nil
+	}
+
+	token, err := c.exchangeToken(ctx, session, upstream)
+	if err != nil {
+		return "", err
+	}
+
+	// Tokens without an expiry are valid for as long as the session's
+	// access token, and are not cached if that is unknown too
+	expiresAt := token.Expiry
+	if expiresAt.IsZero() && session.ExpiresOn != nil {
+		expiresAt = *session.ExpiresOn
+	}
+	if !expiresAt.IsZero() {
+		c.add(key, cachedToken{
+			value:     token.AccessToken,
+			expiresAt: expiresAt.Add(-tokenExpiryLeeway),
+		})
+	}
+	return token.AccessToken, nil
+}
+
+// add caches the token, removing expired tokens at most once per sweep
+// interval to bound the size of the cache
+func (c *tokenExchangeCache) add(key tokenCacheKey, token cachedToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if now.Sub(c.lastSweep) >= tokenSweepInterval {
+		for k, t := range c.tokens {
+			if !now.Before(t.expiresAt) {
+				delete(c.tokens, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.tokens[key] = token
+}
+
+// newTokenExchangeInjector injects the token exchanged for the source's
+// upstream, only into requests proxied to that upstream so that the token
+// never reaches other upstreams
+func newTokenExchangeInjector(name string, source *options.TokenExchangeSource, tokens *tokenExchangeCache, matchUpstream func(*http.Request) (options.Upstream, bool)) (valueInjector, error) {
+	if tokens == nil || matchUpstream == nil {
+		return nil, errors.New("token exchange is not supported for this header")
+	}
+
+	return newInjectorFunc(func(req *http.Request, header http.Header, session *sessionsapi.SessionState) {
+		if session == nil || session.AccessToken == "" {
+			return
+		}
+		if upstream, ok := matchUpstream(req); !ok || upstream.ID != source.Upstream {
+			return
+		}
+
+		token, err := tokens.get(req.Context(), session, source.Upstream)
+		if err != nil {
+			logger.Errorf("Error exchanging token for upstream %q: %v", source.Upstream, err)
+			return
+		}
+		header.Add(name, source.Prefix+token)
+	}), nil
+}
@@ -0,0 +1,187 @@
+package header
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+)
+
+var _ = Describe("Token Exchange Suite", func() {
+	ctx := context.Background()
+	req := httptest.NewRequest("GET", "/api/", nil)
+	now := time.Now()
+
+	var exchanges []string
+	var expiry time.Time
+	var exchangeErr error
+
+	exchangeToken := func(_ context.Context, session *sessionsapi.SessionState, upstream string) (*oauth2.Token, error) {
+		if exchangeErr != nil {
+			return nil, exchangeErr
+		}
+		exchanges = append(exchanges, upstream)
+		return &oauth2.Token{
+			AccessToken: upstream + ":" + session.AccessToken,
+			Expiry:      expiry,
+		}, nil
+	}
+
+	// matchUpstream routes requests to the upstream named by the first
+	// segment of their path
+	matchUpstream := func(req *http.Request) (options.Upstream, bool) {
+		id := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)[0]
+		if id == "" {
+			return options.Upstream{}, false
+		}
+		return options.Upstream{ID: id}, true
+	}
+	injectorOpts := InjectorOpts{ExchangeToken: exchangeToken, MatchUpstream: matchUpstream}
+
+	newHeaders := func(upstreams ...string) []options.Header {
+		headers := []options.Header{}
+		for _, upstream := range upstreams {
+			headers = append(headers, options.Header{
+				Name: "X-" + upstream + "-Token",
+				Values: []options.HeaderValue{
+					{
+						TokenExchange: &options.TokenExchangeSource{
+							Upstream: upstream,
+							Prefix:   "Bearer ",
+						},
+					},
+				},
+			})
+		}
+		return headers
+	}
+
+	BeforeEach(func() {
+		exchanges = nil
+		expiry = now.Add(time.Hour)
+		exchangeErr = nil
+	})
+
+	It("injects only the token exchanged for the upstream of the request", func() {
+		injector, err := NewInjector(newHeaders("api", "other"), injectorOpts)
+		Expect(err).ToNot(HaveOccurred())
+
+		headers := http.Header{}
+		injector.Inject(req, headers, &sessionsapi.SessionState{AccessToken: "access"})
+		Expect(headers).To(Equal(http.Header{
+			"X-Api-Token": []string{"Bearer api:access"},
+		}))
+		Expect(exchanges).To(Equal([]string{"api"}))
+	})
+
+	It("does not inject a token into requests to other upstreams", func() {
+		injector, err := NewInjector(newHeaders("api"), injectorOpts)
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, path := range []string{"/other/", "/"} {
+			headers := http.Header{}
+			injector.Inject(httptest.NewRequest("GET", path, nil), headers, &sessionsapi.SessionState{AccessToken: "access"})
+			Expect(headers).To(BeEmpty())
+		}
+		Expect(exchanges).To(BeEmpty())
+	})
+
+	It("does not inject a token without a session", func() {
+		injector, err := NewInjector(newHeaders("api"), injectorOpts)
+		Expect(err).ToNot(HaveOccurred())
+
+		headers := http.Header{}
+		injector.Inject(req, headers, nil)
+		Expect(headers).To(BeEmpty())
+		Expect(exchanges).To(BeEmpty())
+	})
+
+	It("does not inject a token when the exchange fails", func() {
+		exchangeErr = errors.New("exchange failed")
+		injector, err := NewInjector(newHeaders("api"), injectorOpts)
+		Expect(err).ToNot(HaveOccurred())
+
+		headers := http.Header{}
+		injector.Inject(req, headers, &sessionsapi.SessionState{AccessToken: "access"})
+		Expect(headers).To(BeEmpty())
+	})
+
+	It("fails without a token exchange", func() {
+		injector, err := NewInjector(newHeaders("api"), InjectorOpts{})
+		Expect(err).To(MatchError("error building injector for header \"X-api-Token\": token exchange is not supported for this header"))
+		Expect(injector).To(BeNil())
+	})
+
+	It("fails without matching the upstream of requests", func() {
+		injector, err := NewInjector(newHeaders("api"), InjectorOpts{ExchangeToken: exchangeToken})
+		Expect(err).To(MatchError("error building injector for header \"X-api-Token\": token exchange is not supported for this header"))
+		Expect(injector).To(BeNil())
+	})
+
+	Context("with the token cache", func() {
+		var cache *tokenExchangeCache
+		var session *sessionsapi.SessionState
+
+		BeforeEach(func() {
+			cache = newTokenExchangeCache(exchangeToken)
+			cache.clock.Set(now)
+			session = &sessionsapi.SessionState{AccessToken: "access"}
+		})
+
+		It("caches tokens per session and upstream until they expire", func() {
+			for i := 0; i < 2; i++ {
+				token, err := cache.get(ctx, session, "api")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(token).To(Equal("api:access"))
+			}
+			Expect(exchanges).To(Equal([]string{"api"}))
+
+			_, err := cache.get(ctx, session, "other")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cache.get(ctx, &sessionsapi.SessionState{AccessToken: "refreshed"}, "api")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exchanges).To(Equal([]string{"api", "other", "api"}))
+
+			Expect(cache.clock.Add(time.Hour)).To(Succeed())
+			_, err = cache.get(ctx, session, "api")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exchanges).To(HaveLen(4))
+		})
+
+		It("caches tokens without an expiry until the session expires", func() {
+			expiry = time.Time{}
+
+			_, err := cache.get(ctx, session, "api")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache.tokens).To(BeEmpty())
+
+			sessionExpiry := now.Add(time.Hour)
+			session.ExpiresOn = &sessionExpiry
+			_, err = cache.get(ctx, session, "api")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache.tokens).To(HaveLen(1))
+		})
+
+		It("removes expired tokens", func() {
+			_, err := cache.get(ctx, session, "api")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cache.clock.Add(2 * time.Hour)).To(Succeed())
+			expiry = now.Add(3 * time.Hour)
+			_, err = cache.get(ctx, session, "other")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache.tokens).To(HaveLen(1))
+			for key := range cache.tokens {
+				Expect(key.upstream).To(Equal("other"))
+			}
+		})
+	})
+})
@@ -13,6 +13,8 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -35,12 +37,16 @@ type Opts struct {
 
 	// TLS is the TLS configuration for the server.
 	TLS *options.TLS
+
+	// H2C enables HTTP/2 over cleartext on the HTTP server.
+	H2C bool
 }
 
 // NewServer creates a new Server from the options given.
 func NewServer(opts Opts) (Server, error) {
 	s := &server{
 		handler: opts.Handler,
+		h2c:     opts.H2C,
 	}
 	if err := s.setupListener(opts); err != nil {
 		return nil, fmt.Errorf("error setting up listener: %v", err)
@@ -55,6 +61,7 @@ func NewServer(opts Opts) (Server, error) {
 // server is an implementation of the Server interface.
 type server struct {
 	handler http.Handler
+	h2c     bool
 
 	listener    net.Listener
 	tlsListener net.Listener
@@ -114,7 +121,7 @@ func (s *server) setupTLSListener(opts Opts) error {
 	config := &tls.Config{
 		MinVersion: tls.VersionTLS12, // default, override below
 		MaxVersion: tls.VersionTLS13,
-		NextProtos: []string{"http/1.1"},
+		NextProtos: []string{"h2", "http/1.1"},
 	}
 	if opts.TLS == nil {
 		return errors.New("no TLS config provided")
@@ -162,8 +169,13 @@ func (s *server) Start(ctx context.Context) error {
 	g, groupCtx := errgroup.WithContext(ctx)
 
 	if s.listener != nil {
+		handler := s.handler
+		if s.h2c {
+			handler = h2c.NewHandler(handler, &http2.Server{})
+		}
+
 		g.Go(func() error {
-			if err := s.startServer(groupCtx, s.listener); err != nil {
+			if err := s.startServer(groupCtx, s.listener, handler); err != nil {
 				return fmt.Errorf("error starting insecure server: %v", err)
 			}
 			return nil
@@ -172,7 +184,8 @@ func (s *server) Start(ctx context.Context) error {
 
 	if s.tlsListener != nil {
 		g.Go(func() error {
-			if err := s.startServer(groupCtx, s.tlsListener); err != nil {
+			// HTTP/2 is negotiated by the NextProtos of the TLS listener
+			if err := s.startServer(groupCtx, s.tlsListener, s.handler); err != nil {
 				return fmt.Errorf("error starting secure server: %v", err)
 			}
 			return nil
@@ -182,11 +195,12 @@ func (s *server) Start(ctx context.Context) error {
 	return g.Wait()
 }
 
-// startServer creates and starts a new server with the given listener.
+// startServer creates and starts a new server with the given listener and
+// handler.
 // When the given context is cancelled the server will be shutdown.
 // If any errors occur, only the first error will be returned.
-func (s *server) startServer(ctx context.Context, listener net.Listener) error {
-	srv := &http.Server{Handler: s.handler}
+func (s *server) startServer(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	srv := &http.Server{Handler: handler}
 	g, groupCtx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// jsonTimestampFormat is the RFC 3339 format of timestamps in JSON log
+// messages, with millisecond precision.
+const jsonTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// These are the JSON objects written for each type of logging when logging in
+// the JSON format. Unlike the template variables, values are typed so that
+// they do not need to be parsed by log pipelines.
+type stdLogMessageJSON struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Type      string `json:"type"`
+	File      string `json:"file"`
+	Message   string `json:"message"`
+}
+
+type authLogMessageJSON struct {
+	Timestamp     string `json:"timestamp"`
+	Level         string `json:"level"`
+	Type          string `json:"type"`
+	Client        string `json:"client"`
+	Host          string `json:"host"`
+	Protocol      string `json:"protocol"`
+	RequestID     string `json:"request_id"`
+	RequestMethod string `json:"request_method"`
+	UserAgent     string `json:"user_agent"`
+	Username      string `json:"username"`
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+}
+
+type reqLogMessageJSON struct {
+	Timestamp       string  `json:"timestamp"`
+	Level           string  `json:"level"`
+	Type            string  `json:"type"`
+	Client          string  `json:"client"`
+	Host            string  `json:"host"`
+	Protocol        string  `json:"protocol"`
+	RequestID       string  `json:"request_id"`
+	RequestDuration float64 `json:"request_duration_ms"`
+	RequestMethod   string  `json:"request_method"`
+	RequestURI      string  `json:"request_uri"`
+	ResponseSize    int     `json:"response_size"`
+	StatusCode      int     `json:"status_code"`
+	Upstream        string  `json:"upstream"`
+	UserAgent       string  `json:"user_agent"`
+	Username        string  `json:"username"`
+}
+
+// levelName returns the name of the log level in JSON log messages.
+func levelName(lvl Level) string {
+	if lvl == ERROR {
+		return "error"
+	}
+	return "info"
+}
+
+// formatJSONLogMessage formats a standard log message as a JSON object.
+// The level of the message is recorded whichever channel it is written to.
+func (l *Logger) formatJSONLogMessage(lvl Level, calldepth int, message string) []byte {
+	now := time.Now()
+	file := "???:0"
+
+	if l.flag&(Lshortfile|Llongfile) != 0 {
+		file = l.GetFileLineString(calldepth + 1)
+	}
+
+	return l.marshalJSON(stdLogMessageJSON{
+		Timestamp: l.formatJSONTimestamp(now),
+		Level:     levelName(lvl),
+		Type:      "standard",
+		File:      file,
+		Message:   strings.TrimSuffix(message, "\n"),
+	})
+}
+
+// writeJSON writes the log message to the default output channel as a JSON
+// object, followed by a newline.
+func (l *Logger) writeJSON(v interface{}) {
+	_, err := l.writer.Write(l.marshalJSON(v))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// marshalJSON encodes the log message as a JSON object followed by a newline.
+func (l *Logger) marshalJSON(v interface{}) []byte {
+	var logBuff = new(bytes.Buffer)
+	encoder := json.NewEncoder(logBuff)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		panic(err)
+	}
+	return logBuff.Bytes()
+}
+
+// formatJSONTimestamp returns an RFC 3339 timestamp for JSON log messages.
+func (l *Logger) formatJSONTimestamp(ts time.Time) string {
+	if l.flag&LUTC != 0 {
+		ts = ts.UTC()
+	}
+
+	return ts.Format(jsonTimestampFormat)
+}
@@ -0,0 +1,125 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON logging", func() {
+	var out *bytes.Buffer
+	var errOut *bytes.Buffer
+
+	BeforeEach(func() {
+		out = bytes.NewBuffer(nil)
+		errOut = bytes.NewBuffer(nil)
+
+		logger.SetOutput(out)
+		logger.SetErrOutput(errOut)
+		logger.SetFormat(logger.JSONFormat)
+	})
+
+	AfterEach(func() {
+		logger.SetFormat(logger.TextFormat)
+		logger.SetExcludePaths(nil)
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://test-server/foo/bar?baz=1", nil)
+		req.RemoteAddr = "127.0.0.1"
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+		return middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+			RequestID: "11111111-2222-4333-8444-555555555555",
+		})
+	}
+
+	decode := func(buf *bytes.Buffer) map[string]interface{} {
+		message := map[string]interface{}{}
+		Expect(json.Unmarshal(buf.Bytes(), &message)).To(Succeed())
+		Expect(buf.String()).To(HaveSuffix("}\n"))
+		Expect(message).To(HaveKey("timestamp"))
+		_, err := time.Parse(time.RFC3339, message["timestamp"].(string))
+		Expect(err).ToNot(HaveOccurred())
+		delete(message, "timestamp")
+		return message
+	}
+
+	It("logs standard messages with their level", func() {
+		logger.Println("Hello, world!")
+		Expect(decode(out)).To(Equal(map[string]interface{}{
+			"level":   "info",
+			"type":    "standard",
+			"file":    "json_test.go:55",
+			"message": "Hello, world!",
+		}))
+		Expect(errOut.Len()).To(Equal(0))
+	})
+
+	It("logs errors to the error channel", func() {
+		logger.Error("Oh no")
+		Expect(decode(errOut)["level"]).To(Equal("error"))
+		Expect(out.Len()).To(Equal(0))
+	})
+
+	It("logs errors to the default channel with ErrToInfo", func() {
+		logger.SetErrToInfo(true)
+		defer logger.SetErrToInfo(false)
+		logger.Error("Oh no")
+		Expect(decode(out)["level"]).To(Equal("error"))
+	})
+
+	It("logs auth messages", func() {
+		logger.PrintAuthf("john@example.com", newRequest(), logger.AuthFailure, "Invalid authentication via %s", "OAuth2")
+		Expect(decode(out)).To(Equal(map[string]interface{}{
+			"level":          "info",
+			"type":           "auth",
+			"client":         "127.0.0.1",
+			"host":           "test-server",
+			"protocol":       "HTTP/1.1",
+			"request_id":     "11111111-2222-4333-8444-555555555555",
+			"request_method": "GET",
+			"user_agent":     "Mozilla/5.0 (X11; Linux x86_64)",
+			"username":       "john@example.com",
+			"status":         "AuthFailure",
+			"message":        "Invalid authentication via OAuth2",
+		}))
+	})
+
+	It("logs requests with typed values", func() {
+		req := newRequest()
+		logger.PrintReq("", "upstream", req, *req.URL, time.Now().Add(-1500*time.Millisecond), http.StatusBadGateway, 42)
+
+		message := decode(out)
+		Expect(message["request_duration_ms"]).To(BeNumerically(">=", 1500))
+		delete(message, "request_duration_ms")
+		Expect(message).To(Equal(map[string]interface{}{
+			"level":          "info",
+			"type":           "request",
+			"client":         "127.0.0.1",
+			"host":           "test-server",
+			"protocol":       "HTTP/1.1",
+			"request_id":     "11111111-2222-4333-8444-555555555555",
+			"request_method": "GET",
+			"request_uri":    "/foo/bar?baz=1",
+			"response_size":  float64(42),
+			"status_code":    float64(http.StatusBadGateway),
+			"upstream":       "upstream",
+			"user_agent":     "Mozilla/5.0 (X11; Linux x86_64)",
+			"username":       "-",
+		}))
+	})
+
+	It("does not log requests to excluded paths", func() {
+		logger.SetExcludePaths([]string{"/foo/bar"})
+		req := newRequest()
+		logger.PrintReq("", "upstream", req, *req.URL, time.Now(), http.StatusOK, 42)
+		Expect(out.Len()).To(Equal(0))
+	})
+})
@@ -22,6 +22,9 @@ type AuthStatus string
 // Level indicates the log level for log messages
 type Level int
 
+// Format defines the output format of log messages
+type Format string
+
 const (
 	// DefaultStandardLoggingFormat defines the default standard log format
 	DefaultStandardLoggingFormat = "[{{.Timestamp}}] [{{.File}}] {{.Message}}"
@@ -46,6 +49,11 @@ const (
 	// LstdFlags flag for initial values for the logger
 	LstdFlags = Lshortfile
 
+	// TextFormat renders log messages through the logging format templates
+	TextFormat Format = "text"
+	// JSONFormat renders log messages as JSON objects, one per line
+	JSONFormat Format = "json"
+
 	// DEFAULT is the default log level (effectively INFO)
 	DEFAULT Level = iota
 	// ERROR is for error-level logging
@@ -100,6 +108,7 @@ type GetClientFunc = func(r *http.Request) string
 type Logger struct {
 	mu             sync.Mutex
 	flag           int
+	format         Format
 	writer         io.Writer
 	errWriter      io.Writer
 	stdEnabled     bool
@@ -118,6 +127,7 @@ func New(flag int) *Logger {
 		writer:         os.Stdout,
 		errWriter:      os.Stderr,
 		flag:           flag,
+		format:         TextFormat,
 		stdEnabled:     true,
 		authEnabled:    true,
 		reqEnabled:     true,
@@ -165,7 +175,13 @@ func (l *Logger) Output(lvl Level, calldepth int, message string) {
 	if !l.stdEnabled {
 		return
 	}
-	msg := l.formatLogMessage(calldepth+1, message)
+
+	var msg []byte
+	if l.format == JSONFormat {
+		msg = l.formatJSONLogMessage(lvl, calldepth+1, message)
+	} else {
+		msg = l.formatLogMessage(calldepth+1, message)
+	}
 
 	var err error
 	switch lvl {
@@ -199,6 +215,24 @@ func (l *Logger) PrintAuthf(username string, req *http.Request, status AuthStatu
 	defer l.mu.Unlock()
 
 	scope := middlewareapi.GetRequestScope(req)
+	if l.format == JSONFormat {
+		l.writeJSON(authLogMessageJSON{
+			Timestamp:     l.formatJSONTimestamp(now),
+			Level:         levelName(DEFAULT),
+			Type:          "auth",
+			Client:        client,
+			Host:          requestutil.GetRequestHost(req),
+			Protocol:      req.Proto,
+			RequestID:     scope.RequestID,
+			RequestMethod: req.Method,
+			UserAgent:     req.UserAgent(),
+			Username:      username,
+			Status:        string(status),
+			Message:       fmt.Sprintf(format, a...),
+		})
+		return
+	}
+
 	err := l.authTemplate.Execute(l.writer, authLogMessageData{
 		Client:        client,
 		Host:          requestutil.GetRequestHost(req),
@@ -233,7 +267,7 @@ func (l *Logger) PrintReq(username, upstream string, req *http.Request, url url.
 		return
 	}
 
-	duration := float64(time.Since(ts)) / float64(time.Second)
+	duration := time.Since(ts)
 
 	if username == "" {
 		username = "-"
@@ -255,12 +289,33 @@ func (l *Logger) PrintReq(username, upstream string, req *http.Request, url url.
 	defer l.mu.Unlock()
 
 	scope := middlewareapi.GetRequestScope(req)
+	if l.format == JSONFormat {
+		l.writeJSON(reqLogMessageJSON{
+			Timestamp:       l.formatJSONTimestamp(ts),
+			Level:           levelName(DEFAULT),
+			Type:            "request",
+			Client:          client,
+			Host:            requestutil.GetRequestHost(req),
+			Protocol:        req.Proto,
+			RequestID:       scope.RequestID,
+			RequestDuration: float64(duration) / float64(time.Millisecond),
+			RequestMethod:   req.Method,
+			RequestURI:      url.RequestURI(),
+			ResponseSize:    size,
+			StatusCode:      status,
+			Upstream:        upstream,
+			UserAgent:       req.UserAgent(),
+			Username:        username,
+		})
+		return
+	}
+
 	err := l.reqTemplate.Execute(l.writer, reqLogMessageData{
 		Client:          client,
 		Host:            requestutil.GetRequestHost(req),
 		Protocol:        req.Proto,
 		RequestID:       scope.RequestID,
-		RequestDuration: fmt.Sprintf("%0.3f", duration),
+		RequestDuration: fmt.Sprintf("%0.3f", duration.Seconds()),
 		RequestMethod:   req.Method,
 		RequestURI:      fmt.Sprintf("%q", url.RequestURI()),
 		ResponseSize:    fmt.Sprintf("%d", size),
@@ -331,6 +386,13 @@ func (l *Logger) SetFlags(flag int) {
 	l.flag = flag
 }
 
+// SetFormat sets the output format for all types of logging.
+func (l *Logger) SetFormat(f Format) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = f
+}
+
 // SetStandardEnabled enables or disables standard logging.
 func (l *Logger) SetStandardEnabled(e bool) {
 	l.mu.Lock()
@@ -432,6 +494,12 @@ func SetErrOutput(w io.Writer) {
 	std.errWriter = w
 }
 
+// SetFormat sets the output format for all types of logging for the
+// standard logger.
+func SetFormat(f Format) {
+	std.SetFormat(f)
+}
+
 // SetStandardEnabled enables or disables standard logging for the
 // standard logger.
 func SetStandardEnabled(e bool) {
@@ -0,0 +1,13 @@
+package logger_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoggerSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Logger")
+}
@@ -11,8 +11,8 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 )
 
-func NewRequestHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	headerInjector, err := newRequestHeaderInjector(headers)
+func NewRequestHeaderInjector(headers []options.Header, opts header.InjectorOpts) (alice.Constructor, error) {
+	headerInjector, err := newRequestHeaderInjector(headers, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error building request header injector: %v", err)
 	}
@@ -59,8 +59,8 @@ func stripHeaders(headers []string, next http.Handler) http.Handler {
 	})
 }
 
-func newRequestHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	injector, err := header.NewInjector(headers)
+func newRequestHeaderInjector(headers []options.Header, opts header.InjectorOpts) (alice.Constructor, error) {
+	injector, err := header.NewInjector(headers, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error building request injector: %v", err)
 	}
@@ -76,14 +76,14 @@ func injectRequestHeaders(injector header.Injector, next http.Handler) http.Hand
 
 		// If scope is nil, this will panic.
 		// A scope should always be injected before this handler is called.
-		injector.Inject(req.Header, scope.Session)
+		injector.Inject(req, req.Header, scope.Session)
 		flattenHeaders(req.Header)
 		next.ServeHTTP(rw, req)
 	})
 }
 
-func NewResponseHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	headerInjector, err := newResponseHeaderInjector(headers)
+func NewResponseHeaderInjector(headers []options.Header, opts header.InjectorOpts) (alice.Constructor, error) {
+	headerInjector, err := newResponseHeaderInjector(headers, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error building response header injector: %v", err)
 	}
@@ -91,8 +91,8 @@ func NewResponseHeaderInjector(headers []options.Header) (alice.Constructor, err
 	return headerInjector, nil
 }
 
-func newResponseHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	injector, err := header.NewInjector(headers)
+func newResponseHeaderInjector(headers []options.Header, opts header.InjectorOpts) (alice.Constructor, error) {
+	injector, err := header.NewInjector(headers, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error building response injector: %v", err)
 	}
@@ -108,7 +108,7 @@ func injectResponseHeaders(injector header.Injector, next http.Handler) http.Han
 
 		// If scope is nil, this will panic.
 		// A scope should always be injected before this handler is called.
-		injector.Inject(rw.Header(), scope.Session)
+		injector.Inject(req, rw.Header(), scope.Session)
 		flattenHeaders(rw.Header())
 		next.ServeHTTP(rw, req)
 	})
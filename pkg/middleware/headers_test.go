@@ -8,6 +8,7 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -38,7 +39,7 @@ var _ = Describe("Headers Suite", func() {
 			// Create the handler with a next handler that will capture the headers
 			// from the request
 			var gotHeaders http.Header
-			injector, err := NewRequestHeaderInjector(in.headers)
+			injector, err := NewRequestHeaderInjector(in.headers, header.InjectorOpts{})
 			if in.expectedErr != "" {
 				Expect(err).To(MatchError(in.expectedErr))
 				return
@@ -228,7 +229,7 @@ var _ = Describe("Headers Suite", func() {
 			// Create the handler with a next handler that will capture the headers
 			// from the request
 			var gotHeaders http.Header
-			injector, err := NewResponseHeaderInjector(in.headers)
+			injector, err := NewResponseHeaderInjector(in.headers, header.InjectorOpts{})
 			if in.expectedErr != "" {
 				Expect(err).To(MatchError(in.expectedErr))
 				return
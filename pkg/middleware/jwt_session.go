@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/justinas/alice"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
@@ -15,19 +18,35 @@ import (
 
 const jwtRegexFormat = `^ey[IJ][a-zA-Z0-9_-]*\.ey[IJ][a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]+$`
 
-func NewJwtSessionLoader(sessionLoaders []middlewareapi.TokenToSessionFunc) alice.Constructor {
+// NewJwtSessionLoader creates a middleware loading sessions from bearer tokens
+// with the sessionLoaders for JWTs.
+// The opaqueSessionLoaders load sessions from any bearer token, such as by
+// token introspection, and are tried after the sessionLoaders.
+// JWTs from the jwtIssuers are never passed to the opaqueSessionLoaders, as
+// the sessionLoaders verifying them have already rejected them.
+func NewJwtSessionLoader(sessionLoaders []middlewareapi.TokenToSessionFunc, opaqueSessionLoaders []middlewareapi.TokenToSessionFunc, jwtIssuers []string) alice.Constructor {
+	issuers := make(map[string]struct{}, len(jwtIssuers))
+	for _, issuer := range jwtIssuers {
+		issuers[issuer] = struct{}{}
+	}
+
 	js := &jwtSessionLoader{
-		jwtRegex:       regexp.MustCompile(jwtRegexFormat),
-		sessionLoaders: sessionLoaders,
+		jwtRegex:             regexp.MustCompile(jwtRegexFormat),
+		sessionLoaders:       sessionLoaders,
+		opaqueSessionLoaders: opaqueSessionLoaders,
+		jwtIssuers:           issuers,
 	}
 	return js.loadSession
 }
 
-// jwtSessionLoader is responsible for loading sessions from JWTs in
-// Authorization headers.
+// jwtSessionLoader is responsible for loading sessions from JWTs, and opaque
+// bearer tokens when opaqueSessionLoaders are configured, in Authorization
+// headers.
 type jwtSessionLoader struct {
-	jwtRegex       *regexp.Regexp
-	sessionLoaders []middlewareapi.TokenToSessionFunc
+	jwtRegex             *regexp.Regexp
+	sessionLoaders       []middlewareapi.TokenToSessionFunc
+	opaqueSessionLoaders []middlewareapi.TokenToSessionFunc
+	jwtIssuers           map[string]struct{}
 }
 
 // loadSession attempts to load a session from a JWT stored in an Authorization
@@ -53,6 +72,7 @@ func (j *jwtSessionLoader) loadSession(next http.Handler) http.Handler {
 
 		// Add the session to the scope if it was found
 		scope.Session = session
+		scope.BearerSession = session != nil
 		next.ServeHTTP(rw, req)
 	})
 }
@@ -71,9 +91,21 @@ func (j *jwtSessionLoader) getJwtSession(req *http.Request) (*sessionsapi.Sessio
 		return nil, err
 	}
 
+	// Opaque tokens can only be loaded by the opaque session loaders
+	// and JWTs are only loaded by them when their issuer has no verifier
+	loaders := []middlewareapi.TokenToSessionFunc{}
+	if j.jwtRegex.MatchString(token) {
+		loaders = append(loaders, j.sessionLoaders...)
+		if _, ok := j.jwtIssuers[getUnverifiedIssuer(token)]; !ok {
+			loaders = append(loaders, j.opaqueSessionLoaders...)
+		}
+	} else {
+		loaders = append(loaders, j.opaqueSessionLoaders...)
+	}
+
 	// This leading error message only occurs if all session loaders fail
 	errs := []error{errors.New("unable to verify bearer token")}
-	for _, loader := range j.sessionLoaders {
+	for _, loader := range loaders {
 		session, err := loader(req.Context(), token)
 		if err != nil {
 			errs = append(errs, err)
@@ -85,7 +117,30 @@ func (j *jwtSessionLoader) getJwtSession(req *http.Request) (*sessionsapi.Sessio
 	return nil, k8serrors.NewAggregate(errs)
 }
 
+// getUnverifiedIssuer returns the issuer claim of the JWT without verifying
+// it, or an empty string if the payload can not be decoded.
+func getUnverifiedIssuer(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
 // findTokenFromHeader finds a valid JWT token from the Authorization header of a given request.
+// Any bearer token is valid when opaqueSessionLoaders are configured.
 func (j *jwtSessionLoader) findTokenFromHeader(header string) (string, error) {
 	tokenType, token, err := splitAuthHeader(header)
 	if err != nil {
@@ -97,6 +152,11 @@ func (j *jwtSessionLoader) findTokenFromHeader(header string) (string, error) {
 		return token, nil
 	}
 
+	if tokenType == "Bearer" && len(j.opaqueSessionLoaders) > 0 {
+		// Found an opaque bearer token
+		return token, nil
+	}
+
 	if tokenType == "Basic" {
 		// Check if we have a Bearer token masquerading in Basic
 		return j.getBasicToken(token)
@@ -114,7 +114,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				// Create the handler with a next handler that will capture the session
 				// from the scope
 				var gotSession *sessionsapi.SessionState
-				handler := NewJwtSessionLoader(sessionLoaders)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler := NewJwtSessionLoader(sessionLoaders, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					gotSession = middlewareapi.GetRequestScope(r).Session
 				}))
 				handler.ServeHTTP(rw, req)
@@ -250,6 +250,101 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 		)
 	})
 
+	Context("getJWTSession with opaque session loaders", func() {
+		var j *jwtSessionLoader
+		opaqueSession := &sessionsapi.SessionState{User: "opaque"}
+
+		/* token payload:
+		{
+		  "sub": "1234567890",
+		  "aud": "https://other.myapp.com",
+		  "iss": "https://issuer.example.com"
+		}
+		*/
+		const rejectedToken = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." +
+			"eyJzdWIiOiIxMjM0NTY3ODkwIiwiYXVkIjoiaHR0cHM6Ly9vdGhlci5teWFwcC5jb20iLCJpc3MiOiJodHRwczovL2lzc3Vlci5leGFtcGxlLmNvbSJ9." +
+			"c2lnbmF0dXJl"
+
+		BeforeEach(func() {
+			verifier := oidc.NewVerifier(
+				"https://issuer.example.com",
+				noOpKeySet{},
+				&oidc.Config{
+					ClientID:        "https://test.myapp.com",
+					SkipExpiryCheck: true,
+				},
+			).Verify
+
+			j = &jwtSessionLoader{
+				jwtRegex: regexp.MustCompile(jwtRegexFormat),
+				sessionLoaders: []middlewareapi.TokenToSessionFunc{
+					middlewareapi.CreateTokenToSessionFunc(verifier),
+				},
+				opaqueSessionLoaders: []middlewareapi.TokenToSessionFunc{
+					func(_ context.Context, token string) (*sessionsapi.SessionState, error) {
+						if token == "opaque" || token == validToken || token == rejectedToken {
+							return opaqueSession, nil
+						}
+						return nil, errors.New("token is not active")
+					},
+				},
+				jwtIssuers: map[string]struct{}{"https://issuer.example.com": {}},
+			}
+		})
+
+		type getOpaqueSessionTableInput struct {
+			authorizationHeader string
+			expectedErr         error
+			expectedSession     *sessionsapi.SessionState
+		}
+
+		DescribeTable("with an authorization header",
+			func(in getOpaqueSessionTableInput) {
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Authorization", in.authorizationHeader)
+
+				session, err := j.getJwtSession(req)
+				if in.expectedErr != nil {
+					Expect(err).To(MatchError(in.expectedErr))
+				} else {
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Expect(session).To(Equal(in.expectedSession))
+			},
+			Entry("Bearer opaque", getOpaqueSessionTableInput{
+				authorizationHeader: "Bearer opaque",
+				expectedErr:         nil,
+				expectedSession:     opaqueSession,
+			}),
+			Entry("Bearer inactive", getOpaqueSessionTableInput{
+				authorizationHeader: "Bearer inactive",
+				expectedErr: k8serrors.NewAggregate([]error{
+					errors.New("unable to verify bearer token"),
+					errors.New("token is not active"),
+				}),
+				expectedSession: nil,
+			}),
+			Entry("Bearer <verifiedToken>", getOpaqueSessionTableInput{
+				authorizationHeader: fmt.Sprintf("Bearer %s", verifiedToken),
+				expectedErr:         nil,
+				expectedSession:     verifiedSession,
+			}),
+			Entry("Bearer <nonVerifiedToken>", getOpaqueSessionTableInput{
+				authorizationHeader: fmt.Sprintf("Bearer %s", validToken),
+				expectedErr:         nil,
+				expectedSession:     opaqueSession,
+			}),
+			Entry("Bearer <rejectedToken> (Issuer with a verifier)", getOpaqueSessionTableInput{
+				authorizationHeader: fmt.Sprintf("Bearer %s", rejectedToken),
+				expectedErr: k8serrors.NewAggregate([]error{
+					errors.New("unable to verify bearer token"),
+					errors.New("oidc: expected audience \"https://test.myapp.com\" got [\"https://other.myapp.com\"]"),
+				}),
+				expectedSession: nil,
+			}),
+		)
+	})
+
 	Context("findTokenFromHeader", func() {
 		var j *jwtSessionLoader
 
@@ -399,8 +494,10 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 		notVerified := false
 
 		type idTokenClaims struct {
-			Email    string `json:"email,omitempty"`
-			Verified *bool  `json:"email_verified,omitempty"`
+			Email    string      `json:"email,omitempty"`
+			Verified *bool       `json:"email_verified,omitempty"`
+			Scope    string      `json:"scope,omitempty"`
+			Scp      interface{} `json:"scp,omitempty"`
 			jwt.StandardClaims
 		}
 
@@ -410,6 +507,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 			expectedUser    string
 			expectedEmail   string
 			expectedExpires *time.Time
+			expectedScopes  []string
 		}
 
 		DescribeTable("when creating a session from an IDToken",
@@ -448,6 +546,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				Expect(session.ExpiresOn.Unix()).To(Equal(in.expectedExpires.Unix()))
 				Expect(session.RefreshToken).To(BeEmpty())
 				Expect(session.PreferredUsername).To(BeEmpty())
+				Expect(session.Scopes).To(Equal(in.expectedScopes))
 			},
 			Entry("with no email", tokenToSessionTableInput{
 				idToken: idTokenClaims{
@@ -501,6 +600,38 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				},
 				expectedErr: errors.New("email in id_token (foo@example.com) isn't verified"),
 			}),
+			Entry("with a scope claim", tokenToSessionTableInput{
+				idToken: idTokenClaims{
+					StandardClaims: jwt.StandardClaims{
+						Audience:  "asdf1234",
+						ExpiresAt: expiresFuture.Unix(),
+						Issuer:    "https://issuer.example.com",
+						Subject:   "123456789",
+					},
+					Scope: "read write",
+				},
+				expectedErr:     nil,
+				expectedUser:    "123456789",
+				expectedEmail:   "123456789",
+				expectedExpires: &expiresFuture,
+				expectedScopes:  []string{"read", "write"},
+			}),
+			Entry("with an scp claim list", tokenToSessionTableInput{
+				idToken: idTokenClaims{
+					StandardClaims: jwt.StandardClaims{
+						Audience:  "asdf1234",
+						ExpiresAt: expiresFuture.Unix(),
+						Issuer:    "https://issuer.example.com",
+						Subject:   "123456789",
+					},
+					Scp: []string{"read", "write"},
+				},
+				expectedErr:     nil,
+				expectedUser:    "123456789",
+				expectedEmail:   "123456789",
+				expectedExpires: &expiresFuture,
+				expectedScopes:  []string{"read", "write"},
+			}),
 		)
 	})
 })
@@ -10,6 +10,7 @@ import (
 	"github.com/justinas/alice"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
@@ -163,6 +164,8 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	}()
 
 	// Reload the session in case it was changed underneath us.
+	// A cached session store evicts the session when its lock is obtained,
+	// so this always reads the latest session from the shared store.
 	freshSession, err := s.store.Load(req)
 	if err != nil {
 		return fmt.Errorf("could not load session: %v", err)
@@ -207,9 +210,13 @@ func needsRefresh(refreshPeriod time.Duration, session *sessionsapi.SessionState
 func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
 	refreshed, err := s.sessionRefresher(req.Context(), session)
 	if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
+		audit.Log(req, audit.RefreshFailure, session, audit.ProviderError)
 		return fmt.Errorf("error refreshing tokens: %v", err)
 	}
 
+	// Only sessions with refreshed tokens are audited
+	tokensRefreshed := refreshed
+
 	// HACK:
 	// Providers that don't implement `RefreshSession` use the default
 	// implementation which returns `ErrNotImplemented`.
@@ -233,8 +240,14 @@ func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.R
 	err = s.store.Save(rw, req, session)
 	if err != nil {
 		logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
+		if tokensRefreshed {
+			audit.Log(req, audit.RefreshFailure, session, audit.SessionSaveFailed)
+		}
 		return fmt.Errorf("error saving session: %v", err)
 	}
+	if tokensRefreshed {
+		audit.Log(req, audit.Refresh, session, "")
+	}
 	return nil
 }
 
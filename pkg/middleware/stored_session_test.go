@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,6 +13,7 @@ import (
 
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 	. "github.com/onsi/ginkgo"
@@ -634,9 +637,11 @@ var _ = Describe("Stored Session Suite", func() {
 
 	Context("refreshSession", func() {
 		type refreshSessionWithProviderTableInput struct {
-			session     *sessionsapi.SessionState
-			expectedErr error
-			expectSaved bool
+			session             *sessionsapi.SessionState
+			expectedErr         error
+			expectSaved         bool
+			expectedAuditEvent  audit.Event
+			expectedAuditReason audit.Reason
 		}
 
 		now := time.Now()
@@ -644,6 +649,9 @@ var _ = Describe("Stored Session Suite", func() {
 		DescribeTable("when refreshing with the provider",
 			func(in refreshSessionWithProviderTableInput) {
 				saved := false
+				auditLog := bytes.NewBuffer(nil)
+				audit.SetOutput(auditLog)
+				defer audit.SetOutput(nil)
 
 				s := &storedSessionLoader{
 					store: &fakeSessionStore{
@@ -678,6 +686,15 @@ var _ = Describe("Stored Session Suite", func() {
 					Expect(err).ToNot(HaveOccurred())
 				}
 				Expect(saved).To(Equal(in.expectSaved))
+
+				if in.expectedAuditEvent == "" {
+					Expect(auditLog.Len()).To(Equal(0))
+				} else {
+					record := audit.Record{}
+					Expect(json.Unmarshal(auditLog.Bytes(), &record)).To(Succeed())
+					Expect(record.Event).To(Equal(in.expectedAuditEvent))
+					Expect(record.Reason).To(Equal(in.expectedAuditReason))
+				}
 			},
 			Entry("when the provider does not refresh the session", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
@@ -690,8 +707,9 @@ var _ = Describe("Stored Session Suite", func() {
 				session: &sessionsapi.SessionState{
 					RefreshToken: refresh,
 				},
-				expectedErr: nil,
-				expectSaved: true,
+				expectedErr:        nil,
+				expectSaved:        true,
+				expectedAuditEvent: audit.Refresh,
 			}),
 			Entry("when the provider doesn't implement refresh", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
@@ -706,16 +724,20 @@ var _ = Describe("Stored Session Suite", func() {
 					CreatedAt:    &now,
 					ExpiresOn:    &now,
 				},
-				expectedErr: errors.New("error refreshing tokens: error refreshing session"),
-				expectSaved: false,
+				expectedErr:         errors.New("error refreshing tokens: error refreshing session"),
+				expectSaved:         false,
+				expectedAuditEvent:  audit.RefreshFailure,
+				expectedAuditReason: audit.ProviderError,
 			}),
 			Entry("when the saving the session returns an error", refreshSessionWithProviderTableInput{
 				session: &sessionsapi.SessionState{
 					RefreshToken: refresh,
 					AccessToken:  "NoSave",
 				},
-				expectedErr: errors.New("error saving session: unable to save session"),
-				expectSaved: true,
+				expectedErr:         errors.New("error saving session: unable to save session"),
+				expectSaved:         true,
+				expectedAuditEvent:  audit.RefreshFailure,
+				expectedAuditReason: audit.SessionSaveFailed,
 			}),
 		)
 	})
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
+)
+
+// NewTracing returns middleware which traces each request in a server span,
+// continuing any trace propagated in the traceparent header of the request.
+// The request scope must be injected before this handler so that spans are
+// given the request ID.
+func NewTracing() alice.Constructor {
+	return traceRequest
+}
+
+func traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req, span := tracing.StartServerSpan(req)
+
+		response := &loggingResponse{ResponseWriter: rw}
+		next.ServeHTTP(response, req)
+
+		tracing.EndServerSpan(span, response.Status())
+	})
+}
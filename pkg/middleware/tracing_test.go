@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/justinas/alice"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var _ = Describe("Tracing Suite", func() {
+	var recorder *tracetest.SpanRecorder
+	var nextRequest *http.Request
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	serve := func(status int) {
+		handler := alice.New(NewScope(false, testRequestHeader), NewTracing()).Then(
+			http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				nextRequest = req
+				rw.WriteHeader(status)
+			}))
+
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Set(testRequestHeader, testRequestID)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	It("traces the request in a server span with the request ID", func() {
+		serve(http.StatusOK)
+
+		Expect(recorder.Ended()).To(HaveLen(1))
+		span := recorder.Ended()[0]
+		Expect(span.Name()).To(Equal("GET"))
+		Expect(span.SpanKind()).To(Equal(trace.SpanKindServer))
+		Expect(span.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(span.Status().Code).To(Equal(codes.Unset))
+		Expect(span.Attributes()).To(ContainElements(
+			tracing.RequestIDKey.String(testRequestID),
+			attribute.Int("http.response.status_code", http.StatusOK),
+		))
+		Expect(trace.SpanContextFromContext(nextRequest.Context())).To(Equal(span.SpanContext()))
+	})
+
+	It("marks the span of server errors as errors", func() {
+		serve(http.StatusBadGateway)
+
+		Expect(recorder.Ended()).To(HaveLen(1))
+		span := recorder.Ended()[0]
+		Expect(span.Status().Code).To(Equal(codes.Error))
+		Expect(span.Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusBadGateway)))
+	})
+})
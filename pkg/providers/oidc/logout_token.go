@@ -0,0 +1,55 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// backChannelLogoutEvent is the event member a Logout Token must contain
+// in its events claim.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken holds the claims of a verified OIDC Back-Channel Logout Token
+// that identify the sessions to be logged out.
+type LogoutToken struct {
+	Issuer    string
+	Subject   string
+	SessionID string
+}
+
+// VerifyLogoutToken verifies a Logout Token as defined by the OpenID Connect
+// Back-Channel Logout specification. The signature, issuer, audience and
+// expiry are verified by the IDTokenVerifier, the remaining checks are
+// specific to Logout Tokens.
+func VerifyLogoutToken(ctx context.Context, verifier IDTokenVerifier, rawLogoutToken string) (*LogoutToken, error) {
+	token, err := verifier.Verify(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse logout token claims: %v", err)
+	}
+
+	if _, ok := claims.Events[backChannelLogoutEvent]; !ok {
+		return nil, fmt.Errorf("logout token is missing the %q event", backChannelLogoutEvent)
+	}
+	if token.Nonce != "" {
+		return nil, errors.New("logout token must not contain a nonce")
+	}
+	if token.Subject == "" && claims.SessionID == "" {
+		return nil, errors.New("logout token must contain a sub or sid claim")
+	}
+
+	return &LogoutToken{
+		Issuer:    token.Issuer,
+		Subject:   token.Subject,
+		SessionID: claims.SessionID,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyLogoutToken", func() {
+	type verifyLogoutTokenTableInput struct {
+		claims        map[string]interface{}
+		expectedToken *LogoutToken
+		expectedError string
+	}
+
+	logoutEvents := map[string]interface{}{
+		backChannelLogoutEvent: map[string]interface{}{},
+	}
+
+	DescribeTable("should verify the logout token",
+		func(in verifyLogoutTokenTableInput) {
+			payload, err := json.Marshal(in.claims)
+			Expect(err).ToNot(HaveOccurred())
+			token, err := createToken(payload)
+			Expect(err).ToNot(HaveOccurred())
+
+			verifier := NewVerifier(oidc.NewVerifier("https://foo", &testVerifier{jwk: token.PublicKey}, &oidc.Config{
+				ClientID:          "1226737",
+				SkipClientIDCheck: true,
+				SkipExpiryCheck:   true,
+			}), IDTokenVerificationOptions{
+				AudienceClaims: []string{"aud"},
+				ClientID:       "1226737",
+			})
+
+			logoutToken, err := VerifyLogoutToken(context.Background(), verifier, token.Token)
+			if in.expectedError != "" {
+				Expect(err).To(MatchError(in.expectedError))
+				Expect(logoutToken).To(BeNil())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logoutToken).To(Equal(in.expectedToken))
+		},
+		Entry("with a subject and session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss":    "https://foo",
+				"aud":    "1226737",
+				"sub":    "user",
+				"sid":    "session",
+				"events": logoutEvents,
+			},
+			expectedToken: &LogoutToken{
+				Issuer:    "https://foo",
+				Subject:   "user",
+				SessionID: "session",
+			},
+		}),
+		Entry("with only a session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss":    "https://foo",
+				"aud":    "1226737",
+				"sid":    "session",
+				"events": logoutEvents,
+			},
+			expectedToken: &LogoutToken{
+				Issuer:    "https://foo",
+				SessionID: "session",
+			},
+		}),
+		Entry("without the logout event", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss": "https://foo",
+				"aud": "1226737",
+				"sub": "user",
+			},
+			expectedError: "logout token is missing the \"http://schemas.openid.net/event/backchannel-logout\" event",
+		}),
+		Entry("with a nonce", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss":    "https://foo",
+				"aud":    "1226737",
+				"sub":    "user",
+				"nonce":  "nonce",
+				"events": logoutEvents,
+			},
+			expectedError: "logout token must not contain a nonce",
+		}),
+		Entry("without a subject or session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss":    "https://foo",
+				"aud":    "1226737",
+				"events": logoutEvents,
+			},
+			expectedError: "logout token must contain a sub or sid claim",
+		}),
+		Entry("with an invalid audience", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"iss":    "https://foo",
+				"aud":    "7817818",
+				"sub":    "user",
+				"events": logoutEvents,
+			},
+			expectedError: "audience from claim aud with value [7817818] does not match with any of allowed audiences map[1226737:{}]",
+		}),
+	)
+})
@@ -6,6 +6,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
 )
 
 // Builder allows users to construct a request and then execute the
@@ -100,14 +102,18 @@ func (r *builder) do() Result {
 	}
 	req.Header = r.header
 
+	// The trace context is propagated to the server in a copy of the request
+	req, span := tracing.StartClientSpan(req)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		tracing.EndClientSpan(span, nil, err)
 		r.result = &result{err: fmt.Errorf("error performing request: %v", err)}
 		return r.result
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
+	tracing.EndClientSpan(span, resp, err)
 	if err != nil {
 		r.result = &result{err: fmt.Errorf("error reading response body: %v", err)}
 		return r.result
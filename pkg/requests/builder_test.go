@@ -11,6 +11,13 @@ import (
 	"github.com/bitly/go-simplejson"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var _ = Describe("Builder suite", func() {
@@ -212,6 +219,37 @@ var _ = Describe("Builder suite", func() {
 		})
 	})
 
+	Context("with tracing enabled", func() {
+		var recorder *tracetest.SpanRecorder
+
+		BeforeEach(func() {
+			recorder = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+			otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		})
+
+		It("traces the request and propagates the trace context", func() {
+			header := http.Header{"Foo": []string{"bar"}}
+			result := b.WithHeaders(header).Do()
+			Expect(result.Error()).ToNot(HaveOccurred())
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			span := recorder.Ended()[0]
+			Expect(span.SpanKind()).To(Equal(trace.SpanKindClient))
+			Expect(span.Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusOK)))
+
+			actualRequest := testHTTPRequest{}
+			Expect(json.Unmarshal(result.Body(), &actualRequest)).To(Succeed())
+			Expect(actualRequest.Header.Get("traceparent")).To(Equal(fmt.Sprintf("00-%s-%s-01", span.SpanContext().TraceID(), span.SpanContext().SpanID())))
+			Expect(header).To(Equal(http.Header{"Foo": []string{"bar"}}))
+		})
+	})
+
 	Context("if the request has been completed and then modified", func() {
 		BeforeEach(func() {
 			result := b.Do()
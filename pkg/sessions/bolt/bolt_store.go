@@ -0,0 +1,301 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// sweepInterval is how often expired sessions and index entries are
+	// deleted. Expired entries are never loaded, the sweeper only keeps the
+	// file from growing.
+	sweepInterval = time.Minute
+
+	// openTimeout is how long to wait for the file lock of the database,
+	// which is held exclusively by the process that opened it
+	openTimeout = 5 * time.Second
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	indexesBucket  = []byte("indexes")
+)
+
+// SessionStore is an implementation of the persistence.Store interface
+// that stores sessions in an embedded bbolt key/value file. The file can
+// only be opened by a single process, so locks are held in memory.
+type SessionStore struct {
+	DB *bolt.DB
+
+	clock   clock.Clock
+	locksMu sync.Mutex
+	locks   map[string]*lockEntry
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ persistence.IndexedStore = (*SessionStore)(nil)
+
+// NewBoltSessionStore initialises a new instance of the SessionStore and wraps
+// it in a persistence.Manager. Expired entries are swept in the background.
+func NewBoltSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	db, err := bolt.Open(opts.Bolt.Path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening bolt database %q: %v", opts.Bolt.Path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{sessionsBucket, indexesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating bolt buckets: %v", err)
+	}
+
+	bs := &SessionStore{
+		DB:    db,
+		locks: map[string]*lockEntry{},
+		stop:  make(chan struct{}),
+	}
+	go bs.sweep(sweepInterval)
+	return persistence.NewManager(bs, cookieOpts), nil
+}
+
+// Save stores the value under the key along with its expiration
+func (store *SessionStore) Save(_ context.Context, key string, value []byte, exp time.Duration) error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(key), store.encodeEntry(value, exp))
+	})
+	if err != nil {
+		return fmt.Errorf("error saving bolt session: %v", err)
+	}
+	return nil
+}
+
+// Load reads the value stored under the key. Expired values are treated as
+// if they do not exist.
+func (store *SessionStore) Load(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := store.DB.View(func(tx *bolt.Tx) error {
+		data, ok := store.decodeEntry(tx.Bucket(sessionsBucket).Get([]byte(key)))
+		if !ok {
+			return fmt.Errorf("session not found: %s", key)
+		}
+		// Values are only valid for the life of the transaction
+		value = append([]byte{}, data...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading bolt session: %v", err)
+	}
+	return value, nil
+}
+
+// Clear deletes the value stored under the key
+func (store *SessionStore) Clear(_ context.Context, key string) error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("error clearing the session from bolt: %v", err)
+	}
+	return nil
+}
+
+// AddToIndex adds the key to the index bucket, where it expires after exp
+func (store *SessionStore) AddToIndex(_ context.Context, index string, key string, exp time.Duration) error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(indexesBucket).CreateBucketIfNotExists([]byte(index))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), store.encodeEntry(nil, exp))
+	})
+	if err != nil {
+		return fmt.Errorf("error adding to bolt session index: %v", err)
+	}
+	return nil
+}
+
+// RemoveFromIndex removes the key from the index bucket
+func (store *SessionStore) RemoveFromIndex(_ context.Context, index string, key string) error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexesBucket).Bucket([]byte(index))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("error removing from bolt session index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads all unexpired keys in the index bucket
+func (store *SessionStore) LoadIndex(_ context.Context, index string) ([]string, error) {
+	keys := []string{}
+	err := store.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexesBucket).Bucket([]byte(index))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if _, ok := store.decodeEntry(v); ok {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading bolt session index: %v", err)
+	}
+	return keys, nil
+}
+
+// ClearIndex deletes the index bucket
+func (store *SessionStore) ClearIndex(_ context.Context, index string) error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(indexesBucket).DeleteBucket([]byte(index))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error clearing bolt session index: %v", err)
+	}
+	return nil
+}
+
+// Lock creates a lock object for sessions.SessionState
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return &Lock{
+		store: store,
+		key:   key,
+	}
+}
+
+// DeleteExpired deletes all expired sessions, index entries and locks.
+// Index buckets are deleted once they are empty.
+func (store *SessionStore) DeleteExpired() error {
+	err := store.DB.Update(func(tx *bolt.Tx) error {
+		if err := store.deleteExpiredKeys(tx.Bucket(sessionsBucket)); err != nil {
+			return err
+		}
+
+		indexes := tx.Bucket(indexesBucket)
+		var emptyIndexes [][]byte
+		err := indexes.ForEach(func(index, _ []byte) error {
+			b := indexes.Bucket(index)
+			if err := store.deleteExpiredKeys(b); err != nil {
+				return err
+			}
+			if k, _ := b.Cursor().First(); k == nil {
+				emptyIndexes = append(emptyIndexes, append([]byte{}, index...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, index := range emptyIndexes {
+			if err := indexes.DeleteBucket(index); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting expired bolt sessions: %v", err)
+	}
+
+	store.deleteExpiredLocks()
+	return nil
+}
+
+// deleteExpiredKeys deletes the expired entries of the bucket. Keys are
+// copied first as a bucket must not be modified while iterating it.
+func (store *SessionStore) deleteExpiredKeys(b *bolt.Bucket) error {
+	var expired [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if _, ok := store.decodeEntry(v); !ok {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the sweeper and closes the database, releasing its file lock.
+// It is safe to call Close more than once.
+func (store *SessionStore) Close() error {
+	var err error
+	store.closeOnce.Do(func() {
+		close(store.stop)
+		err = store.DB.Close()
+	})
+	return err
+}
+
+// sweep deletes expired entries on every tick of the interval, until the
+// store is closed
+func (store *SessionStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			if err := store.DeleteExpired(); err != nil {
+				logger.Errorf("error sweeping the bolt session store: %v", err)
+			}
+		}
+	}
+}
+
+// encodeEntry prefixes the value with its expiry in unix nanoseconds
+func (store *SessionStore) encodeEntry(value []byte, exp time.Duration) []byte {
+	entry := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(entry, uint64(store.clock.Now().Add(exp).UnixNano()))
+	return append(entry, value...)
+}
+
+// decodeEntry splits an entry into its value and whether it is unexpired
+func (store *SessionStore) decodeEntry(entry []byte) ([]byte, bool) {
+	if len(entry) < 8 {
+		return nil, false
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(entry[:8]))
+	if store.clock.Now().UnixNano() >= expiresAt {
+		return nil, false
+	}
+	return entry[8:], true
+}
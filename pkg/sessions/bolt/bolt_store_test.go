@@ -0,0 +1,189 @@
+package bolt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSessionStore(t *testing.T) {
+	logger.SetOutput(GinkgoWriter)
+	logger.SetErrOutput(GinkgoWriter)
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bolt SessionStore")
+}
+
+var _ = Describe("Bolt SessionStore Tests", func() {
+	var dir string
+	var ss sessionsapi.SessionStore
+
+	boltStore := func() *SessionStore {
+		return ss.(*persistence.Manager).Store.(*SessionStore)
+	}
+
+	newBoltStore := func() *SessionStore {
+		var err error
+		ss, err = NewBoltSessionStore(&options.SessionOptions{
+			Bolt: options.BoltStoreOptions{
+				Path: filepath.Join(dir, "sessions.db"),
+			},
+		}, &options.Cookie{})
+		Expect(err).ToNot(HaveOccurred())
+		store := boltStore()
+		store.clock.Set(time.Now())
+		return store
+	}
+
+	BeforeEach(func() {
+		ss = nil
+
+		var err error
+		dir, err = os.MkdirTemp("", "bolt-session-store")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Release the file lock immediately after the test ends
+		if ss != nil {
+			Expect(boltStore().Close()).To(Succeed())
+		}
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.BoltSessionStoreType
+			opts.Bolt.Path = filepath.Join(dir, "sessions.db")
+
+			// Capture the session store so that we can mock its clock
+			var err error
+			ss, err = NewBoltSessionStore(opts, cookieOpts)
+			if err == nil {
+				boltStore().clock.Set(time.Now())
+			}
+			return ss, err
+		},
+		func(d time.Duration) error {
+			return boltStore().clock.Add(d)
+		},
+	)
+
+	Context("when deleting expired entries", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		countKeys := func(buckets ...[]byte) int {
+			var count int
+			Expect(store.DB.View(func(tx *bolt.Tx) error {
+				b := tx.Bucket(buckets[0])
+				for _, name := range buckets[1:] {
+					b = b.Bucket(name)
+				}
+				if b == nil {
+					return nil
+				}
+				return b.ForEach(func(_, _ []byte) error {
+					count++
+					return nil
+				})
+			})).To(Succeed())
+			return count
+		}
+
+		BeforeEach(func() {
+			store = newBoltStore()
+
+			Expect(store.Save(ctx, "short", []byte("short"), time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "long", []byte("long"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "short", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "long", time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "short-index", "short", time.Minute)).To(Succeed())
+			Expect(store.Lock("short").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("deletes only the expired entries", func() {
+			Expect(store.clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.DeleteExpired()).To(Succeed())
+
+			Expect(countKeys(sessionsBucket)).To(Equal(1))
+			Expect(countKeys(indexesBucket, []byte("index"))).To(Equal(1))
+			Expect(store.locks).To(BeEmpty())
+
+			value, err := store.Load(ctx, "long")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("long")))
+		})
+
+		It("deletes empty indexes", func() {
+			Expect(store.clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.DeleteExpired()).To(Succeed())
+
+			Expect(store.DB.View(func(tx *bolt.Tx) error {
+				Expect(tx.Bucket(indexesBucket).Bucket([]byte("short-index")) == nil).To(BeTrue())
+				return nil
+			})).To(Succeed())
+		})
+
+		It("keeps unexpired entries", func() {
+			Expect(store.DeleteExpired()).To(Succeed())
+
+			Expect(countKeys(sessionsBucket)).To(Equal(2))
+			Expect(countKeys(indexesBucket, []byte("index"))).To(Equal(2))
+			Expect(store.locks).To(HaveLen(1))
+		})
+	})
+
+	Context("when the store is closed", func() {
+		It("stops the sweeper", func() {
+			store := newBoltStore()
+
+			done := make(chan struct{})
+			go func() {
+				store.sweep(time.Millisecond)
+				close(done)
+			}()
+
+			Expect(store.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(store.Close()).To(Succeed())
+		})
+	})
+
+	Context("when two locks compete for a key", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			store = newBoltStore()
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("does not obtain a held lock", func() {
+			other := store.Lock("key")
+			Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+			Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			Expect(store.Lock("key").Peek(ctx)).To(BeTrue())
+		})
+
+		It("takes over an expired lock", func() {
+			Expect(store.clock.Add(2 * time.Minute)).To(Succeed())
+
+			other := store.Lock("key")
+			Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(other.Peek(ctx)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package bolt
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// lockEntry is a lock held on a key of the SessionStore
+type lockEntry struct {
+	owner     *Lock
+	expiresAt time.Time
+}
+
+// Lock is an in-process sessions.Lock. The bolt file can only be opened by
+// a single process, so the locks of the SessionStore are only ever
+// contended within this process.
+type Lock struct {
+	store *SessionStore
+	key   string
+}
+
+// Obtain obtains the lock for the configured key, unless an unexpired lock
+// is held on it.
+func (l *Lock) Obtain(_ context.Context, expiration time.Duration) error {
+	l.store.locksMu.Lock()
+	defer l.store.locksMu.Unlock()
+
+	if entry, ok := l.store.locks[l.key]; ok && l.store.clock.Now().Before(entry.expiresAt) {
+		return sessions.ErrLockNotObtained
+	}
+	l.store.locks[l.key] = &lockEntry{
+		owner:     l,
+		expiresAt: l.store.clock.Now().Add(expiration),
+	}
+	return nil
+}
+
+// Refresh extends the expiration of an already obtained lock.
+func (l *Lock) Refresh(_ context.Context, expiration time.Duration) error {
+	l.store.locksMu.Lock()
+	defer l.store.locksMu.Unlock()
+
+	entry, ok := l.heldEntry()
+	if !ok {
+		return sessions.ErrNotLocked
+	}
+	entry.expiresAt = l.store.clock.Now().Add(expiration)
+	return nil
+}
+
+// Peek returns true, if the lock is still applied.
+func (l *Lock) Peek(_ context.Context) (bool, error) {
+	l.store.locksMu.Lock()
+	defer l.store.locksMu.Unlock()
+
+	entry, ok := l.store.locks[l.key]
+	return ok && l.store.clock.Now().Before(entry.expiresAt), nil
+}
+
+// Release releases the lock, if it is still held by this lock.
+func (l *Lock) Release(_ context.Context) error {
+	l.store.locksMu.Lock()
+	defer l.store.locksMu.Unlock()
+
+	if _, ok := l.heldEntry(); !ok {
+		return sessions.ErrNotLocked
+	}
+	delete(l.store.locks, l.key)
+	return nil
+}
+
+// heldEntry returns the unexpired lock entry of the key if it is owned by
+// this lock. The store's locksMu must be held.
+func (l *Lock) heldEntry() (*lockEntry, bool) {
+	entry, ok := l.store.locks[l.key]
+	if !ok || entry.owner != l || !l.store.clock.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// deleteExpiredLocks deletes the expired lock entries
+func (store *SessionStore) deleteExpiredLocks() {
+	store.locksMu.Lock()
+	defer store.locksMu.Unlock()
+
+	now := store.clock.Now()
+	for key, entry := range store.locks {
+		if !now.Before(entry.expiresAt) {
+			delete(store.locks, key)
+		}
+	}
+}
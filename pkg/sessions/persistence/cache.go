@@ -0,0 +1,287 @@
+package persistence
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheInvalidator broadcasts the keys changed by one process to the caches
+// of all other processes sharing the same Store.
+type CacheInvalidator interface {
+	// PublishInvalidation notifies all subscribers that the key has changed
+	PublishInvalidation(ctx context.Context, key string) error
+	// SubscribeInvalidations calls invalidate for every published key until
+	// the context is done. It returns once the subscription is established.
+	SubscribeInvalidations(ctx context.Context, invalidate func(key string)) error
+}
+
+// CacheOptions configures the in-process cache of a CachedStore
+type CacheOptions struct {
+	// Size is the maximum number of cached keys
+	Size int
+	// TTL is how long a loaded value is served from the cache. Without an
+	// Invalidator it is how long changes made by other processes may go
+	// unnoticed.
+	TTL time.Duration
+	// Invalidator optionally shares invalidations with other processes
+	Invalidator CacheInvalidator
+	// Registerer registers the hit and miss metrics, it defaults to the
+	// prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+}
+
+// CachedStore is a Store that serves loaded values from a bounded LRU cache
+// in front of another Store. Values saved or cleared by this process are
+// invalidated immediately, and published to the Invalidator if configured.
+type CachedStore struct {
+	store       Store
+	cache       *lruCache
+	invalidator CacheInvalidator
+
+	hits   prometheus.Counter
+	misses prometheus.Counter
+}
+
+// cachedIndexedStore is a CachedStore in front of an IndexedStore. Indexes
+// are not cached, they are only read when sessions are listed or cleared.
+type cachedIndexedStore struct {
+	*CachedStore
+	indexed IndexedStore
+}
+
+// NewCachedStore wraps the store in an in-process cache. The returned Store
+// is an IndexedStore if the wrapped store is one.
+func NewCachedStore(store Store, opts CacheOptions) (Store, error) {
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	cs := &CachedStore{
+		store:       store,
+		cache:       newLRUCache(opts.Size, opts.TTL),
+		invalidator: opts.Invalidator,
+		hits: registerCacheCounter(registerer, "oauth2_proxy_session_cache_hits_total",
+			"Total number of sessions loaded from the session cache."),
+		misses: registerCacheCounter(registerer, "oauth2_proxy_session_cache_misses_total",
+			"Total number of sessions not found in the session cache and loaded from the session store."),
+	}
+
+	if cs.invalidator != nil {
+		if err := cs.invalidator.SubscribeInvalidations(context.Background(), cs.cache.remove); err != nil {
+			return nil, fmt.Errorf("error subscribing to session cache invalidations: %v", err)
+		}
+	}
+
+	if indexed, ok := store.(IndexedStore); ok {
+		return &cachedIndexedStore{CachedStore: cs, indexed: indexed}, nil
+	}
+	return cs, nil
+}
+
+// Save saves the value in the wrapped store and invalidates the cached key
+func (cs *CachedStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	err := cs.store.Save(ctx, key, value, exp)
+	cs.invalidate(ctx, key)
+	return err
+}
+
+// Load serves the value from the cache, or loads it from the wrapped store
+// and caches it
+func (cs *CachedStore) Load(ctx context.Context, key string) ([]byte, error) {
+	value, generation, ok := cs.cache.get(key)
+	if ok {
+		cs.hits.Inc()
+		return value, nil
+	}
+	cs.misses.Inc()
+
+	value, err := cs.store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// The key may have been invalidated while it was loaded, in which case
+	// the loaded value may already be stale and is not cached
+	cs.cache.put(key, value, generation)
+	return value, nil
+}
+
+// Clear clears the key from the wrapped store and invalidates the cached key
+func (cs *CachedStore) Clear(ctx context.Context, key string) error {
+	err := cs.store.Clear(ctx, key)
+	cs.invalidate(ctx, key)
+	return err
+}
+
+// Lock creates a lock object from the wrapped store, locks are never cached.
+// Obtaining the lock evicts the key from this cache, so that a value loaded
+// under the lock is never a stale copy from before another process changed it.
+func (cs *CachedStore) Lock(key string) sessions.Lock {
+	return &cachedLock{
+		Lock:  cs.store.Lock(key),
+		key:   key,
+		cache: cs.cache,
+	}
+}
+
+// invalidate removes the key from this cache and publishes the invalidation
+// to the caches of other processes
+func (cs *CachedStore) invalidate(ctx context.Context, key string) {
+	cs.cache.remove(key)
+	if cs.invalidator == nil {
+		return
+	}
+	if err := cs.invalidator.PublishInvalidation(ctx, key); err != nil {
+		logger.Errorf("error publishing session cache invalidation: %v", err)
+	}
+}
+
+// AddToIndex adds the key to the index of the wrapped store
+func (cs *cachedIndexedStore) AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error {
+	return cs.indexed.AddToIndex(ctx, index, key, exp)
+}
+
+// RemoveFromIndex removes the key from the index of the wrapped store
+func (cs *cachedIndexedStore) RemoveFromIndex(ctx context.Context, index string, key string) error {
+	return cs.indexed.RemoveFromIndex(ctx, index, key)
+}
+
+// LoadIndex loads the index from the wrapped store
+func (cs *cachedIndexedStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	return cs.indexed.LoadIndex(ctx, index)
+}
+
+// ClearIndex clears the index from the wrapped store
+func (cs *cachedIndexedStore) ClearIndex(ctx context.Context, index string) error {
+	return cs.indexed.ClearIndex(ctx, index)
+}
+
+// cachedLock is a lock of the wrapped store that evicts its key from the
+// cache when it is obtained
+type cachedLock struct {
+	sessions.Lock
+	key   string
+	cache *lruCache
+}
+
+// Obtain obtains the lock of the wrapped store and evicts the cached key
+func (l *cachedLock) Obtain(ctx context.Context, expiration time.Duration) error {
+	if err := l.Lock.Obtain(ctx, expiration); err != nil {
+		return err
+	}
+	l.cache.remove(l.key)
+	return nil
+}
+
+// lruCache is a size bounded cache that evicts the least recently used key
+// and expires keys after the TTL
+type lruCache struct {
+	size  int
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	// generation is incremented on every removal, so that values loaded
+	// before a removal are not cached after it
+	generation uint64
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns a copy of the cached value of the key. On a miss it returns
+// the generation that must be passed to put when caching the loaded value.
+func (c *lruCache) get(key string) ([]byte, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.removeElement(elem)
+		return nil, c.generation, false
+	}
+	c.order.MoveToFront(elem)
+	return append([]byte{}, entry.value...), c.generation, true
+}
+
+// put caches the value, unless a key was removed since the generation
+func (c *lruCache) put(key string, value []byte, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation || c.size <= 0 {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{
+		key:       key,
+		value:     append([]byte{}, value...),
+		expiresAt: c.clock.Now().Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// remove removes the key from the cache
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement removes the element from the cache, c.mu must be held
+func (c *lruCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry).key)
+}
+
+// registerCacheCounter registers a session cache counter
+func registerCacheCounter(registerer prometheus.Registerer, name, help string) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	})
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(prometheus.Counter)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
@@ -0,0 +1,206 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mockInvalidator is an in-memory CacheInvalidator shared by several caches
+type mockInvalidator struct {
+	subscribers []func(string)
+	published   []string
+}
+
+func (mi *mockInvalidator) PublishInvalidation(_ context.Context, key string) error {
+	mi.published = append(mi.published, key)
+	for _, invalidate := range mi.subscribers {
+		invalidate(key)
+	}
+	return nil
+}
+
+func (mi *mockInvalidator) SubscribeInvalidations(_ context.Context, invalidate func(string)) error {
+	mi.subscribers = append(mi.subscribers, invalidate)
+	return nil
+}
+
+var _ = Describe("Cached Store Tests", func() {
+	ctx := context.Background()
+
+	var ms *tests.MockStore
+	var registry *prometheus.Registry
+
+	newCachedStore := func(opts CacheOptions) *CachedStore {
+		opts.Registerer = registry
+		store, err := NewCachedStore(ms, opts)
+		Expect(err).ToNot(HaveOccurred())
+		cs := store.(*cachedIndexedStore).CachedStore
+		cs.cache.clock.Set(time.Now())
+		return cs
+	}
+
+	BeforeEach(func() {
+		ms = tests.NewMockStore()
+		registry = prometheus.NewRegistry()
+	})
+
+	Context("with the shared session store tests", func() {
+		var cs *CachedStore
+
+		tests.RunSessionStoreTests(
+			func(_ *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+				store, err := NewCachedStore(ms, CacheOptions{
+					Size:       10,
+					TTL:        time.Minute,
+					Registerer: registry,
+				})
+				if err != nil {
+					return nil, err
+				}
+				cs = store.(*cachedIndexedStore).CachedStore
+				cs.cache.clock.Set(time.Now())
+				return NewManager(store, cookieOpts), nil
+			},
+			func(d time.Duration) error {
+				ms.FastForward(d)
+				return cs.cache.clock.Add(d)
+			})
+	})
+
+	Context("when loading values", func() {
+		var cs *CachedStore
+
+		BeforeEach(func() {
+			cs = newCachedStore(CacheOptions{Size: 2, TTL: time.Minute})
+			Expect(ms.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+		})
+
+		It("serves repeated loads from the cache", func() {
+			value, err := cs.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			// Change the value behind the cache's back
+			Expect(ms.Save(ctx, "key", []byte("changed"), time.Hour)).To(Succeed())
+
+			value, err = cs.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			Expect(testutil.ToFloat64(cs.hits)).To(Equal(1.0))
+			Expect(testutil.ToFloat64(cs.misses)).To(Equal(1.0))
+		})
+
+		It("reloads the value after the TTL", func() {
+			_, err := cs.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ms.Save(ctx, "key", []byte("changed"), time.Hour)).To(Succeed())
+
+			Expect(cs.cache.clock.Add(time.Minute)).To(Succeed())
+
+			value, err := cs.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("changed")))
+			Expect(testutil.ToFloat64(cs.misses)).To(Equal(2.0))
+		})
+
+		It("does not cache errors", func() {
+			_, err := cs.Load(ctx, "missing")
+			Expect(err).To(HaveOccurred())
+			Expect(ms.Save(ctx, "missing", []byte("found"), time.Hour)).To(Succeed())
+
+			value, err := cs.Load(ctx, "missing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("found")))
+		})
+
+		It("evicts the least recently used key", func() {
+			Expect(ms.Save(ctx, "other", []byte("other"), time.Hour)).To(Succeed())
+			Expect(ms.Save(ctx, "third", []byte("third"), time.Hour)).To(Succeed())
+
+			for _, key := range []string{"key", "other", "key", "third"} {
+				_, err := cs.Load(ctx, key)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(cs.cache.entries).To(HaveKey("key"))
+			Expect(cs.cache.entries).To(HaveKey("third"))
+			Expect(cs.cache.entries).ToNot(HaveKey("other"))
+		})
+
+		It("does not cache a value loaded while the key was invalidated", func() {
+			_, generation, ok := cs.cache.get("key")
+			Expect(ok).To(BeFalse())
+
+			cs.cache.remove("key")
+			cs.cache.put("key", []byte("stale"), generation)
+
+			Expect(cs.cache.entries).ToNot(HaveKey("key"))
+		})
+	})
+
+	Context("when values change", func() {
+		var invalidator *mockInvalidator
+		var cs, other *CachedStore
+
+		BeforeEach(func() {
+			invalidator = &mockInvalidator{}
+			cs = newCachedStore(CacheOptions{Size: 10, TTL: time.Minute, Invalidator: invalidator})
+			other = newCachedStore(CacheOptions{Size: 10, TTL: time.Minute, Invalidator: invalidator})
+
+			Expect(cs.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+			for _, store := range []*CachedStore{cs, other} {
+				_, err := store.Load(ctx, "key")
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("invalidates the key in all caches on Save", func() {
+			Expect(cs.Save(ctx, "key", []byte("changed"), time.Hour)).To(Succeed())
+			Expect(invalidator.published).To(Equal([]string{"key", "key"}))
+
+			for _, store := range []*CachedStore{cs, other} {
+				value, err := store.Load(ctx, "key")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal([]byte("changed")))
+			}
+		})
+
+		It("evicts the key when its lock is obtained", func() {
+			// Change the value without publishing, as if the invalidation
+			// was lost or is still in flight
+			Expect(ms.Save(ctx, "key", []byte("changed"), time.Hour)).To(Succeed())
+
+			value, err := other.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			lock := other.Lock("key")
+			Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+			defer func() {
+				Expect(lock.Release(ctx)).To(Succeed())
+			}()
+
+			value, err = other.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("changed")))
+		})
+
+		It("invalidates the key in all caches on Clear", func() {
+			Expect(other.Clear(ctx, "key")).To(Succeed())
+
+			for _, store := range []*CachedStore{cs, other} {
+				_, err := store.Load(ctx, "key")
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+})
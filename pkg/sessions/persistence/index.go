@@ -0,0 +1,254 @@
+package persistence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	subjectIndex   = "sub"
+	sessionIDIndex = "sid"
+	userIndex      = "user"
+
+	// sessionInfoSuffix is appended to a ticket ID to build the key of the
+	// session info stored alongside the session
+	sessionInfoSuffix = "-info"
+)
+
+var errIndexNotSupported = errors.New("the session store does not support indexing sessions")
+
+// idTokenIndexClaims are the ID Token claims sessions are indexed by
+type idTokenIndexClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+}
+
+// ClearBySubject clears all sessions indexed under the subject of the issuer
+func (m *Manager) ClearBySubject(ctx context.Context, issuer, subject string) error {
+	return m.clearIndex(ctx, m.indexKey(subjectIndex, issuer, subject))
+}
+
+// ClearBySessionID clears all sessions indexed under the sid of the issuer
+func (m *Manager) ClearBySessionID(ctx context.Context, issuer, sessionID string) error {
+	return m.clearIndex(ctx, m.indexKey(sessionIDIndex, issuer, sessionID))
+}
+
+// ClearByUser clears all sessions indexed under the user
+func (m *Manager) ClearByUser(ctx context.Context, user string) error {
+	return m.clearIndex(ctx, m.indexKey(userIndex, "", user))
+}
+
+// ClearByTicketID clears the session stored under the ticket ID
+func (m *Manager) ClearByTicketID(ctx context.Context, ticketID string) error {
+	store, ok := m.Store.(IndexedStore)
+	if !ok {
+		return errIndexNotSupported
+	}
+	if !isTicketID(ticketID, m.Options) {
+		return sessions.ErrInvalidTicketID
+	}
+	return m.clearTicket(ctx, store, ticketID)
+}
+
+// ListSessions lists the session info of all sessions that have not expired.
+// Only the session info stored by indexSession can be read, the sessions
+// themselves are encrypted with the secret of their ticket.
+func (m *Manager) ListSessions(ctx context.Context) ([]*sessions.StoredSession, error) {
+	store, ok := m.Store.(IndexedStore)
+	if !ok {
+		return nil, errIndexNotSupported
+	}
+
+	ticketIDs, err := store.LoadIndex(ctx, m.allSessionsIndexKey())
+	if err != nil {
+		return nil, fmt.Errorf("error loading session index: %v", err)
+	}
+
+	storedSessions := []*sessions.StoredSession{}
+	for _, ticketID := range ticketIDs {
+		info, err := m.loadSessionInfo(ctx, store, ticketID)
+		if err != nil {
+			logger.Errorf("error loading session info of %s: %v", ticketID, err)
+			continue
+		}
+		storedSessions = append(storedSessions, &sessions.StoredSession{
+			TicketID: ticketID,
+			Session:  info,
+		})
+	}
+	return storedSessions, nil
+}
+
+// indexSession stores the session info and adds the ticket ID to the index
+// of all sessions, the index of the session's user and the indexes of its
+// ID Token claims.
+func (m *Manager) indexSession(ctx context.Context, ticketID string, s *sessions.SessionState) error {
+	store, ok := m.Store.(IndexedStore)
+	if !ok {
+		return nil
+	}
+
+	if err := m.saveSessionInfo(ctx, store, ticketID, s); err != nil {
+		return err
+	}
+
+	indexes := []string{m.allSessionsIndexKey()}
+	if s.User != "" {
+		indexes = append(indexes, m.indexKey(userIndex, "", s.User))
+	}
+
+	var claimsErr error
+	if s.IDToken != "" {
+		var claims *idTokenIndexClaims
+		claims, claimsErr = parseIDTokenIndexClaims(s.IDToken)
+		if claimsErr == nil && claims.Subject != "" {
+			indexes = append(indexes, m.indexKey(subjectIndex, claims.Issuer, claims.Subject))
+		}
+		if claimsErr == nil && claims.SessionID != "" {
+			indexes = append(indexes, m.indexKey(sessionIDIndex, claims.Issuer, claims.SessionID))
+		}
+	}
+
+	for _, index := range indexes {
+		if err := store.AddToIndex(ctx, index, ticketID, m.Options.Expire); err != nil {
+			return fmt.Errorf("error adding session to index: %v", err)
+		}
+	}
+	return claimsErr
+}
+
+// clearIndex clears every session in the index and then the index itself.
+// Indexes may reference sessions that have already been cleared, clearing
+// these again is a no-op.
+func (m *Manager) clearIndex(ctx context.Context, index string) error {
+	store, ok := m.Store.(IndexedStore)
+	if !ok {
+		return errIndexNotSupported
+	}
+
+	ticketIDs, err := store.LoadIndex(ctx, index)
+	if err != nil {
+		return fmt.Errorf("error loading session index: %v", err)
+	}
+	for _, ticketID := range ticketIDs {
+		if err := m.clearTicket(ctx, store, ticketID); err != nil {
+			return err
+		}
+	}
+	return store.ClearIndex(ctx, index)
+}
+
+// clearTicket clears the session and session info of the ticket and removes
+// it from the index of all sessions and its user's index. The ID Token claim
+// indexes are not pruned, their entries expire with the session.
+func (m *Manager) clearTicket(ctx context.Context, store IndexedStore, ticketID string) error {
+	// The session info may already have expired, it is only needed to find
+	// the user's index
+	info, _ := m.loadSessionInfo(ctx, store, ticketID)
+
+	if err := store.Clear(ctx, ticketID); err != nil {
+		return err
+	}
+	if err := store.Clear(ctx, ticketID+sessionInfoSuffix); err != nil {
+		return err
+	}
+	if err := store.RemoveFromIndex(ctx, m.allSessionsIndexKey(), ticketID); err != nil {
+		return fmt.Errorf("error removing session from index: %v", err)
+	}
+	if info != nil && info.User != "" {
+		if err := store.RemoveFromIndex(ctx, m.indexKey(userIndex, "", info.User), ticketID); err != nil {
+			return fmt.Errorf("error removing session from user index: %v", err)
+		}
+	}
+	return nil
+}
+
+// saveSessionInfo stores the user and lifetime of the session next to it.
+// Unlike the session it is encrypted with the cookie secret, so that
+// sessions can be listed without their tickets. Tokens are never included.
+func (m *Manager) saveSessionInfo(ctx context.Context, store Store, ticketID string, s *sessions.SessionState) error {
+	info := &sessions.SessionState{
+		CreatedAt:         s.CreatedAt,
+		ExpiresOn:         s.ExpiresOn,
+		Email:             s.Email,
+		User:              s.User,
+		Groups:            s.Groups,
+		PreferredUsername: s.PreferredUsername,
+		ProviderID:        s.ProviderID,
+	}
+
+	c, err := m.makeInfoCipher()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := info.EncodeSessionState(c, false)
+	if err != nil {
+		return fmt.Errorf("failed to encode the session info: %v", err)
+	}
+	return store.Save(ctx, ticketID+sessionInfoSuffix, ciphertext, m.Options.Expire)
+}
+
+// loadSessionInfo loads the session info stored by saveSessionInfo
+func (m *Manager) loadSessionInfo(ctx context.Context, store Store, ticketID string) (*sessions.SessionState, error) {
+	ciphertext, err := store.Load(ctx, ticketID+sessionInfoSuffix)
+	if err != nil {
+		return nil, err
+	}
+	c, err := m.makeInfoCipher()
+	if err != nil {
+		return nil, err
+	}
+	return sessions.DecodeSessionState(ciphertext, c, false)
+}
+
+// makeInfoCipher makes an AES-GCM cipher out of the cookie secret
+func (m *Manager) makeInfoCipher() (encryption.Cipher, error) {
+	c, err := encryption.NewGCMCipher(encryption.SecretBytes(m.Options.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make an AES-GCM cipher from the cookie secret: %v", err)
+	}
+	return c, nil
+}
+
+// allSessionsIndexKey is the Store key of the index of all sessions
+func (m *Manager) allSessionsIndexKey() string {
+	return fmt.Sprintf("%s-sessions", m.Options.Name)
+}
+
+// indexKey builds the Store key of an index. The values are hashed as they
+// are controlled by the provider and may be of any length.
+func (m *Manager) indexKey(kind, issuer, value string) string {
+	hash := sha256.Sum256([]byte(issuer + "\x00" + value))
+	return fmt.Sprintf("%s-%s-%s", m.Options.Name, kind, hex.EncodeToString(hash[:]))
+}
+
+// parseIDTokenIndexClaims reads the index claims from the payload of an
+// ID Token. The token was verified when the session was created so its
+// signature is not checked again here.
+func parseIDTokenIndexClaims(rawIDToken string) (*idTokenIndexClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token: expected 3 parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token payload: %v", err)
+	}
+
+	claims := &idTokenIndexClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %v", err)
+	}
+	return claims, nil
+}
@@ -16,3 +16,19 @@ type Store interface {
 	Clear(context.Context, string) error
 	Lock(key string) sessions.Lock
 }
+
+// IndexedStore is a persistent Store that can also maintain sets of keys
+// under an index key. The Manager uses this to index sessions by their user
+// and the claims of their ID Token, and to enumerate all stored sessions.
+type IndexedStore interface {
+	Store
+	// AddToIndex adds the key to the index. The key is dropped from the
+	// index after the expiration, along with the session it refers to.
+	AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error
+	// RemoveFromIndex removes the key from the index
+	RemoveFromIndex(ctx context.Context, index string, key string) error
+	// LoadIndex returns all keys in the index that have not expired
+	LoadIndex(ctx context.Context, index string) ([]string, error)
+	// ClearIndex deletes the index
+	ClearIndex(ctx context.Context, index string) error
+}
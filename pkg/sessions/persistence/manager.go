@@ -7,6 +7,7 @@ import (
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
 // Manager wraps a Store and handles the implementation details of the
@@ -16,6 +17,8 @@ type Manager struct {
 	Options *options.Cookie
 }
 
+var _ sessions.IndexedSessionStore = (*Manager)(nil)
+
 // NewManager creates a Manager that can wrap a Store and manage the
 // sessions.SessionStore implementation details
 func NewManager(store Store, cookieOpts *options.Cookie) *Manager {
@@ -48,6 +51,12 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 		return err
 	}
 
+	// A session that can't be indexed is still usable, it just can't be
+	// listed or cleared without its ticket
+	if err := m.indexSession(req.Context(), tckt.id, s); err != nil {
+		logger.Errorf("error indexing session: %v", err)
+	}
+
 	return tckt.setCookie(rw, req, s)
 }
 
@@ -87,6 +96,9 @@ func (m *Manager) Clear(rw http.ResponseWriter, req *http.Request) error {
 
 	tckt.clearCookie(rw, req)
 	return tckt.clearSession(func(key string) error {
+		if store, ok := m.Store.(IndexedStore); ok {
+			return m.clearTicket(req.Context(), store, key)
+		}
 		return m.Store.Clear(req.Context(), key)
 	})
 }
@@ -65,6 +65,17 @@ func newTicket(cookieOpts *options.Cookie) (*ticket, error) {
 	}, nil
 }
 
+// isTicketID checks whether the ID has the format of the IDs of tickets
+// created by newTicket
+func isTicketID(ticketID string, cookieOpts *options.Cookie) bool {
+	prefix := cookieOpts.Name + "-"
+	if !strings.HasPrefix(ticketID, prefix) {
+		return false
+	}
+	rawID, err := hex.DecodeString(strings.TrimPrefix(ticketID, prefix))
+	return err == nil && len(rawID) == 16
+}
+
 // encodeTicket encodes the Ticket to a string for usage in cookies
 func (t *ticket) encodeTicket() string {
 	return fmt.Sprintf("%s.%s", t.id, base64.RawURLEncoding.EncodeToString(t.secret))
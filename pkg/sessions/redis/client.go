@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,6 +15,19 @@ type Client interface {
 	Lock(key string) sessions.Lock
 	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
 	Del(ctx context.Context, key string) error
+	// ZAdd adds the member to the sorted set scored by its expiry time,
+	// dropping expired members and extending the expiration of the set
+	ZAdd(ctx context.Context, key string, member string, expiration time.Duration) error
+	// ZRem removes the member from the sorted set
+	ZRem(ctx context.Context, key string, member string) error
+	// ZMembers returns the members of the sorted set that have not expired
+	ZMembers(ctx context.Context, key string) ([]string, error)
+	// Publish posts the message to the channel
+	Publish(ctx context.Context, channel string, message string) error
+	// Subscribe calls the handler with every message posted to the channel
+	// until the context is done. It returns once the subscription is
+	// confirmed by the server.
+	Subscribe(ctx context.Context, channel string, handler func(message string)) error
 }
 
 var _ Client = (*client)(nil)
@@ -40,6 +54,26 @@ func (c *client) Del(ctx context.Context, key string) error {
 	return c.Client.Del(ctx, key).Err()
 }
 
+func (c *client) ZAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	return zAdd(ctx, c.Client, key, member, expiration)
+}
+
+func (c *client) ZRem(ctx context.Context, key string, member string) error {
+	return c.Client.ZRem(ctx, key, member).Err()
+}
+
+func (c *client) ZMembers(ctx context.Context, key string) ([]string, error) {
+	return zMembers(ctx, c.Client, key)
+}
+
+func (c *client) Publish(ctx context.Context, channel string, message string) error {
+	return c.Client.Publish(ctx, channel, message).Err()
+}
+
+func (c *client) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	return subscribe(ctx, c.Client.Subscribe(ctx, channel), handler)
+}
+
 func (c *client) Lock(key string) sessions.Lock {
 	return NewLock(c.Client, key)
 }
@@ -68,6 +102,75 @@ func (c *clusterClient) Del(ctx context.Context, key string) error {
 	return c.ClusterClient.Del(ctx, key).Err()
 }
 
+func (c *clusterClient) ZAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	return zAdd(ctx, c.ClusterClient, key, member, expiration)
+}
+
+func (c *clusterClient) ZRem(ctx context.Context, key string, member string) error {
+	return c.ClusterClient.ZRem(ctx, key, member).Err()
+}
+
+func (c *clusterClient) ZMembers(ctx context.Context, key string) ([]string, error) {
+	return zMembers(ctx, c.ClusterClient, key)
+}
+
+func (c *clusterClient) Publish(ctx context.Context, channel string, message string) error {
+	return c.ClusterClient.Publish(ctx, channel, message).Err()
+}
+
+func (c *clusterClient) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	return subscribe(ctx, c.ClusterClient.Subscribe(ctx, channel), handler)
+}
+
 func (c *clusterClient) Lock(key string) sessions.Lock {
 	return NewLock(c.ClusterClient, key)
 }
+
+// zAdd implements Client.ZAdd for any redis client. The members of the
+// sorted set are scored by the unix time at which they expire.
+func zAdd(ctx context.Context, c redis.Cmdable, key string, member string, expiration time.Duration) error {
+	now := time.Now()
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Unix(), 10))
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.Add(expiration).Unix()), Member: member})
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+// zMembers implements Client.ZMembers for any redis client
+func zMembers(ctx context.Context, c redis.Cmdable, key string) ([]string, error) {
+	return c.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(time.Now().Unix(), 10),
+		Max: "+inf",
+	}).Result()
+}
+
+// subscribe implements Client.Subscribe for any redis client. Messages are
+// handled in the background until the context is done.
+func subscribe(ctx context.Context, pubsub *redis.PubSub, handler func(message string)) error {
+	// Wait for the subscription to be confirmed, so that no message
+	// published after subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handler(msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
@@ -13,14 +13,24 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// cacheInvalidationChannel is the pub/sub channel on which the keys of
+// changed sessions are published to the session caches of all replicas
+const cacheInvalidationChannel = "oauth2-proxy-session-cache-invalidation"
+
 // SessionStore is an implementation of the persistence.Store
 // interface that stores sessions in redis
 type SessionStore struct {
 	Client Client
 }
 
+var _ persistence.IndexedStore = (*SessionStore)(nil)
+var _ persistence.CacheInvalidator = (*SessionStore)(nil)
+
 // NewRedisSessionStore initialises a new instance of the SessionStore and wraps
 // it in a persistence.Manager
 func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
@@ -38,7 +48,9 @@ func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cook
 // Save takes a sessions.SessionState and stores the information from it
 // to redis, and adds a new persistence cookie on the HTTP response writer
 func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	ctx, span := startSpan(ctx, "Save")
 	err := store.Client.Set(ctx, key, value, exp)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("error saving redis session: %v", err)
 	}
@@ -48,7 +60,9 @@ func (store *SessionStore) Save(ctx context.Context, key string, value []byte, e
 // Load reads sessions.SessionState information from a persistence
 // cookie within the HTTP request object
 func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := startSpan(ctx, "Load")
 	value, err := store.Client.Get(ctx, key)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("error loading redis session: %v", err)
 	}
@@ -58,18 +72,95 @@ func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error)
 // Clear clears any saved session information for a given persistence cookie
 // from redis, and then clears the session
 func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	ctx, span := startSpan(ctx, "Clear")
 	err := store.Client.Del(ctx, key)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("error clearing the session from redis: %v", err)
 	}
 	return nil
 }
 
+// AddToIndex adds the key to the sorted set stored under the index, where
+// it expires after exp
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error {
+	ctx, span := startSpan(ctx, "AddToIndex")
+	err := store.Client.ZAdd(ctx, index, key, exp)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error adding to redis session index: %v", err)
+	}
+	return nil
+}
+
+// RemoveFromIndex removes the key from the sorted set stored under the index
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, key string) error {
+	ctx, span := startSpan(ctx, "RemoveFromIndex")
+	err := store.Client.ZRem(ctx, index, key)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error removing from redis session index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads all unexpired keys in the sorted set stored under the index
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	ctx, span := startSpan(ctx, "LoadIndex")
+	keys, err := store.Client.ZMembers(ctx, index)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("error loading redis session index: %v", err)
+	}
+	return keys, nil
+}
+
+// ClearIndex deletes the sorted set stored under the index
+func (store *SessionStore) ClearIndex(ctx context.Context, index string) error {
+	ctx, span := startSpan(ctx, "ClearIndex")
+	err := store.Client.Del(ctx, index)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error clearing redis session index: %v", err)
+	}
+	return nil
+}
+
 // Lock creates a lock object for sessions.SessionState
 func (store *SessionStore) Lock(key string) sessions.Lock {
 	return store.Client.Lock(key)
 }
 
+// PublishInvalidation publishes the key to the session caches of all
+// replicas subscribed to the redis server
+func (store *SessionStore) PublishInvalidation(ctx context.Context, key string) error {
+	ctx, span := startSpan(ctx, "PublishInvalidation")
+	err := store.Client.Publish(ctx, cacheInvalidationChannel, key)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error publishing redis session cache invalidation: %v", err)
+	}
+	return nil
+}
+
+// SubscribeInvalidations calls invalidate with every key published by a
+// replica until the context is done
+func (store *SessionStore) SubscribeInvalidations(ctx context.Context, invalidate func(key string)) error {
+	err := store.Client.Subscribe(ctx, cacheInvalidationChannel, invalidate)
+	if err != nil {
+		return fmt.Errorf("error subscribing to redis session cache invalidations: %v", err)
+	}
+	return nil
+}
+
+// startSpan starts a span for the operation of the session store
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "redis.SessionStore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis),
+	)
+}
+
 // NewRedisClient makes a redis.Client (either standalone, sentinel aware, or
 // redis cluster)
 func NewRedisClient(opts options.RedisStoreOptions) (Client, error) {
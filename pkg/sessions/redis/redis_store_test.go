@@ -443,4 +443,62 @@ var _ = Describe("Redis SessionStore Tests", func() {
 			},
 		)
 	})
+
+	Context("with session cache invalidation", func() {
+		var publisher, subscriber *SessionStore
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		BeforeEach(func() {
+			// The stores are closed below, not by the JustAfterEach
+			ss = nil
+			ctx, cancel = context.WithCancel(context.Background())
+
+			for _, store := range []**SessionStore{&publisher, &subscriber} {
+				client, err := NewRedisClient(options.RedisStoreOptions{
+					ConnectionURL: "redis://" + mr.Addr(),
+				})
+				Expect(err).ToNot(HaveOccurred())
+				*store = &SessionStore{Client: client}
+			}
+		})
+
+		AfterEach(func() {
+			cancel()
+			Expect(publisher.Client.(closer).Close()).To(Succeed())
+			Expect(subscriber.Client.(closer).Close()).To(Succeed())
+		})
+
+		It("receives the keys published by other stores", func() {
+			invalidated := make(chan string, 1)
+			Expect(subscriber.SubscribeInvalidations(ctx, func(key string) {
+				invalidated <- key
+			})).To(Succeed())
+
+			Expect(publisher.PublishInvalidation(ctx, "ticket-id")).To(Succeed())
+			Eventually(invalidated).Should(Receive(Equal("ticket-id")))
+		})
+
+		It("invalidates the session caches of other replicas", func() {
+			cached := func(store *SessionStore) persistence.Store {
+				cs, err := persistence.NewCachedStore(store, persistence.CacheOptions{
+					Size:        10,
+					TTL:         time.Minute,
+					Invalidator: store,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				return cs
+			}
+			publisherCache := cached(publisher)
+			subscriberCache := cached(subscriber)
+
+			Expect(publisherCache.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+			Expect(subscriberCache.Load(ctx, "key")).To(Equal([]byte("value")))
+
+			Expect(publisherCache.Save(ctx, "key", []byte("changed"), time.Hour)).To(Succeed())
+			Eventually(func() ([]byte, error) {
+				return subscriberCache.Load(ctx, "key")
+			}).Should(Equal([]byte("changed")))
+		})
+	})
 })
@@ -5,17 +5,55 @@ import (
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/bolt"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 )
 
-// NewSessionStore creates a SessionStore from the provided configuration
+// NewSessionStore creates a SessionStore from the provided configuration.
+// Persistent session stores are wrapped in an in-memory cache if enabled.
 func NewSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	if opts.Cache.Size > 0 && opts.Cache.RedisInvalidation && opts.Type != options.RedisSessionStoreType {
+		return nil, fmt.Errorf("session cache invalidation requires the redis session store")
+	}
+
+	ss, err := newSessionStore(opts, cookieOpts)
+	if err != nil || opts.Cache.Size <= 0 {
+		return ss, err
+	}
+
+	manager, ok := ss.(*persistence.Manager)
+	if !ok {
+		return ss, nil
+	}
+
+	cacheOpts := persistence.CacheOptions{
+		Size: opts.Cache.Size,
+		TTL:  opts.Cache.TTL,
+	}
+	if opts.Cache.RedisInvalidation {
+		cacheOpts.Invalidator, _ = manager.Store.(persistence.CacheInvalidator)
+	}
+
+	manager.Store, err = persistence.NewCachedStore(manager.Store, cacheOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing session cache: %v", err)
+	}
+	return manager, nil
+}
+
+func newSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
 	switch opts.Type {
 	case options.CookieSessionStoreType:
 		return cookie.NewCookieSessionStore(opts, cookieOpts)
 	case options.RedisSessionStoreType:
 		return redis.NewRedisSessionStore(opts, cookieOpts)
+	case options.SQLSessionStoreType:
+		return sql.NewSQLSessionStore(opts, cookieOpts)
+	case options.BoltSessionStoreType:
+		return bolt.NewBoltSessionStore(opts, cookieOpts)
 	default:
 		return nil, fmt.Errorf("unknown session store type '%s'", opts.Type)
 	}
@@ -3,15 +3,20 @@ package sessions_test
 import (
 	"encoding/base64"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	sessionsbolt "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/bolt"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	sessionssql "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -74,6 +79,82 @@ var _ = Describe("NewSessionStore", func() {
 			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
 			Expect(ss.(*persistence.Manager).Store).To(BeAssignableToTypeOf(&redis.SessionStore{}))
 		})
+
+		It("wraps the redis.SessionStore in a cache when enabled", func() {
+			opts.Cache.Size = 10
+			opts.Cache.TTL = time.Second
+
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+			Expect(ss.(*persistence.Manager).Store).ToNot(BeAssignableToTypeOf(&redis.SessionStore{}))
+			_, indexed := ss.(*persistence.Manager).Store.(persistence.IndexedStore)
+			Expect(indexed).To(BeTrue())
+		})
+	})
+
+	Context("with type 'sql'", func() {
+		var dir string
+
+		BeforeEach(func() {
+			if !tests.SQLiteAvailable() {
+				Skip("the sqlite3 driver requires cgo")
+			}
+
+			var err error
+			dir, err = os.MkdirTemp("", "sql-session-store")
+			Expect(err).ToNot(HaveOccurred())
+
+			opts.Type = options.SQLSessionStoreType
+			opts.SQL.Driver = "sqlite3"
+			opts.SQL.DSN = filepath.Join(dir, "sessions.db")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("creates a persistence.Manager that wraps a sql.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+			Expect(ss.(*persistence.Manager).Store).To(BeAssignableToTypeOf(&sessionssql.SessionStore{}))
+			Expect(ss.(*persistence.Manager).Store.(*sessionssql.SessionStore).DB.Close()).To(Succeed())
+		})
+	})
+
+	Context("with type 'bolt'", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "bolt-session-store")
+			Expect(err).ToNot(HaveOccurred())
+
+			opts.Type = options.BoltSessionStoreType
+			opts.Bolt.Path = filepath.Join(dir, "sessions.db")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("creates a persistence.Manager that wraps a bolt.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+			Expect(ss.(*persistence.Manager).Store).To(BeAssignableToTypeOf(&sessionsbolt.SessionStore{}))
+			Expect(ss.(*persistence.Manager).Store.(*sessionsbolt.SessionStore).DB.Close()).To(Succeed())
+		})
+
+		It("does not support redis cache invalidation", func() {
+			opts.Cache.Size = 10
+			opts.Cache.RedisInvalidation = true
+
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).To(MatchError("session cache invalidation requires the redis session store"))
+			Expect(ss).To(BeNil())
+		})
 	})
 
 	Context("with an invalid type", func() {
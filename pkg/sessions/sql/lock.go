@@ -0,0 +1,98 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+// Lock is a sessions.Lock held as a row in the locks table. The row is
+// owned by the lock holding its random token, and can be taken over by
+// any other lock once it has expired.
+type Lock struct {
+	store *SessionStore
+	key   string
+	token string
+}
+
+// Obtain obtains the lock on the database for the configured key.
+// The lock row is only inserted, or taken over, when no unexpired lock is
+// held, which the database decides atomically.
+func (l *Lock) Obtain(ctx context.Context, expiration time.Duration) error {
+	nonce, err := encryption.Nonce(32)
+	if err != nil {
+		return fmt.Errorf("error generating a lock token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(nonce)
+
+	result, err := l.store.DB.ExecContext(ctx,
+		`INSERT INTO `+locksTable+` (lock_key, token, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (lock_key) DO UPDATE SET token = excluded.token, expires_at = excluded.expires_at
+		WHERE `+locksTable+`.expires_at <= $4`,
+		l.key, token, l.store.expiresAt(expiration), l.store.now())
+	if err != nil {
+		return fmt.Errorf("error obtaining sql lock: %v", err)
+	}
+	if !rowsAffected(result) {
+		return sessions.ErrLockNotObtained
+	}
+	l.token = token
+	return nil
+}
+
+// Refresh extends the expiration of an already obtained lock.
+func (l *Lock) Refresh(ctx context.Context, expiration time.Duration) error {
+	if l.token == "" {
+		return sessions.ErrNotLocked
+	}
+	result, err := l.store.DB.ExecContext(ctx,
+		`UPDATE `+locksTable+` SET expires_at = $1 WHERE lock_key = $2 AND token = $3 AND expires_at > $4`,
+		l.store.expiresAt(expiration), l.key, l.token, l.store.now())
+	if err != nil {
+		return fmt.Errorf("error refreshing sql lock: %v", err)
+	}
+	if !rowsAffected(result) {
+		return sessions.ErrNotLocked
+	}
+	return nil
+}
+
+// Peek returns true, if the lock is still applied.
+func (l *Lock) Peek(ctx context.Context) (bool, error) {
+	var count int
+	err := l.store.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM `+locksTable+` WHERE lock_key = $1 AND expires_at > $2`,
+		l.key, l.store.now()).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error peeking sql lock: %v", err)
+	}
+	return count > 0, nil
+}
+
+// Release deletes the lock row, if it is still held by this lock.
+func (l *Lock) Release(ctx context.Context) error {
+	if l.token == "" {
+		return sessions.ErrNotLocked
+	}
+	result, err := l.store.DB.ExecContext(ctx,
+		`DELETE FROM `+locksTable+` WHERE lock_key = $1 AND token = $2`, l.key, l.token)
+	if err != nil {
+		return fmt.Errorf("error releasing sql lock: %v", err)
+	}
+	l.token = ""
+	if !rowsAffected(result) {
+		return sessions.ErrNotLocked
+	}
+	return nil
+}
+
+// rowsAffected checks whether the statement changed any rows
+func rowsAffected(result sql.Result) bool {
+	n, err := result.RowsAffected()
+	return err == nil && n > 0
+}
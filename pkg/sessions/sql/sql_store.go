@@ -0,0 +1,248 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	// Register the postgres driver with database/sql
+	_ "github.com/lib/pq"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+const (
+	sessionsTable = "oauth2_proxy_sessions"
+	locksTable    = "oauth2_proxy_session_locks"
+	indexesTable  = "oauth2_proxy_session_indexes"
+
+	// cleanupInterval is how often expired rows are deleted. Expired rows
+	// are never loaded, the cleanup only keeps the tables from growing.
+	cleanupInterval = 5 * time.Minute
+)
+
+// schema creates the tables of the SessionStore. The statements are limited
+// to SQL understood by both PostgreSQL and SQLite. Expirations are stored as
+// unix milliseconds so that they can be compared to the store's clock rather
+// than the database's.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + sessionsTable + ` (
+		session_key VARCHAR(255) PRIMARY KEY,
+		data BYTEA NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + sessionsTable + `_expires_at ON ` + sessionsTable + ` (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS ` + locksTable + ` (
+		lock_key VARCHAR(255) PRIMARY KEY,
+		token VARCHAR(64) NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ` + indexesTable + ` (
+		index_key VARCHAR(255) NOT NULL,
+		session_key VARCHAR(255) NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (index_key, session_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + indexesTable + `_expires_at ON ` + indexesTable + ` (expires_at)`,
+}
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in a SQL database
+type SessionStore struct {
+	DB *sql.DB
+
+	clock     clock.Clock
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ persistence.IndexedStore = (*SessionStore)(nil)
+
+// NewSQLSessionStore initialises a new instance of the SessionStore and wraps
+// it in a persistence.Manager. The tables are created if they do not exist
+// and expired rows are deleted in the background.
+func NewSQLSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	db, err := sql.Open(opts.SQL.Driver, opts.SQL.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sql database: %v", err)
+	}
+
+	ss := &SessionStore{
+		DB:   db,
+		stop: make(chan struct{}),
+	}
+	if err := ss.createSchema(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	go ss.cleanup(cleanupInterval)
+	return persistence.NewManager(ss, cookieOpts), nil
+}
+
+// Save takes a sessions.SessionState and stores the information from it
+// in the database, replacing any existing session under the key
+func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	_, err := store.DB.ExecContext(ctx,
+		`INSERT INTO `+sessionsTable+` (session_key, data, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (session_key) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at`,
+		key, value, store.expiresAt(exp))
+	if err != nil {
+		return fmt.Errorf("error saving sql session: %v", err)
+	}
+	return nil
+}
+
+// Load reads sessions.SessionState information from the database.
+// Expired sessions are treated as if they do not exist.
+func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT data FROM `+sessionsTable+` WHERE session_key = $1 AND expires_at > $2`,
+		key, store.now()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session not found: %s", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading sql session: %v", err)
+	}
+	return value, nil
+}
+
+// Clear deletes any saved session information for the key from the database
+func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	_, err := store.DB.ExecContext(ctx, `DELETE FROM `+sessionsTable+` WHERE session_key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("error clearing the session from sql: %v", err)
+	}
+	return nil
+}
+
+// AddToIndex adds the key to the index, where it expires after exp
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, key string, exp time.Duration) error {
+	_, err := store.DB.ExecContext(ctx,
+		`INSERT INTO `+indexesTable+` (index_key, session_key, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (index_key, session_key) DO UPDATE SET expires_at = excluded.expires_at`,
+		index, key, store.expiresAt(exp))
+	if err != nil {
+		return fmt.Errorf("error adding to sql session index: %v", err)
+	}
+	return nil
+}
+
+// RemoveFromIndex removes the key from the index
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, key string) error {
+	_, err := store.DB.ExecContext(ctx,
+		`DELETE FROM `+indexesTable+` WHERE index_key = $1 AND session_key = $2`, index, key)
+	if err != nil {
+		return fmt.Errorf("error removing from sql session index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads all unexpired keys in the index
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT session_key FROM `+indexesTable+` WHERE index_key = $1 AND expires_at > $2`,
+		index, store.now())
+	if err != nil {
+		return nil, fmt.Errorf("error loading sql session index: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error loading sql session index: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading sql session index: %v", err)
+	}
+	return keys, nil
+}
+
+// ClearIndex deletes all keys in the index
+func (store *SessionStore) ClearIndex(ctx context.Context, index string) error {
+	_, err := store.DB.ExecContext(ctx, `DELETE FROM `+indexesTable+` WHERE index_key = $1`, index)
+	if err != nil {
+		return fmt.Errorf("error clearing sql session index: %v", err)
+	}
+	return nil
+}
+
+// Lock creates a lock object for sessions.SessionState
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return &Lock{
+		store: store,
+		key:   key,
+	}
+}
+
+// DeleteExpired deletes all expired sessions, locks and index entries
+func (store *SessionStore) DeleteExpired(ctx context.Context) error {
+	for _, table := range []string{sessionsTable, locksTable, indexesTable} {
+		_, err := store.DB.ExecContext(ctx, `DELETE FROM `+table+` WHERE expires_at <= $1`, store.now())
+		if err != nil {
+			return fmt.Errorf("error deleting expired rows from %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// createSchema creates the tables and indexes of the store
+func (store *SessionStore) createSchema(ctx context.Context) error {
+	for _, stmt := range schema {
+		if _, err := store.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error creating sql session store schema: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close stops the background cleanup and closes the database.
+// It is safe to call Close more than once.
+func (store *SessionStore) Close() error {
+	var err error
+	store.closeOnce.Do(func() {
+		close(store.stop)
+		err = store.DB.Close()
+	})
+	return err
+}
+
+// cleanup deletes expired rows on every tick of the interval until the
+// store is closed
+func (store *SessionStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			if err := store.DeleteExpired(context.Background()); err != nil {
+				logger.Errorf("error cleaning up the sql session store: %v", err)
+			}
+		}
+	}
+}
+
+// now is the current time of the store's clock in unix milliseconds
+func (store *SessionStore) now() int64 {
+	return store.clock.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// expiresAt is the time in unix milliseconds after the expiration
+func (store *SessionStore) expiresAt(exp time.Duration) int64 {
+	return store.clock.Now().Add(exp).UnixNano() / int64(time.Millisecond)
+}
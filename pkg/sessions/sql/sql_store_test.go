@@ -0,0 +1,183 @@
+package sql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSessionStore(t *testing.T) {
+	if !tests.SQLiteAvailable() {
+		t.Skip("the sqlite3 driver requires cgo")
+	}
+
+	logger.SetOutput(GinkgoWriter)
+	logger.SetErrOutput(GinkgoWriter)
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SQL SessionStore")
+}
+
+var _ = Describe("SQL SessionStore Tests", func() {
+	var dir string
+	var ss sessionsapi.SessionStore
+
+	sqlStore := func() *SessionStore {
+		return ss.(*persistence.Manager).Store.(*SessionStore)
+	}
+
+	BeforeEach(func() {
+		ss = nil
+
+		var err error
+		dir, err = os.MkdirTemp("", "sql-session-store")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Release the database immediately after the test ends
+		if ss != nil {
+			Expect(sqlStore().Close()).To(Succeed())
+		}
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.SQLSessionStoreType
+			opts.SQL.Driver = "sqlite3"
+			opts.SQL.DSN = filepath.Join(dir, "sessions.db")
+
+			// Capture the session store so that we can mock its clock
+			var err error
+			ss, err = NewSQLSessionStore(opts, cookieOpts)
+			if err == nil {
+				sqlStore().clock.Set(time.Now())
+			}
+			return ss, err
+		},
+		func(d time.Duration) error {
+			return sqlStore().clock.Add(d)
+		},
+	)
+
+	Context("when deleting expired rows", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		countRows := func(table string) int {
+			var count int
+			Expect(store.DB.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count)).To(Succeed())
+			return count
+		}
+
+		BeforeEach(func() {
+			var err error
+			ss, err = NewSQLSessionStore(&options.SessionOptions{
+				SQL: options.SQLStoreOptions{
+					Driver: "sqlite3",
+					DSN:    filepath.Join(dir, "sessions.db"),
+				},
+			}, &options.Cookie{})
+			Expect(err).ToNot(HaveOccurred())
+			store = sqlStore()
+			store.clock.Set(time.Now())
+
+			Expect(store.Save(ctx, "short", []byte("short"), time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "long", []byte("long"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "short", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "long", time.Hour)).To(Succeed())
+			Expect(store.Lock("short").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("deletes only the expired rows", func() {
+			Expect(store.clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.DeleteExpired(ctx)).To(Succeed())
+
+			Expect(countRows(sessionsTable)).To(Equal(1))
+			Expect(countRows(indexesTable)).To(Equal(1))
+			Expect(countRows(locksTable)).To(Equal(0))
+
+			value, err := store.Load(ctx, "long")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("long")))
+		})
+
+		It("keeps unexpired rows", func() {
+			Expect(store.DeleteExpired(ctx)).To(Succeed())
+
+			Expect(countRows(sessionsTable)).To(Equal(2))
+			Expect(countRows(indexesTable)).To(Equal(2))
+			Expect(countRows(locksTable)).To(Equal(1))
+		})
+	})
+
+	Context("when the store is closed", func() {
+		It("stops the background cleanup", func() {
+			var err error
+			ss, err = NewSQLSessionStore(&options.SessionOptions{
+				SQL: options.SQLStoreOptions{
+					Driver: "sqlite3",
+					DSN:    filepath.Join(dir, "sessions.db"),
+				},
+			}, &options.Cookie{})
+			Expect(err).ToNot(HaveOccurred())
+			store := sqlStore()
+
+			done := make(chan struct{})
+			go func() {
+				store.cleanup(time.Millisecond)
+				close(done)
+			}()
+
+			Expect(store.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(store.Close()).To(Succeed())
+		})
+	})
+
+	Context("when two locks compete for a key", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			var err error
+			ss, err = NewSQLSessionStore(&options.SessionOptions{
+				SQL: options.SQLStoreOptions{
+					Driver: "sqlite3",
+					DSN:    filepath.Join(dir, "sessions.db"),
+				},
+			}, &options.Cookie{})
+			Expect(err).ToNot(HaveOccurred())
+			store = sqlStore()
+			store.clock.Set(time.Now())
+
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(Succeed())
+		})
+
+		It("does not obtain a held lock", func() {
+			other := store.Lock("key")
+			Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			Expect(store.Lock("key").Peek(ctx)).To(BeTrue())
+		})
+
+		It("takes over an expired lock", func() {
+			Expect(store.clock.Add(2 * time.Minute)).To(Succeed())
+
+			other := store.Lock("key")
+			Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(other.Peek(ctx)).To(BeTrue())
+		})
+	})
+})
@@ -18,6 +18,7 @@ type entry struct {
 // for mocking in tests
 type MockStore struct {
 	cache     map[string]entry
+	indexes   map[string]map[string]time.Duration
 	lockCache map[string]*MockLock
 	elapsed   time.Duration
 }
@@ -26,6 +27,7 @@ type MockStore struct {
 func NewMockStore() *MockStore {
 	return &MockStore{
 		cache:     map[string]entry{},
+		indexes:   map[string]map[string]time.Duration{},
 		lockCache: map[string]*MockLock{},
 		elapsed:   0 * time.Second,
 	}
@@ -56,6 +58,38 @@ func (s *MockStore) Clear(_ context.Context, key string) error {
 	return nil
 }
 
+// AddToIndex adds a key to an index, expiring along with the key
+func (s *MockStore) AddToIndex(_ context.Context, index string, key string, exp time.Duration) error {
+	if s.indexes[index] == nil {
+		s.indexes[index] = map[string]time.Duration{}
+	}
+	s.indexes[index][key] = s.elapsed + exp
+	return nil
+}
+
+// RemoveFromIndex removes a key from an index
+func (s *MockStore) RemoveFromIndex(_ context.Context, index string, key string) error {
+	delete(s.indexes[index], key)
+	return nil
+}
+
+// LoadIndex gets all unexpired keys in an index
+func (s *MockStore) LoadIndex(_ context.Context, index string) ([]string, error) {
+	keys := []string{}
+	for key, expiration := range s.indexes[index] {
+		if expiration > s.elapsed {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// ClearIndex deletes an index
+func (s *MockStore) ClearIndex(_ context.Context, index string) error {
+	delete(s.indexes, index)
+	return nil
+}
+
 func (s *MockStore) Lock(key string) sessions.Lock {
 	if s.lockCache[key] != nil {
 		return s.lockCache[key]
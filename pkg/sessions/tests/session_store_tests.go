@@ -1,7 +1,10 @@
 package tests
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -287,6 +290,136 @@ func PersistentSessionStoreInterfaceTests(in *testInput) {
 		})
 	})
 
+	Context("when sessions are indexed", func() {
+		const issuer = "https://issuer.example.com"
+		var firstRequest, secondRequest, otherUserRequest *http.Request
+		var indexedStore sessionsapi.IndexedSessionStore
+
+		saveSessionRequest := func(user, sessionID string) *http.Request {
+			session := *in.session
+			session.User = user
+			session.IDToken = unsignedIDToken(map[string]string{
+				"iss": issuer,
+				"sub": user,
+				"sid": sessionID,
+			})
+
+			resp := httptest.NewRecorder()
+			err := in.ss().Save(resp, httptest.NewRequest("GET", "http://example.com/", nil), &session)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, cookie := range resp.Result().Cookies() {
+				req.AddCookie(cookie)
+			}
+			return req
+		}
+
+		BeforeEach(func() {
+			var ok bool
+			indexedStore, ok = in.ss().(sessionsapi.IndexedSessionStore)
+			Expect(ok).To(BeTrue())
+
+			firstRequest = saveSessionRequest("john.doe", "first")
+			secondRequest = saveSessionRequest("john.doe", "second")
+			otherUserRequest = saveSessionRequest("jane.doe", "other")
+		})
+
+		It("clears only the sessions with the session ID", func() {
+			Expect(indexedStore.ClearBySessionID(context.Background(), issuer, "first")).To(Succeed())
+
+			_, err := in.ss().Load(firstRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(secondRequest)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("clears all sessions of the subject", func() {
+			Expect(indexedStore.ClearBySubject(context.Background(), issuer, "john.doe")).To(Succeed())
+
+			_, err := in.ss().Load(firstRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(secondRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(otherUserRequest)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("doesn't clear sessions of other issuers", func() {
+			Expect(indexedStore.ClearBySubject(context.Background(), "https://other.example.com", "john.doe")).To(Succeed())
+
+			_, err := in.ss().Load(firstRequest)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("clears all sessions of the user", func() {
+			Expect(indexedStore.ClearByUser(context.Background(), "john.doe")).To(Succeed())
+
+			_, err := in.ss().Load(firstRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(secondRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(otherUserRequest)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("lists all sessions without their tokens", func() {
+			storedSessions, err := indexedStore.ListSessions(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(storedSessions).To(HaveLen(3))
+
+			users := []string{}
+			for _, stored := range storedSessions {
+				Expect(stored.TicketID).To(HavePrefix(in.cookieOpts.Name + "-"))
+				Expect(stored.Session.Email).To(Equal(in.session.Email))
+				Expect(stored.Session.CreatedAt).ToNot(BeNil())
+				Expect(stored.Session.AccessToken).To(BeEmpty())
+				Expect(stored.Session.IDToken).To(BeEmpty())
+				Expect(stored.Session.RefreshToken).To(BeEmpty())
+				users = append(users, stored.Session.User)
+			}
+			Expect(users).To(ConsistOf("john.doe", "john.doe", "jane.doe"))
+		})
+
+		It("doesn't list sessions after they are cleared", func() {
+			Expect(in.ss().Clear(httptest.NewRecorder(), otherUserRequest)).To(Succeed())
+
+			storedSessions, err := indexedStore.ListSessions(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(storedSessions).To(HaveLen(2))
+			for _, stored := range storedSessions {
+				Expect(stored.Session.User).To(Equal("john.doe"))
+			}
+		})
+
+		It("clears a session by its ticket ID", func() {
+			storedSessions, err := indexedStore.ListSessions(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			for _, stored := range storedSessions {
+				if stored.Session.User == "jane.doe" {
+					Expect(indexedStore.ClearByTicketID(context.Background(), stored.TicketID)).To(Succeed())
+				}
+			}
+
+			_, err = in.ss().Load(otherUserRequest)
+			Expect(err).To(HaveOccurred())
+			_, err = in.ss().Load(firstRequest)
+			Expect(err).ToNot(HaveOccurred())
+
+			storedSessions, err = indexedStore.ListSessions(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(storedSessions).To(HaveLen(2))
+		})
+
+		It("doesn't clear keys that aren't ticket IDs", func() {
+			Expect(indexedStore.ClearByTicketID(context.Background(), in.cookieOpts.Name+"-sessions")).ToNot(Succeed())
+
+			storedSessions, err := indexedStore.ListSessions(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(storedSessions).To(HaveLen(3))
+		})
+	})
+
 	Context("when lock is applied", func() {
 		var loadedSession *sessionsapi.SessionState
 		BeforeEach(func() {
@@ -513,3 +646,11 @@ func LoadSessionTests(in *testInput) {
 
 	})
 }
+
+// unsignedIDToken builds a JWT with the given claims and no valid signature.
+// Session stores only read the claims of an ID Token, they never verify it.
+func unsignedIDToken(claims map[string]string) string {
+	payload, err := json.Marshal(claims)
+	Expect(err).ToNot(HaveOccurred())
+	return "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
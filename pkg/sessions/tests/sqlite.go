@@ -0,0 +1,14 @@
+package tests
+
+import "database/sql"
+
+// SQLiteAvailable reports whether the sqlite3 driver is registered, it is
+// only built into the tests when cgo is enabled
+func SQLiteAvailable() bool {
+	for _, driver := range sql.Drivers() {
+		if driver == "sqlite3" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build cgo
+
+package tests
+
+import (
+	// Register the embedded sqlite driver with database/sql. The driver
+	// requires cgo, tests using it are skipped when cgo is disabled.
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartServerSpan starts a server span for an incoming request, continuing
+// any trace propagated by the client, and returns the request with the
+// context of the span.
+func StartServerSpan(req *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, span := StartSpan(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+			semconv.ServerAddress(req.Host),
+			semconv.UserAgentOriginal(req.UserAgent()),
+		),
+	)
+	return req.WithContext(ctx), span
+}
+
+// EndServerSpan records the status code of the response to an incoming
+// request and ends the span.
+func EndServerSpan(span trace.Span, status int) {
+	// The status is OK when the handler did not write a response
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// StartClientSpan starts a client span for an outgoing request, and returns
+// a copy of the request with the context of the span, which is propagated to
+// the server in the traceparent header.
+func StartClientSpan(req *http.Request, attrs ...attribute.KeyValue) (*http.Request, trace.Span) {
+	ctx, span := StartSpan(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(spanURL(req.URL)),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+		trace.WithAttributes(attrs...),
+	)
+
+	req = req.Clone(ctx)
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// EndClientSpan records the status code of the response to an outgoing
+// request, or the error performing it, and ends the span.
+func EndClientSpan(span trace.Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if err == nil && resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	EndSpan(span, err)
+}
+
+// spanURL returns the URL of a request without any credentials or query, as
+// queries may contain tokens.
+func spanURL(u *url.URL) string {
+	path := u.EscapedPath()
+	// Proxied requests hold the original request URI in the opaque URL
+	if u.Opaque != "" {
+		path = strings.SplitN(u.Opaque, "?", 2)[0]
+	}
+	return u.Scheme + "://" + u.Host + path
+}
+
+// transport traces the requests of the wrapped http.RoundTripper.
+type transport struct {
+	next  http.RoundTripper
+	attrs []attribute.KeyValue
+}
+
+// NewTransport wraps the http.RoundTripper to trace each round trip in a
+// client span with the attributes, propagating the trace context to the
+// server.
+// The span ends once the response headers are received.
+func NewTransport(next http.RoundTripper, attrs ...attribute.KeyValue) http.RoundTripper {
+	return &transport{
+		next:  next,
+		attrs: attrs,
+	}
+}
+
+// RoundTrip performs the request in a client span.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, span := StartClientSpan(req, t.attrs...)
+	resp, err := t.next.RoundTrip(req)
+	EndClientSpan(span, resp, err)
+	return resp, err
+}
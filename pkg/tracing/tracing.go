@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation scope of all spans
+const tracerName = "github.com/oauth2-proxy/oauth2-proxy/v7"
+
+// defaultOTLPPath is the path of the traces endpoint of an OTLP/HTTP collector
+const defaultOTLPPath = "/v1/traces"
+
+const (
+	// RequestIDKey is the attribute of spans holding the request ID of the
+	// request scope
+	RequestIDKey = attribute.Key("oauth2_proxy.request_id")
+	// ProviderKey is the attribute of spans holding the name of a provider
+	ProviderKey = attribute.Key("oauth2_proxy.provider")
+	// UpstreamKey is the attribute of spans holding the ID of an upstream
+	UpstreamKey = attribute.Key("oauth2_proxy.upstream")
+)
+
+// ShutdownFunc flushes any spans which have not been exported yet and stops
+// the exporting of spans.
+type ShutdownFunc = func(context.Context) error
+
+// Configure sets up the global tracer provider to export spans for the
+// service to the OTLP/HTTP endpoint, sampling the ratio of new traces, and
+// propagates the trace context of requests in W3C traceparent headers.
+// When no endpoint is configured, tracing is disabled and the ShutdownFunc
+// does nothing.
+func Configure(endpoint, serviceName string, sampleRatio float64) (ShutdownFunc, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing OTLP endpoint: %v", err)
+	}
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	if endpointURL.Path == "" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithURLPath(defaultOTLPPath))
+	}
+
+	// The exporter connects lazily, so the context only applies to its setup
+	exporter, err := otlptracehttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP trace exporter: %v", err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Enabled reports whether spans are recorded by the global tracer provider, as
+// set up by Configure, so that tracing may be skipped when it is disabled.
+func Enabled() bool {
+	_, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	return ok
+}
+
+// StartSpan starts a span as a child of any span in the context, with the
+// request ID of any request scope in the context.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if scope, ok := ctx.Value(middlewareapi.RequestScopeKey).(*middlewareapi.RequestScope); ok && scope != nil {
+		opts = append(opts, trace.WithAttributes(RequestIDKey.String(scope.RequestID)))
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// EndSpan records the error, if any, as the status of the span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
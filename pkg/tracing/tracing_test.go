@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+var _ = Describe("Tracing Suite", func() {
+	var recorder *tracetest.SpanRecorder
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+
+	spanAttributes := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, attr := range span.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+		return attrs
+	}
+
+	Context("StartSpan", func() {
+		It("attaches the request ID of the request scope", func() {
+			req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "/", nil), &middlewareapi.RequestScope{
+				RequestID: "11111111-2222-4333-8444-555555555555",
+			})
+
+			_, span := StartSpan(req.Context(), "test")
+			EndSpan(span, nil)
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			Expect(spanAttributes(recorder.Ended()[0])).To(HaveKeyWithValue(RequestIDKey, attribute.StringValue("11111111-2222-4333-8444-555555555555")))
+		})
+
+		It("does not attach a request ID without a request scope", func() {
+			_, span := StartSpan(context.Background(), "test")
+			EndSpan(span, nil)
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			Expect(spanAttributes(recorder.Ended()[0])).ToNot(HaveKey(RequestIDKey))
+		})
+
+		It("records the error of the span", func() {
+			_, span := StartSpan(context.Background(), "test")
+			EndSpan(span, errors.New("redeem failed"))
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			Expect(recorder.Ended()[0].Status()).To(Equal(sdktrace.Status{Code: codes.Error, Description: "redeem failed"}))
+		})
+	})
+
+	Context("StartServerSpan", func() {
+		It("continues the trace propagated by the client", func() {
+			req := httptest.NewRequest("GET", "/foo", nil)
+			req.Header.Set("traceparent", testTraceparent)
+
+			req, span := StartServerSpan(req)
+			EndServerSpan(span, 0)
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			ended := recorder.Ended()[0]
+			Expect(ended.SpanKind()).To(Equal(trace.SpanKindServer))
+			Expect(ended.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(ended.Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+			Expect(spanAttributes(ended)).To(HaveKeyWithValue(attribute.Key("http.response.status_code"), attribute.IntValue(http.StatusOK)))
+			Expect(trace.SpanContextFromContext(req.Context())).To(Equal(ended.SpanContext()))
+		})
+	})
+
+	Context("NewTransport", func() {
+		It("traces the round trip and propagates the trace context", func() {
+			var receivedTraceparent string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				receivedTraceparent = req.Header.Get("traceparent")
+				rw.WriteHeader(http.StatusAccepted)
+			}))
+			defer server.Close()
+
+			ctx, parent := StartSpan(context.Background(), "parent")
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/foo?token=secret", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			client := &http.Client{Transport: NewTransport(http.DefaultTransport, UpstreamKey.String("upstream"))}
+			resp, err := client.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			parent.End()
+
+			Expect(req.Header).ToNot(HaveKey("Traceparent"))
+			Expect(recorder.Ended()).To(HaveLen(2))
+			ended := recorder.Ended()[0]
+			Expect(ended.SpanKind()).To(Equal(trace.SpanKindClient))
+			Expect(ended.Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+			Expect(receivedTraceparent).To(Equal("00-" + ended.SpanContext().TraceID().String() + "-" + ended.SpanContext().SpanID().String() + "-01"))
+
+			attrs := spanAttributes(ended)
+			Expect(attrs).To(HaveKeyWithValue(UpstreamKey, attribute.StringValue("upstream")))
+			Expect(attrs).To(HaveKeyWithValue(attribute.Key("url.full"), attribute.StringValue(server.URL+"/foo")))
+			Expect(attrs).To(HaveKeyWithValue(attribute.Key("http.response.status_code"), attribute.IntValue(http.StatusAccepted)))
+		})
+	})
+
+	Context("Configure", func() {
+		It("does nothing without an endpoint", func() {
+			shutdown, err := Configure("", "oauth2-proxy", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shutdown(context.Background())).To(Succeed())
+
+			_, span := StartSpan(context.Background(), "test")
+			span.End()
+			Expect(recorder.Ended()).To(HaveLen(1))
+		})
+
+		It("exports spans to the OTLP endpoint", func() {
+			var mu sync.Mutex
+			var paths []string
+			collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				paths = append(paths, req.URL.Path)
+			}))
+			defer collector.Close()
+
+			shutdown, err := Configure(collector.URL, "oauth2-proxy", 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, span := StartSpan(context.Background(), "test")
+			span.End()
+			Expect(shutdown(context.Background())).To(Succeed())
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(paths).To(Equal([]string{"/v1/traces"}))
+			Expect(recorder.Ended()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package upstream
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// AllowsUnauthenticated checks if the upstream allows requests with the
+// method without a valid session
+func AllowsUnauthenticated(upstream options.Upstream, method string) bool {
+	if !upstream.AllowUnauthenticated {
+		return false
+	}
+	if len(upstream.AllowedMethods) == 0 {
+		return true
+	}
+	for _, allowed := range upstream.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAuthorizationHandler only passes requests to the handler of an upstream
+// if their session satisfies the authorization rules of the upstream, or the
+// upstream allows the request without a session.
+// Other requests receive a 403 error page, an empty JSON object for API
+// requests, or a PERMISSION_DENIED status for gRPC calls.
+func newAuthorizationHandler(upstream options.Upstream, writer pagewriter.Writer, isAPIRequest func(*http.Request) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		scope := middlewareapi.GetRequestScope(req)
+		if AllowsUnauthenticated(upstream, req.Method) || isAuthorized(upstream.Authorization, scope.Session) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		var email string
+		if scope.Session != nil {
+			email = scope.Session.Email
+		}
+		logger.PrintAuthf(email, req, logger.AuthFailure, "Invalid authorization via the rules of upstream %q", upstream.ID)
+
+		if IsGRPCRequest(req) {
+			WriteGRPCStatus(rw, GRPCPermissionDenied, "the session failed the authorization rules of the upstream")
+			return
+		}
+		if upstream.API || (isAPIRequest != nil && isAPIRequest(req)) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusForbidden)
+			rw.Write([]byte("{}"))
+			return
+		}
+		writer.WriteErrorPage(rw, pagewriter.ErrorPageOpts{
+			Status:    http.StatusForbidden,
+			RequestID: scope.RequestID,
+			AppError:  "The session failed the authorization checks of the upstream",
+		})
+	})
+}
+
+// isAuthorized checks that the session satisfies every rule that is set.
+// Requests without a session never satisfy the rules.
+func isAuthorized(rules *options.UpstreamAuthorization, session *sessionsapi.SessionState) bool {
+	if session == nil {
+		return false
+	}
+
+	if len(rules.Groups) > 0 && !containsAny(rules.Groups, session.Groups) {
+		return false
+	}
+	if len(rules.EmailDomains) > 0 && !isEmailDomainAllowed(rules.EmailDomains, session.Email) {
+		return false
+	}
+	if len(rules.Emails) > 0 && !containsAny(rules.Emails, []string{session.Email}) {
+		return false
+	}
+	for _, matcher := range rules.Claims {
+		if !containsAny(matcher.Values, session.GetClaim(matcher.Claim)) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny checks if any of the values is allowed
+func containsAny(allowed []string, values []string) bool {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmailDomainAllowed checks if the domain of the email is one of the
+// allowed domains, or a subdomain of an allowed domain prefixed with a `.`
+func isEmailDomainAllowed(domains []string, email string) bool {
+	splitEmail := strings.Split(email, "@")
+	if len(splitEmail) != 2 {
+		return false
+	}
+	return util.IsEndpointAllowed(&url.URL{Host: splitEmail[1]}, domains)
+}
@@ -0,0 +1,199 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization Suite", func() {
+	session := &sessionsapi.SessionState{
+		Email:  "user@sub.example.com",
+		Groups: []string{"devs", "ops"},
+		Claims: map[string][]string{
+			"team": {"platform"},
+		},
+	}
+
+	type isAuthorizedTableInput struct {
+		rules    options.UpstreamAuthorization
+		session  *sessionsapi.SessionState
+		expected bool
+	}
+
+	DescribeTable("isAuthorized",
+		func(in isAuthorizedTableInput) {
+			Expect(isAuthorized(&in.rules, in.session)).To(Equal(in.expected))
+		},
+		Entry("with no rules", isAuthorizedTableInput{
+			session:  session,
+			expected: true,
+		}),
+		Entry("without a session", isAuthorizedTableInput{
+			session:  nil,
+			expected: false,
+		}),
+		Entry("with an allowed group", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{Groups: []string{"admins", "ops"}},
+			session:  session,
+			expected: true,
+		}),
+		Entry("without an allowed group", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{Groups: []string{"admins"}},
+			session:  session,
+			expected: false,
+		}),
+		Entry("with an allowed email domain", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{EmailDomains: []string{".example.com"}},
+			session:  session,
+			expected: true,
+		}),
+		Entry("without an allowed email domain", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{EmailDomains: []string{"example.com"}},
+			session:  session,
+			expected: false,
+		}),
+		Entry("with an allowed email", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{Emails: []string{"user@sub.example.com"}},
+			session:  session,
+			expected: true,
+		}),
+		Entry("without an allowed email", isAuthorizedTableInput{
+			rules:    options.UpstreamAuthorization{Emails: []string{"admin@example.com"}},
+			session:  session,
+			expected: false,
+		}),
+		Entry("with a matching claim", isAuthorizedTableInput{
+			rules: options.UpstreamAuthorization{Claims: []options.ClaimMatcher{
+				{Claim: "team", Values: []string{"platform"}},
+			}},
+			session:  session,
+			expected: true,
+		}),
+		Entry("with a claim that does not match", isAuthorizedTableInput{
+			rules: options.UpstreamAuthorization{Claims: []options.ClaimMatcher{
+				{Claim: "team", Values: []string{"platform"}},
+				{Claim: "department", Values: []string{"engineering"}},
+			}},
+			session:  session,
+			expected: false,
+		}),
+		Entry("with an allowed group but not an allowed email", isAuthorizedTableInput{
+			rules: options.UpstreamAuthorization{
+				Groups: []string{"ops"},
+				Emails: []string{"admin@example.com"},
+			},
+			session:  session,
+			expected: false,
+		}),
+	)
+
+	Context("with upstreams with authorization rules", func() {
+		var proxy Proxy
+
+		BeforeEach(func() {
+			ok := http.StatusOK
+			writer := &pagewriter.WriterFuncs{
+				ErrorPageFunc: func(rw http.ResponseWriter, opts pagewriter.ErrorPageOpts) {
+					rw.WriteHeader(opts.Status)
+					rw.Write([]byte("Error Page: " + opts.RequestID))
+				},
+			}
+
+			var err error
+			proxy, err = NewProxy(options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:         "admin",
+						Path:       "/admin/",
+						Static:     true,
+						StaticCode: &ok,
+						Authorization: &options.UpstreamAuthorization{
+							Groups: []string{"admins"},
+						},
+					},
+					{
+						ID:         "ops",
+						Path:       "/ops/",
+						Static:     true,
+						StaticCode: &ok,
+						Authorization: &options.UpstreamAuthorization{
+							Groups: []string{"ops"},
+						},
+					},
+					{
+						ID:         "root",
+						Path:       "/",
+						Static:     true,
+						StaticCode: &ok,
+					},
+				},
+			}, nil, writer, func(req *http.Request) bool {
+				return req.Header.Get("Accept") == "application/json"
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		type serveHTTPTableInput struct {
+			path               string
+			accept             string
+			contentType        string
+			expectedCode       int
+			expectedBody       string
+			expectedGRPCStatus string
+		}
+
+		DescribeTable("ServeHTTP",
+			func(in serveHTTPTableInput) {
+				req := httptest.NewRequest("GET", in.path, nil)
+				req.Header.Set("Accept", in.accept)
+				req.Header.Set("Content-Type", in.contentType)
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+					RequestID: "request-id",
+					Session:   session,
+				})
+
+				rw := httptest.NewRecorder()
+				proxy.ServeHTTP(rw, req)
+				Expect(rw.Code).To(Equal(in.expectedCode))
+				Expect(rw.Body.String()).To(Equal(in.expectedBody))
+				Expect(rw.Header().Get("Grpc-Status")).To(Equal(in.expectedGRPCStatus))
+			},
+			Entry("with an upstream without rules", serveHTTPTableInput{
+				path:         "/",
+				expectedCode: http.StatusOK,
+				expectedBody: "Authenticated",
+			}),
+			Entry("with an upstream with rules the session satisfies", serveHTTPTableInput{
+				path:         "/ops/dashboard",
+				expectedCode: http.StatusOK,
+				expectedBody: "Authenticated",
+			}),
+			Entry("with an upstream with rules the session fails", serveHTTPTableInput{
+				path:         "/admin/users",
+				expectedCode: http.StatusForbidden,
+				expectedBody: "Error Page: request-id",
+			}),
+			Entry("with an API request for an upstream with rules the session fails", serveHTTPTableInput{
+				path:         "/admin/users",
+				accept:       "application/json",
+				expectedCode: http.StatusForbidden,
+				expectedBody: "{}",
+			}),
+			Entry("with a gRPC call for an upstream with rules the session fails", serveHTTPTableInput{
+				path:               "/admin/users",
+				contentType:        "application/grpc",
+				expectedCode:       http.StatusOK,
+				expectedBody:       "",
+				expectedGRPCStatus: "7",
+			}),
+		)
+	})
+})
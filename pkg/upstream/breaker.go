@@ -0,0 +1,233 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// newCircuitBreaker creates a circuitBreaker for requests to the target,
+// registering its metrics with the registerer. There must only be one
+// circuitBreaker per upstream and target, as they share their metrics.
+func newCircuitBreaker(upstream string, target string, config options.CircuitBreaker, next http.RoundTripper, registerer prometheus.Registerer) *circuitBreaker {
+	b := &circuitBreaker{
+		next:         next,
+		upstream:     upstream,
+		target:       target,
+		threshold:    options.DefaultCircuitBreakerFailureThreshold,
+		openDuration: options.DefaultCircuitBreakerOpenDuration,
+		state:        breakerClosed,
+		stateGauge:   registerCircuitBreakerStateGauge(registerer),
+		transitions:  registerCircuitBreakerTransitionsCounter(registerer),
+	}
+	if config.FailureThreshold > 0 {
+		b.threshold = config.FailureThreshold
+	}
+	if config.OpenDuration != nil {
+		b.openDuration = config.OpenDuration.Duration()
+	}
+	b.updateState()
+	return b
+}
+
+// circuitBreaker stops requests to a server after consecutive failures.
+// Once open for the open duration, a single probe request is allowed through,
+// which closes the circuit breaker if it succeeds.
+type circuitBreaker struct {
+	next         http.RoundTripper
+	upstream     string
+	target       string
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openUntil time.Time
+	probing   bool
+
+	stateGauge  *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+	clock       clock.Clock
+}
+
+// RoundTrip sends the request when the circuit breaker allows it, and records
+// whether it failed.
+func (b *circuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !b.allow() {
+		return nil, errCircuitOpen
+	}
+
+	resp, err := b.next.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Requests cancelled by the client say nothing about the server
+		b.release()
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		b.record(false)
+	default:
+		b.record(true)
+	}
+	return resp, err
+}
+
+// allow returns whether a request may be sent to the server.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.clock.Now().Before(b.openUntil) {
+			return false
+		}
+		b.transition(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record records the result of a request, opening the circuit breaker when
+// the failure threshold is reached or the probe request failed, and closing
+// it when a request succeeded.
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.transition(breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.failures = 0
+		b.openUntil = b.clock.Now().Add(b.openDuration)
+		if b.state != breakerOpen {
+			b.transition(breakerOpen)
+		}
+	}
+}
+
+// release allows another probe request when a probe request was cancelled.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// transition moves the circuit breaker to the state, logging the transition
+// and updating the metrics. The caller must hold the lock of the breaker.
+func (b *circuitBreaker) transition(state string) {
+	logger.Printf("Circuit breaker for upstream %q server %q changed from %s to %s", b.upstream, b.target, b.state, state)
+	b.state = state
+	b.transitions.WithLabelValues(b.upstream, b.target, state).Inc()
+	b.updateState()
+}
+
+// updateState sets the state gauge of the circuit breaker.
+func (b *circuitBreaker) updateState() {
+	for _, s := range []string{breakerClosed, breakerOpen, breakerHalfOpen} {
+		value := 0.0
+		if s == b.state {
+			value = 1
+		}
+		b.stateGauge.WithLabelValues(b.upstream, b.target, s).Set(value)
+	}
+}
+
+// newCircuitOpenErrorHandler returns an error handler that responds with the
+// static response of the circuit breaker configuration to requests rejected
+// by an open circuit breaker. Other errors are passed to the errorHandler.
+func newCircuitOpenErrorHandler(config options.CircuitBreaker, errorHandler ProxyErrorHandler) ProxyErrorHandler {
+	return func(rw http.ResponseWriter, req *http.Request, err error) {
+		if errors.Is(err, errCircuitOpen) && config.StaticCode != nil {
+			rw.WriteHeader(*config.StaticCode)
+			if _, err := fmt.Fprint(rw, config.StaticBody); err != nil {
+				logger.Errorf("Error writing static response: %v", err)
+			}
+			return
+		}
+
+		if errorHandler != nil {
+			errorHandler(rw, req, err)
+			return
+		}
+		logger.Errorf("Error proxying to upstream server: %v", err)
+		rw.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// registerCircuitBreakerStateGauge registers 'oauth2_proxy_upstream_circuit_breaker_state'
+// This is 1 for the current state of each circuit breaker and 0 for the other
+// states
+func registerCircuitBreakerStateGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oauth2_proxy_upstream_circuit_breaker_state",
+			Help: "State of the circuit breakers of upstream servers.",
+		},
+		[]string{"upstream", "server", "state"},
+	)
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
+
+// registerCircuitBreakerTransitionsCounter registers 'oauth2_proxy_upstream_circuit_breaker_transitions_total'
+// This keeps a tally of the transitions of each circuit breaker bucketed by
+// the state transitioned to
+func registerCircuitBreakerTransitionsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_upstream_circuit_breaker_transitions_total",
+			Help: "Total number of state transitions of the circuit breakers of upstream servers.",
+		},
+		[]string{"upstream", "server", "state"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
@@ -0,0 +1,163 @@
+package upstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Circuit Breaker Suite", func() {
+	var breaker *circuitBreaker
+	var registry *prometheus.Registry
+	var code int
+	var attempts int
+
+	openDuration := options.Duration(time.Minute)
+
+	roundTrip := func() error {
+		req := httptest.NewRequest(http.MethodGet, "http://example.localhost/", nil)
+		_, err := breaker.RoundTrip(req)
+		return err
+	}
+
+	state := func(s string) float64 {
+		return testutil.ToFloat64(registerCircuitBreakerStateGauge(registry).WithLabelValues("breaker", "example.localhost", s))
+	}
+
+	transitions := func(s string) float64 {
+		return testutil.ToFloat64(registerCircuitBreakerTransitionsCounter(registry).WithLabelValues("breaker", "example.localhost", s))
+	}
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		code = http.StatusInternalServerError
+		attempts = 0
+
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if code == 0 {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		})
+		breaker = newCircuitBreaker("breaker", "example.localhost", options.CircuitBreaker{
+			FailureThreshold: 2,
+			OpenDuration:     &openDuration,
+		}, next, registry)
+		breaker.clock.Set(time.Now())
+	})
+
+	It("starts closed", func() {
+		Expect(state(breakerClosed)).To(Equal(1.0))
+		Expect(state(breakerOpen)).To(Equal(0.0))
+	})
+
+	It("opens after consecutive failures", func() {
+		Expect(roundTrip()).To(Succeed())
+		code = 0
+		Expect(roundTrip()).ToNot(Succeed())
+		Expect(attempts).To(Equal(2))
+
+		Expect(roundTrip()).To(MatchError(errCircuitOpen))
+		Expect(attempts).To(Equal(2))
+		Expect(state(breakerOpen)).To(Equal(1.0))
+		Expect(state(breakerClosed)).To(Equal(0.0))
+		Expect(transitions(breakerOpen)).To(Equal(1.0))
+	})
+
+	It("only counts consecutive failures", func() {
+		Expect(roundTrip()).To(Succeed())
+		code = http.StatusOK
+		Expect(roundTrip()).To(Succeed())
+		code = http.StatusBadGateway
+		Expect(roundTrip()).To(Succeed())
+		Expect(roundTrip()).To(Succeed())
+
+		Expect(attempts).To(Equal(4))
+		Expect(transitions(breakerOpen)).To(Equal(1.0))
+	})
+
+	Context("when open", func() {
+		BeforeEach(func() {
+			Expect(roundTrip()).To(Succeed())
+			Expect(roundTrip()).To(Succeed())
+			Expect(roundTrip()).To(MatchError(errCircuitOpen))
+			Expect(breaker.clock.Add(openDuration.Duration())).To(Succeed())
+		})
+
+		It("closes when the probe request succeeds", func() {
+			code = http.StatusOK
+			Expect(roundTrip()).To(Succeed())
+			Expect(roundTrip()).To(Succeed())
+
+			Expect(attempts).To(Equal(4))
+			Expect(state(breakerClosed)).To(Equal(1.0))
+			Expect(transitions(breakerHalfOpen)).To(Equal(1.0))
+			Expect(transitions(breakerClosed)).To(Equal(1.0))
+		})
+
+		It("opens again when the probe request fails", func() {
+			Expect(roundTrip()).To(Succeed())
+			Expect(roundTrip()).To(MatchError(errCircuitOpen))
+
+			Expect(attempts).To(Equal(3))
+			Expect(state(breakerOpen)).To(Equal(1.0))
+			Expect(transitions(breakerOpen)).To(Equal(2.0))
+		})
+
+		It("only allows a single probe request", func() {
+			Expect(breaker.allow()).To(BeTrue())
+			Expect(breaker.allow()).To(BeFalse())
+			Expect(state(breakerHalfOpen)).To(Equal(1.0))
+		})
+	})
+
+	Context("newCircuitOpenErrorHandler", func() {
+		staticCode := http.StatusServiceUnavailable
+		errorHandler := func(rw http.ResponseWriter, _ *http.Request, _ error) {
+			rw.WriteHeader(http.StatusBadGateway)
+			rw.Write([]byte("Proxy Error"))
+		}
+
+		It("writes the static response when the breaker is open", func() {
+			handler := newCircuitOpenErrorHandler(options.CircuitBreaker{
+				StaticCode: &staticCode,
+				StaticBody: "Unavailable",
+			}, errorHandler)
+
+			rw := httptest.NewRecorder()
+			handler(rw, httptest.NewRequest("", "/", nil), errCircuitOpen)
+			Expect(rw.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(rw.Body.String()).To(Equal("Unavailable"))
+		})
+
+		It("renders the error page for other errors", func() {
+			handler := newCircuitOpenErrorHandler(options.CircuitBreaker{
+				StaticCode: &staticCode,
+			}, errorHandler)
+
+			rw := httptest.NewRecorder()
+			handler(rw, httptest.NewRequest("", "/", nil), errors.New("connection refused"))
+			Expect(rw.Code).To(Equal(http.StatusBadGateway))
+			Expect(rw.Body.String()).To(Equal("Proxy Error"))
+		})
+
+		It("renders the error page without a static response", func() {
+			handler := newCircuitOpenErrorHandler(options.CircuitBreaker{}, errorHandler)
+
+			rw := httptest.NewRecorder()
+			handler(rw, httptest.NewRequest("", "/", nil), errCircuitOpen)
+			Expect(rw.Code).To(Equal(http.StatusBadGateway))
+			Expect(rw.Body.String()).To(Equal("Proxy Error"))
+		})
+	})
+})
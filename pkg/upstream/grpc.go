@@ -0,0 +1,70 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"golang.org/x/net/http2"
+)
+
+const (
+	h2cScheme  = "h2c"
+	grpcScheme = "grpc"
+
+	grpcContentType = "application/grpc"
+)
+
+// gRPC status codes returned to gRPC clients, as defined in
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	// GRPCPermissionDenied is the status of calls that are not authorized.
+	GRPCPermissionDenied = 7
+
+	// GRPCUnauthenticated is the status of calls without valid authentication.
+	GRPCUnauthenticated = 16
+)
+
+// IsGRPCRequest returns whether the request is a gRPC call.
+func IsGRPCRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), grpcContentType)
+}
+
+// WriteGRPCStatus responds to a gRPC call with the status code and message,
+// in a response without a body as gRPC clients expect instead of an HTTP
+// error status.
+func WriteGRPCStatus(rw http.ResponseWriter, code int, message string) {
+	rw.Header().Set("Content-Type", grpcContentType)
+	rw.Header().Set("Grpc-Status", strconv.Itoa(code))
+	rw.Header().Set("Grpc-Message", url.PathEscape(message))
+	rw.WriteHeader(http.StatusOK)
+}
+
+// isH2CTarget returns whether requests to the target are sent with HTTP/2
+// over cleartext.
+func isH2CTarget(target *url.URL) bool {
+	return target.Scheme == h2cScheme || target.Scheme == grpcScheme
+}
+
+// newH2CTransport creates a transport sending requests with HTTP/2 over
+// cleartext, as the servers of h2c and grpc upstreams expect.
+func newH2CTransport(upstream options.Upstream) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout: options.DefaultUpstreamTimeout,
+	}
+	if upstream.Timeout != nil {
+		dialer.Timeout = upstream.Timeout.Duration()
+	}
+
+	return &http2.Transport{
+		AllowHTTP: true,
+		// Dial without TLS, the transport only dials TLS by default
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		},
+	}
+}
@@ -0,0 +1,139 @@
+package upstream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ = Describe("gRPC Suite", func() {
+	Context("with an h2c upstream server", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				body, err := io.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+
+				rw.Header().Set("Trailer", "Grpc-Status")
+				rw.Header().Set("Content-Type", "application/grpc")
+				rw.Header().Set("X-Proto", req.Proto)
+				rw.WriteHeader(http.StatusOK)
+				rw.Write(body)
+				rw.Header().Set("Grpc-Status", "0")
+			})
+			server = httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		DescribeTable("proxies requests with HTTP/2 and preserves trailers",
+			func(scheme string) {
+				uri := strings.Replace(server.URL, "http", scheme, 1)
+				upstreams := options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "grpc-backend",
+							Path: "/",
+							URI:  uri,
+						},
+					},
+				}
+				proxy, err := NewProxy(upstreams, nil, &pagewriter.WriterFuncs{}, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				req := middlewareapi.AddRequestScope(
+					httptest.NewRequest(http.MethodPost, "http://example.localhost/service/Method", strings.NewReader("message")),
+					&middlewareapi.RequestScope{},
+				)
+				req.Header.Set("Content-Type", "application/grpc")
+				rw := httptest.NewRecorder()
+				proxy.ServeHTTP(rw, req)
+
+				resp := rw.Result()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get("X-Proto")).To(Equal("HTTP/2.0"))
+
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("message"))
+				Expect(resp.Trailer.Get("Grpc-Status")).To(Equal("0"))
+			},
+			Entry("with the h2c scheme", h2cScheme),
+			Entry("with the grpc scheme", grpcScheme),
+		)
+
+		It("proxies requests to load balanced backends with health checks", func() {
+			uri := strings.Replace(server.URL, "http", h2cScheme, 1)
+			interval := options.Duration(10 * time.Millisecond)
+			upstreams := options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "grpc-pool",
+						Path: "/",
+						URI:  uri,
+						URIs: []string{strings.Replace(server.URL, "http", grpcScheme, 1)},
+						LoadBalancer: &options.LoadBalancer{
+							HealthCheck: &options.HealthCheck{
+								Path:     "/healthz",
+								Interval: &interval,
+							},
+						},
+					},
+				},
+			}
+			proxy, err := NewProxy(upstreams, nil, &pagewriter.WriterFuncs{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			serve := func() int {
+				req := middlewareapi.AddRequestScope(
+					httptest.NewRequest(http.MethodPost, "http://example.localhost/service/Method", strings.NewReader("message")),
+					&middlewareapi.RequestScope{},
+				)
+				req.Header.Set("Content-Type", "application/grpc")
+				rw := httptest.NewRecorder()
+				proxy.ServeHTTP(rw, req)
+				return rw.Code
+			}
+			Consistently(serve, 100*time.Millisecond, 10*time.Millisecond).Should(Equal(http.StatusOK))
+		})
+	})
+
+	DescribeTable("IsGRPCRequest",
+		func(contentType string, expected bool) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Content-Type", contentType)
+			Expect(IsGRPCRequest(req)).To(Equal(expected))
+		},
+		Entry("with a gRPC call", "application/grpc", true),
+		Entry("with a gRPC call with a protobuf message", "application/grpc+proto", true),
+		Entry("with a JSON request", "application/json", false),
+		Entry("without a content type", "", false),
+	)
+
+	It("WriteGRPCStatus responds with the gRPC status", func() {
+		rw := httptest.NewRecorder()
+		WriteGRPCStatus(rw, GRPCUnauthenticated, "no valid authentication")
+
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header()).To(Equal(http.Header{
+			"Content-Type": []string{"application/grpc"},
+			"Grpc-Status":  []string{"16"},
+			"Grpc-Message": []string{"no%20valid%20authentication"},
+		}))
+		Expect(rw.Body.Len()).To(Equal(0))
+	})
+})
@@ -9,6 +9,8 @@ import (
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -39,29 +41,42 @@ var SignatureHeaders = []string{
 // newHTTPUpstreamProxy creates a new httpUpstreamProxy that can serve requests
 // to a single upstream host.
 func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, errorHandler ProxyErrorHandler) http.Handler {
-	// Set path to empty so that request paths start at the server root
-	u.Path = ""
+	setServerRootPath(u)
 
 	// Create a ReverseProxy
 	proxy := newReverseProxy(u, upstream, errorHandler)
 
 	// Set up a WebSocket proxy if required
+	// WebSockets upgrade HTTP/1.1 connections, so are not proxied to h2c upstreams
 	var wsProxy http.Handler
-	if upstream.ProxyWebSockets == nil || *upstream.ProxyWebSockets {
+	if (upstream.ProxyWebSockets == nil || *upstream.ProxyWebSockets) && !isH2CTarget(u) {
 		wsProxy = newWebSocketReverseProxy(u, upstream.InsecureSkipTLSVerify)
 	}
 
-	var auth hmacauth.HmacAuth
-	if sigData != nil {
-		auth = hmacauth.NewHmacAuth(sigData.Hash, []byte(sigData.Key), SignatureHeader, SignatureHeaders)
-	}
-
 	return &httpUpstreamProxy{
 		upstream:  upstream.ID,
 		handler:   proxy,
 		wsHandler: wsProxy,
-		auth:      auth,
+		auth:      newHmacAuth(sigData),
+	}
+}
+
+// setServerRootPath sets the path of the target to empty so that request paths
+// start at the server root.
+// The path of unix socket targets locates the socket, so is kept.
+func setServerRootPath(target *url.URL) {
+	if target.Scheme != unixScheme {
+		target.Path = ""
+	}
+}
+
+// newHmacAuth creates the HmacAuth signing requests to upstreams, or nil if
+// no signature data is configured.
+func newHmacAuth(sigData *options.SignatureData) hmacauth.HmacAuth {
+	if sigData == nil {
+		return nil
 	}
+	return hmacauth.NewHmacAuth(sigData.Hash, []byte(sigData.Key), SignatureHeader, SignatureHeaders)
 }
 
 // httpUpstreamProxy represents a single HTTP(S) upstream proxy
@@ -85,22 +100,40 @@ func (h *httpUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 		req.Header.Set("GAP-Auth", rw.Header().Get("GAP-Auth"))
 		h.auth.SignRequest(req)
 	}
-	if h.wsHandler != nil && strings.EqualFold(req.Header.Get("Connection"), "upgrade") && req.Header.Get("Upgrade") == "websocket" {
+	if h.wsHandler != nil && isWebSocketRequest(req) {
 		h.wsHandler.ServeHTTP(rw, req)
 	} else {
 		h.handler.ServeHTTP(rw, req)
 	}
 }
 
+// isWebSocketRequest returns whether the request upgrades to a websocket.
+func isWebSocketRequest(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Connection"), "upgrade") && req.Header.Get("Upgrade") == "websocket"
+}
+
 // newReverseProxy creates a new reverse proxy for proxying requests to upstream
 // servers based on the upstream configuration provided.
 // The proxy should render an error page if there are failures connecting to the
 // upstream server.
 func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler ProxyErrorHandler) http.Handler {
+	if isH2CTarget(target) {
+		return newH2CReverseProxy(target, upstream, errorHandler)
+	}
+
+	// Requests to unix socket upstreams are HTTP requests sent over the socket
+	var socket string
+	if target.Scheme == unixScheme {
+		target, socket = unixSocketTarget(target)
+	}
+
 	proxy := httputil.NewSingleHostReverseProxy(target)
 
 	// Inherit default transport options from Go's stdlib
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if socket != "" {
+		setUnixSocketDialer(transport, socket)
+	}
 
 	// Change default duration for waiting for an upstream response
 	if upstream.Timeout != nil {
@@ -120,13 +153,54 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 		transport.TLSClientConfig.InsecureSkipVerify = true
 	}
 
+	return configureReverseProxy(proxy, target, transport, upstream, errorHandler)
+}
+
+// newH2CReverseProxy creates a new reverse proxy for proxying requests to h2c
+// and grpc upstream servers with HTTP/2 over cleartext.
+// Responses are flushed immediately so that streams and trailers reach the
+// client as they are received.
+func newH2CReverseProxy(target *url.URL, upstream options.Upstream, errorHandler ProxyErrorHandler) http.Handler {
+	// The HTTP/2 transport sends cleartext requests for http URLs
+	httpTarget := *target
+	httpTarget.Scheme = httpScheme
+
+	proxy := httputil.NewSingleHostReverseProxy(&httpTarget)
+	proxy.FlushInterval = -1
+
+	return configureReverseProxy(proxy, &httpTarget, newH2CTransport(upstream), upstream, errorHandler)
+}
+
+// configureReverseProxy applies the configuration shared by all reverse
+// proxies to the proxy, and sets its transport.
+func configureReverseProxy(proxy *httputil.ReverseProxy, target *url.URL, transport http.RoundTripper, upstream options.Upstream, errorHandler ProxyErrorHandler) http.Handler {
 	// Ensure we always pass the original request path
 	setProxyDirector(proxy)
 
+	// Unix socket upstreams may serve requests under a path prefix
+	if target.Path != "" {
+		setProxyPathPrefix(proxy, target.Path)
+	}
+
 	if upstream.PassHostHeader != nil && !*upstream.PassHostHeader {
 		setProxyUpstreamHostHeader(proxy, target)
 	}
 
+	// Trace each round trip to the upstream, propagating the trace context, and
+	// retry idempotent requests and stop requests while the server is failing,
+	// wrapping the customized transport
+	roundTripper := transport
+	if tracing.Enabled() {
+		roundTripper = tracing.NewTransport(roundTripper, tracing.UpstreamKey.String(upstream.ID))
+	}
+	if upstream.Retry != nil {
+		roundTripper = newRetryTransport(upstream.ID, *upstream.Retry, roundTripper)
+	}
+	if upstream.CircuitBreaker != nil {
+		roundTripper = newCircuitBreaker(upstream.ID, target.Host, *upstream.CircuitBreaker, roundTripper, prometheus.DefaultRegisterer)
+		errorHandler = newCircuitOpenErrorHandler(*upstream.CircuitBreaker, errorHandler)
+	}
+
 	// Set the error handler so that upstream connection failures render the
 	// error page instead of sending a empty response
 	if errorHandler != nil {
@@ -134,7 +208,7 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 	}
 
 	// Apply the customized transport to our proxy before returning it
-	proxy.Transport = transport
+	proxy.Transport = roundTripper
 
 	return proxy
 }
@@ -164,10 +238,18 @@ func setProxyDirector(proxy *httputil.ReverseProxy) {
 
 // newWebSocketReverseProxy creates a new reverse proxy for proxying websocket connections.
 func newWebSocketReverseProxy(u *url.URL, skipTLSVerify bool) http.Handler {
+	var socket string
+	if u.Scheme == unixScheme {
+		u, socket = unixSocketTarget(u)
+	}
+
 	wsProxy := httputil.NewSingleHostReverseProxy(u)
 
 	// Inherit default transport options from Go's stdlib
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if socket != "" {
+		setUnixSocketDialer(transport, socket)
+	}
 
 	/* #nosec G402 */
 	if skipTLSVerify {
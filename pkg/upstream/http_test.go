@@ -15,9 +15,17 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/tracing"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"golang.org/x/net/websocket"
 )
 
@@ -349,6 +357,54 @@ var _ = Describe("HTTP Upstream Suite", func() {
 		Expect(req.Host).To(Equal(strings.TrimPrefix(serverAddr, "http://")))
 	})
 
+	Context("with tracing enabled", func() {
+		var recorder *tracetest.SpanRecorder
+
+		BeforeEach(func() {
+			recorder = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+			otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		})
+
+		It("traces the round trip and propagates the trace context to the upstream", func() {
+			upstream := options.Upstream{
+				ID:             "traced",
+				PassHostHeader: &truth,
+				FlushInterval:  &defaultFlushInterval,
+				Timeout:        &defaultTimeout,
+			}
+
+			u, err := url.Parse(serverAddr)
+			Expect(err).ToNot(HaveOccurred())
+			handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+
+			req := middlewareapi.AddRequestScope(httptest.NewRequest("GET", "http://example.localhost/foo", nil), &middlewareapi.RequestScope{
+				RequestID: "11111111-2222-4333-8444-555555555555",
+			})
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+
+			request := testHTTPRequest{}
+			Expect(json.Unmarshal(rw.Body.Bytes(), &request)).To(Succeed())
+
+			Expect(recorder.Ended()).To(HaveLen(1))
+			span := recorder.Ended()[0]
+			Expect(span.SpanKind()).To(Equal(trace.SpanKindClient))
+			Expect(span.Attributes()).To(ContainElements(
+				tracing.UpstreamKey.String("traced"),
+				tracing.RequestIDKey.String("11111111-2222-4333-8444-555555555555"),
+				attribute.Int("http.response.status_code", http.StatusOK),
+			))
+			Expect(request.Header.Get("traceparent")).To(Equal(fmt.Sprintf("00-%s-%s-01", span.SpanContext().TraceID(), span.SpanContext().SpanID())))
+		})
+	})
+
 	type newUpstreamTableInput struct {
 		proxyWebSockets bool
 		flushInterval   options.Duration
@@ -0,0 +1,456 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	backendHealthy   = "healthy"
+	backendUnhealthy = "unhealthy"
+	backendEjected   = "ejected"
+)
+
+var errNoAvailableBackends = errors.New("no healthy backends available")
+
+// newLoadBalancedUpstreamProxy creates a new httpUpstreamProxy that balances
+// requests between the servers of the targets.
+func newLoadBalancedUpstreamProxy(upstream options.Upstream, targets []*url.URL, sigData *options.SignatureData, errorHandler ProxyErrorHandler) http.Handler {
+	pool := newBackendPool(upstream, targets, errorHandler, prometheus.DefaultRegisterer)
+	if upstream.LoadBalancer != nil && upstream.LoadBalancer.HealthCheck != nil {
+		go pool.runHealthChecks(*upstream.LoadBalancer.HealthCheck, upstream)
+	}
+
+	return &httpUpstreamProxy{
+		upstream: upstream.ID,
+		handler:  pool,
+		auth:     newHmacAuth(sigData),
+	}
+}
+
+// backend is one of the servers of a load balanced upstream.
+type backend struct {
+	url       *url.URL
+	handler   http.Handler
+	wsHandler http.Handler
+
+	// connections is the number of requests in flight to the backend.
+	// It must be accessed atomically.
+	connections int64
+
+	mu           sync.Mutex
+	unhealthy    bool
+	ejectedUntil time.Time
+
+	stateGauge       *prometheus.GaugeVec
+	connectionsGauge prometheus.Gauge
+	upstream         string
+}
+
+// available returns whether the backend passes its health checks and is not
+// ejected. Ejections that have expired are cleared.
+func (b *backend) available(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.ejectedUntil.IsZero() && !now.Before(b.ejectedUntil) {
+		b.ejectedUntil = time.Time{}
+		b.updateState()
+	}
+	return !b.unhealthy && b.ejectedUntil.IsZero()
+}
+
+// eject stops requests to the backend until the given time.
+func (b *backend) eject(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ejectedUntil = until
+	b.updateState()
+}
+
+// setHealthy records the result of a health check of the backend and returns
+// whether the health of the backend changed.
+func (b *backend) setHealthy(healthy bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.unhealthy == !healthy {
+		return false
+	}
+	b.unhealthy = !healthy
+	b.updateState()
+	return true
+}
+
+// updateState sets the state gauge of the backend. The caller must hold the
+// lock of the backend.
+func (b *backend) updateState() {
+	state := backendHealthy
+	switch {
+	case b.unhealthy:
+		state = backendUnhealthy
+	case !b.ejectedUntil.IsZero():
+		state = backendEjected
+	}
+
+	for _, s := range []string{backendHealthy, backendUnhealthy, backendEjected} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		b.stateGauge.WithLabelValues(b.upstream, b.url.Host, s).Set(value)
+	}
+}
+
+// serve proxies the request to the backend while tracking the requests in
+// flight.
+func (b *backend) serve(rw http.ResponseWriter, req *http.Request) {
+	b.connectionsGauge.Set(float64(atomic.AddInt64(&b.connections, 1)))
+	defer func() {
+		b.connectionsGauge.Set(float64(atomic.AddInt64(&b.connections, -1)))
+	}()
+
+	if b.wsHandler != nil && isWebSocketRequest(req) {
+		b.wsHandler.ServeHTTP(rw, req)
+	} else {
+		b.handler.ServeHTTP(rw, req)
+	}
+}
+
+// backendPool balances requests between the backends of an upstream.
+type backendPool struct {
+	upstream         string
+	strategy         string
+	ejectionDuration time.Duration
+	backends         []*backend
+	errorHandler     ProxyErrorHandler
+
+	// next is the counter for the round robin strategy.
+	// It must be accessed atomically.
+	next uint64
+
+	clock clock.Clock
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// newBackendPool creates a backendPool with a backend for each of the
+// targets, registering the backend gauges with the registerer.
+func newBackendPool(upstream options.Upstream, targets []*url.URL, errorHandler ProxyErrorHandler, registerer prometheus.Registerer) *backendPool {
+	p := &backendPool{
+		upstream:         upstream.ID,
+		strategy:         options.RoundRobinStrategy,
+		ejectionDuration: options.DefaultEjectionDuration,
+		errorHandler:     errorHandler,
+		stop:             make(chan struct{}),
+	}
+	if lb := upstream.LoadBalancer; lb != nil {
+		if lb.Strategy != "" {
+			p.strategy = lb.Strategy
+		}
+		if lb.EjectionDuration != nil {
+			p.ejectionDuration = lb.EjectionDuration.Duration()
+		}
+	}
+
+	stateGauge := registerBackendStateGauge(registerer)
+	connectionsGauge := registerBackendConnectionsGauge(registerer)
+
+	for _, target := range targets {
+		// Set path to empty so that request paths start at the server root
+		target.Path = ""
+
+		b := &backend{
+			url:              target,
+			upstream:         upstream.ID,
+			stateGauge:       stateGauge,
+			connectionsGauge: connectionsGauge.WithLabelValues(upstream.ID, target.Host),
+		}
+		b.handler = newReverseProxy(target, upstream, p.backendErrorHandler(b))
+		if (upstream.ProxyWebSockets == nil || *upstream.ProxyWebSockets) && !isH2CTarget(target) {
+			b.wsHandler = newWebSocketReverseProxy(target, upstream.InsecureSkipTLSVerify)
+		}
+		b.updateState()
+		b.connectionsGauge.Set(0)
+
+		p.backends = append(p.backends, b)
+	}
+	return p
+}
+
+// ServeHTTP proxies the request to the backend chosen by the strategy of the
+// pool.
+func (p *backendPool) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b := p.choose(req)
+	if b == nil {
+		logger.Errorf("Error proxying to upstream %q: %v", p.upstream, errNoAvailableBackends)
+		p.handleError(rw, req, errNoAvailableBackends)
+		return
+	}
+	b.serve(rw, req)
+}
+
+// choose returns the backend for the request, or nil if no backend is
+// available.
+func (p *backendPool) choose(req *http.Request) *backend {
+	now := p.clock.Now()
+	available := make([]*backend, 0, len(p.backends))
+	for _, b := range p.backends {
+		if b.available(now) {
+			available = append(available, b)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch p.strategy {
+	case options.LeastConnectionsStrategy:
+		return leastConnections(available)
+	case options.UserHashStrategy:
+		if user := sessionUser(req); user != "" {
+			return highestUserHash(available, user)
+		}
+	}
+
+	next := atomic.AddUint64(&p.next, 1) - 1
+	return available[next%uint64(len(available))]
+}
+
+// backendErrorHandler returns an error handler for the reverse proxy of the
+// backend, ejecting the backend when a request to it fails.
+func (p *backendPool) backendErrorHandler(b *backend) ProxyErrorHandler {
+	return func(rw http.ResponseWriter, req *http.Request, err error) {
+		// Requests cancelled by the client say nothing about the backend, and
+		// backends with an open circuit breaker already receive no requests
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, errCircuitOpen) && p.ejectionDuration > 0 {
+			logger.Errorf("Ejecting backend %q of upstream %q for %s: %v", b.url.Host, p.upstream, p.ejectionDuration, err)
+			b.eject(p.clock.Now().Add(p.ejectionDuration))
+		}
+		p.handleError(rw, req, err)
+	}
+}
+
+// handleError renders the error with the errorHandler of the pool, or with a
+// plain bad gateway response when no errorHandler is set.
+func (p *backendPool) handleError(rw http.ResponseWriter, req *http.Request, err error) {
+	if p.errorHandler != nil {
+		p.errorHandler(rw, req, err)
+		return
+	}
+	rw.WriteHeader(http.StatusBadGateway)
+}
+
+// Close stops the health checks of the pool.
+// It is safe to call Close more than once.
+func (p *backendPool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+	return nil
+}
+
+// runHealthChecks checks the health of each backend of the pool at every
+// interval, until the pool is closed.
+func (p *backendPool) runHealthChecks(check options.HealthCheck, upstream options.Upstream) {
+	interval := options.DefaultHealthCheckInterval
+	if check.Interval != nil {
+		interval = check.Interval.Duration()
+	}
+	checker := newHealthChecker(check, upstream)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.checkHealth(checker)
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth checks the health of each backend of the pool once.
+func (p *backendPool) checkHealth(checker *healthChecker) {
+	for _, b := range p.backends {
+		err := checker.check(b.url)
+		if !b.setHealthy(err == nil) {
+			continue
+		}
+		if err != nil {
+			logger.Errorf("Backend %q of upstream %q is unhealthy: %v", b.url.Host, p.upstream, err)
+		} else {
+			logger.Printf("Backend %q of upstream %q is healthy", b.url.Host, p.upstream)
+		}
+	}
+}
+
+// healthChecker requests the health check path from the backends, with
+// HTTP/2 over cleartext for h2c and grpc backends.
+type healthChecker struct {
+	path      string
+	client    *http.Client
+	h2cClient *http.Client
+}
+
+// newHealthChecker creates a healthChecker for the health check of the
+// upstream.
+func newHealthChecker(check options.HealthCheck, upstream options.Upstream) *healthChecker {
+	timeout := options.DefaultHealthCheckTimeout
+	if check.Timeout != nil {
+		timeout = check.Timeout.Duration()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	/* #nosec G402 */
+	if upstream.InsecureSkipTLSVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	newClient := func(transport http.RoundTripper) *http.Client {
+		return &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// Redirects are healthy responses, there is no need to follow them
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	return &healthChecker{
+		path:      check.Path,
+		client:    newClient(transport),
+		h2cClient: newClient(newH2CTransport(upstream)),
+	}
+}
+
+// check requests the health check path from the backend, returning an error
+// unless the response has a 2xx or 3xx status.
+func (c *healthChecker) check(target *url.URL) error {
+	u := *target
+	u.Path = c.path
+
+	client := c.client
+	if isH2CTarget(target) {
+		// The HTTP/2 transport sends cleartext requests for http URLs
+		u.Scheme = httpScheme
+		client = c.h2cClient
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// leastConnections returns the backend with the fewest requests in flight,
+// preferring backends earlier in the list.
+func leastConnections(backends []*backend) *backend {
+	least := backends[0]
+	for _, b := range backends[1:] {
+		if atomic.LoadInt64(&b.connections) < atomic.LoadInt64(&least.connections) {
+			least = b
+		}
+	}
+	return least
+}
+
+// highestUserHash returns the backend with the highest hash of the user and
+// the backend. This is rendezvous hashing, so that only the users of a backend
+// move to other backends when it becomes unavailable.
+func highestUserHash(backends []*backend, user string) *backend {
+	var highest *backend
+	var highestHash uint64
+	for _, b := range backends {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(user))
+		_, _ = h.Write([]byte(b.url.Host))
+		if sum := h.Sum64(); highest == nil || sum > highestHash {
+			highest, highestHash = b, sum
+		}
+	}
+	return highest
+}
+
+// sessionUser returns the user of the session of the request, or its email
+// when the session has no user.
+func sessionUser(req *http.Request) string {
+	scope := middleware.GetRequestScope(req)
+	if scope == nil || scope.Session == nil {
+		return ""
+	}
+	if scope.Session.User != "" {
+		return scope.Session.User
+	}
+	return scope.Session.Email
+}
+
+// registerBackendStateGauge registers 'oauth2_proxy_upstream_backend_state'
+// This is 1 for the current state of each backend of load balanced upstreams
+// and 0 for the other states
+func registerBackendStateGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oauth2_proxy_upstream_backend_state",
+			Help: "State of the backends of load balanced upstreams.",
+		},
+		[]string{"upstream", "backend", "state"},
+	)
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
+
+// registerBackendConnectionsGauge registers 'oauth2_proxy_upstream_backend_connections'
+// This keeps the count of requests in flight to each backend of load balanced
+// upstreams
+func registerBackendConnectionsGauge(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "oauth2_proxy_upstream_backend_connections",
+			Help: "Current number of requests in flight to the backends of load balanced upstreams.",
+		},
+		[]string{"upstream", "backend"},
+	)
+
+	if err := registerer.Register(gauge); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return gauge
+}
@@ -0,0 +1,272 @@
+package upstream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ = Describe("Backend Pool Suite", func() {
+	var servers []*httptest.Server
+	var healthy map[string]bool
+	var registry *prometheus.Registry
+
+	newServer := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/healthz" && !healthy[name] {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			rw.Write([]byte(name))
+		}))
+		servers = append(servers, server)
+		return server
+	}
+
+	newPool := func(lb *options.LoadBalancer, uris ...string) *backendPool {
+		targets := []*url.URL{}
+		for _, uri := range uris {
+			u, err := url.Parse(uri)
+			Expect(err).ToNot(HaveOccurred())
+			targets = append(targets, u)
+		}
+		upstream := options.Upstream{
+			ID:           "pool",
+			LoadBalancer: lb,
+		}
+		errorHandler := func(rw http.ResponseWriter, _ *http.Request, _ error) {
+			rw.WriteHeader(http.StatusBadGateway)
+			rw.Write([]byte("Proxy Error"))
+		}
+		return newBackendPool(upstream, targets, errorHandler, registry)
+	}
+
+	serve := func(p *backendPool, session *sessionsapi.SessionState) (int, string) {
+		req := middlewareapi.AddRequestScope(
+			httptest.NewRequest("GET", "/", nil),
+			&middlewareapi.RequestScope{Session: session},
+		)
+		rw := httptest.NewRecorder()
+		p.ServeHTTP(rw, req)
+
+		body, err := io.ReadAll(rw.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return rw.Code, string(body)
+	}
+
+	stateGauge := func(backend, state string) float64 {
+		return testutil.ToFloat64(registerBackendStateGauge(registry).WithLabelValues("pool", backend, state))
+	}
+
+	BeforeEach(func() {
+		servers = nil
+		healthy = map[string]bool{"a": true, "b": true, "c": true}
+		registry = prometheus.NewRegistry()
+	})
+
+	AfterEach(func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	})
+
+	Context("with the roundRobin strategy", func() {
+		It("sends requests to each backend in turn", func() {
+			p := newPool(nil, newServer("a").URL, newServer("b").URL)
+
+			bodies := []string{}
+			for i := 0; i < 4; i++ {
+				code, body := serve(p, nil)
+				Expect(code).To(Equal(http.StatusOK))
+				bodies = append(bodies, body)
+			}
+			Expect(bodies).To(Equal([]string{"a", "b", "a", "b"}))
+		})
+	})
+
+	Context("with the leastConnections strategy", func() {
+		It("sends requests to the backend with the fewest requests in flight", func() {
+			p := newPool(&options.LoadBalancer{Strategy: options.LeastConnectionsStrategy},
+				newServer("a").URL, newServer("b").URL, newServer("c").URL)
+			p.backends[0].connections = 2
+			p.backends[1].connections = 1
+			p.backends[2].connections = 3
+
+			_, body := serve(p, nil)
+			Expect(body).To(Equal("b"))
+		})
+	})
+
+	Context("with the userHash strategy", func() {
+		It("sends the requests of a user to the same backend", func() {
+			p := newPool(&options.LoadBalancer{Strategy: options.UserHashStrategy},
+				newServer("a").URL, newServer("b").URL, newServer("c").URL)
+
+			users := map[string]string{}
+			for _, user := range []string{"alice", "bob", "carol", "dave"} {
+				_, body := serve(p, &sessionsapi.SessionState{User: user})
+				users[user] = body
+			}
+			for i := 0; i < 3; i++ {
+				for user, backend := range users {
+					_, body := serve(p, &sessionsapi.SessionState{User: user})
+					Expect(body).To(Equal(backend))
+				}
+			}
+		})
+
+		It("only moves the users of a backend when it becomes unavailable", func() {
+			p := newPool(&options.LoadBalancer{Strategy: options.UserHashStrategy},
+				newServer("a").URL, newServer("b").URL, newServer("c").URL)
+
+			users := map[string]string{}
+			for _, user := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+				_, body := serve(p, &sessionsapi.SessionState{User: user})
+				users[user] = body
+			}
+
+			p.backends[0].eject(p.clock.Now().Add(time.Minute))
+			for user, backend := range users {
+				_, body := serve(p, &sessionsapi.SessionState{User: user})
+				Expect(body).ToNot(Equal("a"))
+				if backend != "a" {
+					Expect(body).To(Equal(backend))
+				}
+			}
+		})
+	})
+
+	Context("with a backend failing to connect", func() {
+		var p *backendPool
+
+		BeforeEach(func() {
+			closed := newServer("a")
+			closed.Close()
+
+			p = newPool(&options.LoadBalancer{}, closed.URL, newServer("b").URL)
+			p.clock.Set(time.Now())
+		})
+
+		It("ejects the backend after the failed request", func() {
+			code, body := serve(p, nil)
+			Expect(code).To(Equal(http.StatusBadGateway))
+			Expect(body).To(Equal("Proxy Error"))
+
+			host := p.backends[0].url.Host
+			Expect(stateGauge(host, backendEjected)).To(Equal(1.0))
+			Expect(stateGauge(host, backendHealthy)).To(Equal(0.0))
+
+			for i := 0; i < 3; i++ {
+				code, body := serve(p, nil)
+				Expect(code).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("b"))
+			}
+		})
+
+		It("sends requests to the backend again after the ejection duration", func() {
+			serve(p, nil)
+			Expect(p.backends[0].available(p.clock.Now())).To(BeFalse())
+
+			Expect(p.clock.Add(options.DefaultEjectionDuration)).To(Succeed())
+			Expect(p.backends[0].available(p.clock.Now())).To(BeTrue())
+			Expect(stateGauge(p.backends[0].url.Host, backendHealthy)).To(Equal(1.0))
+		})
+	})
+
+	Context("with health checks", func() {
+		var p *backendPool
+		var checker *healthChecker
+
+		BeforeEach(func() {
+			p = newPool(&options.LoadBalancer{}, newServer("a").URL, newServer("b").URL)
+			checker = newHealthChecker(options.HealthCheck{Path: "/healthz"}, options.Upstream{})
+		})
+
+		It("stops requests to unhealthy backends until they are healthy", func() {
+			healthy["a"] = false
+			p.checkHealth(checker)
+
+			host := p.backends[0].url.Host
+			Expect(stateGauge(host, backendUnhealthy)).To(Equal(1.0))
+			for i := 0; i < 3; i++ {
+				_, body := serve(p, nil)
+				Expect(body).To(Equal("b"))
+			}
+
+			healthy["a"] = true
+			p.checkHealth(checker)
+
+			Expect(stateGauge(host, backendHealthy)).To(Equal(1.0))
+			bodies := []string{}
+			for i := 0; i < 2; i++ {
+				_, body := serve(p, nil)
+				bodies = append(bodies, body)
+			}
+			Expect(bodies).To(ConsistOf("a", "b"))
+		})
+
+		It("returns an error when no backend is healthy", func() {
+			healthy["a"] = false
+			healthy["b"] = false
+			p.checkHealth(checker)
+
+			code, body := serve(p, nil)
+			Expect(code).To(Equal(http.StatusBadGateway))
+			Expect(body).To(Equal("Proxy Error"))
+		})
+
+		It("stops the health checks when closed", func() {
+			interval := options.Duration(time.Millisecond)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.runHealthChecks(options.HealthCheck{Path: "/healthz", Interval: &interval}, options.Upstream{})
+			}()
+
+			Expect(p.Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(p.Close()).To(Succeed())
+		})
+	})
+
+	DescribeTable("health checks of h2c backends",
+		func(scheme string) {
+			server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.ProtoMajor != 2 || !healthy["a"] {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				rw.Write([]byte("a"))
+			}), &http2.Server{}))
+			servers = append(servers, server)
+
+			p := newPool(&options.LoadBalancer{}, strings.Replace(server.URL, "http", scheme, 1))
+			checker := newHealthChecker(options.HealthCheck{Path: "/healthz"}, options.Upstream{})
+			host := p.backends[0].url.Host
+
+			healthy["a"] = false
+			p.checkHealth(checker)
+			Expect(stateGauge(host, backendUnhealthy)).To(Equal(1.0))
+
+			healthy["a"] = true
+			p.checkHealth(checker)
+			Expect(stateGauge(host, backendHealthy)).To(Equal(1.0))
+		},
+		Entry("with the h2c scheme", h2cScheme),
+		Entry("with the grpc scheme", grpcScheme),
+	)
+})
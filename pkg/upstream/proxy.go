@@ -1,8 +1,9 @@
 package upstream
 
 import (
-	"context"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -14,46 +15,53 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 )
 
 // ProxyErrorHandler is a function that will be used to render error pages when
 // HTTP proxies fail to connect to upstream servers.
 type ProxyErrorHandler func(http.ResponseWriter, *http.Request, error)
 
+// Proxy serves requests directed to multiple upstreams.
+type Proxy interface {
+	http.Handler
+
+	// MatchUpstream returns the upstream that would serve the request, and
+	// false if no upstream matches the request.
+	MatchUpstream(req *http.Request) (options.Upstream, bool)
+}
+
 // NewProxy creates a new multiUpstreamProxy that can serve requests directed to
 // multiple upstreams.
-func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
+// The isAPIRequest func determines whether requests failing the authorization
+// rules of an upstream receive a JSON response rather than an error page.
+func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer, isAPIRequest func(*http.Request) bool) (Proxy, error) {
 	m := &multiUpstreamProxy{
-		serveMux: mux.NewRouter(),
+		serveMux:     mux.NewRouter(),
+		upstreams:    make(map[string]options.Upstream, len(upstreams.Upstreams)),
+		isAPIRequest: isAPIRequest,
 	}
 
 	if upstreams.ProxyRawPath {
 		m.serveMux.UseEncodedPath()
 	}
 
-	for _, upstream := range sortByPathLongest(upstreams.Upstreams) {
-		if upstream.Static {
-			if err := m.registerStaticResponseHandler(upstream, writer); err != nil {
-				return nil, fmt.Errorf("could not register static upstream %q: %v", upstream.ID, err)
-			}
-			continue
-		}
-
-		u, err := url.Parse(upstream.URI)
+	// Handlers are built once per upstream and shared by the routes of all
+	// of its hosts, so that the hosts share the backends, health checks and
+	// circuit breakers of the upstream.
+	handlers := make(map[string]http.Handler, len(upstreams.Upstreams))
+	for _, upstream := range upstreams.Upstreams {
+		handler, err := m.newUpstreamHandler(upstream, sigData, writer)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing URI for upstream %q: %w", upstream.ID, err)
+			return nil, err
 		}
-		switch u.Scheme {
-		case fileScheme:
-			if err := m.registerFileServer(upstream, u, writer); err != nil {
-				return nil, fmt.Errorf("could not register file upstream %q: %v", upstream.ID, err)
-			}
-		case httpScheme, httpsScheme:
-			if err := m.registerHTTPUpstreamProxy(upstream, u, sigData, writer); err != nil {
-				return nil, fmt.Errorf("could not register HTTP upstream %q: %v", upstream.ID, err)
-			}
-		default:
-			return nil, fmt.Errorf("unknown scheme for upstream %q: %q", upstream.ID, u.Scheme)
+		m.upstreams[upstream.ID] = upstream
+		handlers[upstream.ID] = handler
+	}
+
+	for _, upstream := range sortByPathLongest(routesByHost(upstreams.Upstreams)) {
+		if err := m.registerHandler(upstream, handlers[upstream.ID], writer); err != nil {
+			return nil, fmt.Errorf("could not register upstream %q: %v", upstream.ID, err)
 		}
 	}
 
@@ -64,7 +72,9 @@ func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData,
 // multiUpstreamProxy will serve requests directed to multiple upstream servers
 // registered in the serverMux.
 type multiUpstreamProxy struct {
-	serveMux *mux.Router
+	serveMux     *mux.Router
+	upstreams    map[string]options.Upstream
+	isAPIRequest func(*http.Request) bool
 }
 
 // ServerHTTP handles HTTP requests.
@@ -72,28 +82,71 @@ func (m *multiUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request
 	m.serveMux.ServeHTTP(rw, req)
 }
 
-// registerStaticResponseHandler registers a static response handler with at the given path.
-func (m *multiUpstreamProxy) registerStaticResponseHandler(upstream options.Upstream, writer pagewriter.Writer) error {
-	logger.Printf("mapping path %q => static response %d", upstream.Path, derefStaticCode(upstream.StaticCode))
-	return m.registerHandler(upstream, newStaticResponseHandler(upstream.ID, upstream.StaticCode), writer)
+// MatchUpstream returns the upstream registered for the request.
+// Routes are named by the ID of their upstream, so the trailing slash
+// redirect, which has no name, does not match any upstream.
+func (m *multiUpstreamProxy) MatchUpstream(req *http.Request) (options.Upstream, bool) {
+	match := &mux.RouteMatch{}
+	if !m.serveMux.Match(req, match) || match.Route == nil {
+		return options.Upstream{}, false
+	}
+	upstream, ok := m.upstreams[match.Route.GetName()]
+	return upstream, ok
 }
 
-// registerFileServer registers a new fileServer based on the configuration given.
-func (m *multiUpstreamProxy) registerFileServer(upstream options.Upstream, u *url.URL, writer pagewriter.Writer) error {
-	logger.Printf("mapping path %q => file system %q", upstream.Path, u.Path)
-	return m.registerHandler(upstream, newFileServer(upstream.ID, upstream.Path, u.Path), writer)
+// newUpstreamHandler builds the handler serving the requests to the upstream
+// based on the configuration given.
+func (m *multiUpstreamProxy) newUpstreamHandler(upstream options.Upstream, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
+	handler, err := newBackendHandler(upstream, sigData, writer)
+	if err != nil {
+		return nil, err
+	}
+
+	if upstream.Authorization != nil {
+		handler = newAuthorizationHandler(upstream, writer, m.isAPIRequest, handler)
+	}
+	return handler, nil
 }
 
-// registerHTTPUpstreamProxy registers a new httpUpstreamProxy based on the configuration given.
-func (m *multiUpstreamProxy) registerHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer) error {
-	logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
-	return m.registerHandler(upstream, newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), writer)
+// newBackendHandler builds the static response, file server or proxy handler
+// of the upstream.
+func newBackendHandler(upstream options.Upstream, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
+	if upstream.Static {
+		logger.Printf("mapping path %q => static response %d", upstream.Path, derefStaticCode(upstream.StaticCode))
+		return newStaticResponseHandler(upstream.ID, upstream.StaticCode), nil
+	}
+
+	u, err := url.Parse(upstream.URI)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URI for upstream %q: %w", upstream.ID, err)
+	}
+	switch u.Scheme {
+	case fileScheme:
+		logger.Printf("mapping path %q => file system %q", upstream.Path, u.Path)
+		return newFileServer(upstream.ID, upstream.Path, u.Path), nil
+	case httpScheme, httpsScheme, h2cScheme, grpcScheme:
+		if len(upstream.URIs) > 0 || upstream.LoadBalancer != nil {
+			targets, err := parseLoadBalancedTargets(u, upstream.URIs)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing URI for upstream %q: %w", upstream.ID, err)
+			}
+			logger.Printf("mapping path %q => load balanced upstream %q with %d backends", upstream.Path, upstream.URI, len(targets))
+			return newLoadBalancedUpstreamProxy(upstream, targets, sigData, writer.ProxyErrorHandler), nil
+		}
+		logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
+		return newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), nil
+	case unixScheme:
+		logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
+		return newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), nil
+	default:
+		return nil, fmt.Errorf("unknown scheme for upstream %q: %q", upstream.ID, u.Scheme)
+	}
 }
 
-// registerHandler ensures the given handler is regiestered with the serveMux.
+// registerHandler registers the handler of the upstream with the serveMux.
 func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler http.Handler, writer pagewriter.Writer) error {
 	if upstream.RewriteTarget == "" {
-		m.registerSimpleHandler(upstream.Path, handler)
+		m.registerSimpleHandler(upstream, handler)
 		return nil
 	}
 
@@ -102,11 +155,12 @@ func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler
 
 // registerSimpleHandler maintains the behaviour of the go standard serveMux
 // by ensuring any path with a trailing `/` matches all paths under that prefix.
-func (m *multiUpstreamProxy) registerSimpleHandler(path string, handler http.Handler) {
-	if strings.HasSuffix(path, "/") {
-		m.serveMux.PathPrefix(path).Handler(handler)
+func (m *multiUpstreamProxy) registerSimpleHandler(upstream options.Upstream, handler http.Handler) {
+	route := m.newRoute(upstream)
+	if strings.HasSuffix(upstream.Path, "/") {
+		route.PathPrefix(upstream.Path).Name(upstream.ID).Handler(handler)
 	} else {
-		m.serveMux.Path(path).Handler(handler)
+		route.Path(upstream.Path).Name(upstream.ID).Handler(handler)
 	}
 }
 
@@ -122,17 +176,68 @@ func (m *multiUpstreamProxy) registerRewriteHandler(upstream options.Upstream, h
 
 	rewrite := newRewritePath(rewriteRegExp, upstream.RewriteTarget, writer)
 	h := alice.New(rewrite).Then(handler)
-	m.serveMux.MatcherFunc(func(req *http.Request, match *mux.RouteMatch) bool {
+	m.newRoute(upstream).MatcherFunc(func(req *http.Request, match *mux.RouteMatch) bool {
 		return rewriteRegExp.MatchString(req.URL.Path)
-	}).Handler(h)
+	}).Name(upstream.ID).Handler(h)
 
 	return nil
 }
 
+// newRoute creates a new route in the serveMux that only matches requests to
+// the hosts of the upstream, or to any host if the upstream has no hosts.
+func (m *multiUpstreamProxy) newRoute(upstream options.Upstream) *mux.Route {
+	route := m.serveMux.NewRoute()
+	if len(upstream.Hosts) == 0 {
+		return route
+	}
+	return route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+		host := requestutil.GetRequestHost(req)
+		for _, pattern := range upstream.Hosts {
+			if matchesHost(pattern, host) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// matchesHost checks whether the host, ignoring any port, matches the
+// pattern. Patterns are either an exact host, or a wildcard such as
+// `*.example.com` matching any subdomain of `example.com`.
+func matchesHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if strings.HasPrefix(pattern, "*.") {
+		suffix := strings.TrimPrefix(pattern, "*")
+		return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+	}
+	return host == pattern
+}
+
+// parseLoadBalancedTargets returns the targets of a load balanced upstream,
+// the parsed URI followed by the further URIs.
+func parseLoadBalancedTargets(u *url.URL, uris []string) ([]*url.URL, error) {
+	targets := []*url.URL{u}
+	for _, uri := range uris {
+		target, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
 // registerTrailingSlashHandler creates a new matcher that will check if the
 // requested path would match if it had a trailing slash appended.
 // If the path matches with a trailing slash, we send back a redirect.
 // This allows us to be consistent with the built in go servemux implementation.
+// As routes match on the host of the request, the redirect only applies when
+// a route for the host of the request would match.
 func registerTrailingSlashHandler(serveMux *mux.Router) {
 	serveMux.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
 		if strings.HasSuffix(req.URL.Path, "/") {
@@ -143,7 +248,7 @@ func registerTrailingSlashHandler(serveMux *mux.Router) {
 		// If we pass through the match then the matched backed will be served
 		// instead of the redirect handler.
 		m := &mux.RouteMatch{}
-		slashReq := req.Clone(context.Background())
+		slashReq := req.Clone(req.Context())
 		slashReq.URL.Path += "/"
 		return serveMux.Match(slashReq, m)
 	}).Handler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
@@ -151,7 +256,41 @@ func registerTrailingSlashHandler(serveMux *mux.Router) {
 	}))
 }
 
-// sortByPathLongest ensures that the upstreams are sorted by longest path.
+// routesByHost splits the upstreams into one upstream per host, so that the
+// routes for each host can be sorted independently.
+// Upstreams without hosts match any host and are returned as they are.
+func routesByHost(upstreams []options.Upstream) []options.Upstream {
+	routes := []options.Upstream{}
+	for _, upstream := range upstreams {
+		if len(upstream.Hosts) == 0 {
+			routes = append(routes, upstream)
+			continue
+		}
+		for _, host := range upstream.Hosts {
+			route := upstream
+			route.Hosts = []string{host}
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// hostPriority orders the hosts of upstreams by how specific they are.
+// Exact hosts take precedence over wildcards, longer wildcards take precedence
+// over shorter wildcards, and any host is matched last.
+func hostPriority(upstream options.Upstream) int {
+	if len(upstream.Hosts) == 0 {
+		return 0
+	}
+	host := upstream.Hosts[0]
+	if strings.HasPrefix(host, "*.") {
+		return len(host)
+	}
+	return math.MaxInt32
+}
+
+// sortByPathLongest ensures that the upstreams are sorted by the most specific
+// host, and then by longest path.
 // If rewrites are involved, a rewrite takes precedence over a non-rewrite.
 // When two upstreams define rewrites, whichever has the longest path will take
 // precedence (note this is the input to the rewrite logic).
@@ -159,6 +298,10 @@ func registerTrailingSlashHandler(serveMux *mux.Router) {
 // This should maintain the sorting behaviour of the standard go serve mux.
 func sortByPathLongest(in []options.Upstream) []options.Upstream {
 	sort.Slice(in, func(i, j int) bool {
+		if iHost, jHost := hostPriority(in[i]), hostPriority(in[j]); iHost != jHost {
+			return iHost > jHost
+		}
+
 		iRW := in[i].RewriteTarget
 		jRW := in[j].RewriteTarget
 
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
+	"github.com/gorilla/mux"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
@@ -24,6 +26,40 @@ var _ = Describe("Proxy Suite", func() {
 	}
 
 	Context("multiUpstreamProxy", func() {
+		hostOK := http.StatusOK
+		hostAccepted := http.StatusAccepted
+		hostUpstreams := options.UpstreamConfig{
+			Upstreams: []options.Upstream{
+				{
+					ID:         "any-host",
+					Path:       "/api/",
+					Static:     true,
+					StaticCode: &hostOK,
+				},
+				{
+					ID:         "wildcard-host",
+					Path:       "/",
+					Hosts:      []string{"*.apps.example.com"},
+					Static:     true,
+					StaticCode: &hostOK,
+				},
+				{
+					ID:         "exact-host",
+					Path:       "/",
+					Hosts:      []string{"foo.apps.example.com"},
+					Static:     true,
+					StaticCode: &hostOK,
+				},
+				{
+					ID:         "exact-host-path",
+					Path:       "/api/",
+					Hosts:      []string{"foo.apps.example.com", "bar.example.com"},
+					Static:     true,
+					StaticCode: &hostAccepted,
+				},
+			},
+		}
+
 		DescribeTable("Proxy ServeHTTP",
 			func(in *proxyTableInput) {
 				sigData := &options.SignatureData{Hash: crypto.SHA256, Key: "secret"}
@@ -101,7 +137,7 @@ var _ = Describe("Proxy Suite", func() {
 					}
 				}
 
-				upstreamServer, err := NewProxy(upstreams, sigData, writer)
+				upstreamServer, err := NewProxy(upstreams, sigData, writer, nil)
 				Expect(err).ToNot(HaveOccurred())
 
 				req := middlewareapi.AddRequestScope(
@@ -112,6 +148,10 @@ var _ = Describe("Proxy Suite", func() {
 				// Don't mock the remote Address
 				req.RemoteAddr = ""
 
+				matched, matchedOK := upstreamServer.MatchUpstream(req)
+				Expect(matchedOK).To(Equal(in.upstream != ""))
+				Expect(matched.ID).To(Equal(in.upstream))
+
 				upstreamServer.ServeHTTP(rw, req)
 
 				scope := middlewareapi.GetRequestScope(req)
@@ -337,9 +377,154 @@ var _ = Describe("Proxy Suite", func() {
 				},
 				upstream: "",
 			}),
+			Entry("with a request to a host without a host upstream", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://example.localhost/api/",
+				response: testHTTPResponse{
+					code:   200,
+					header: map[string][]string{},
+					raw:    "Authenticated",
+				},
+				upstream: "any-host",
+			}),
+			Entry("with a request to a host matching a wildcard", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://bar.apps.example.com/api/",
+				response: testHTTPResponse{
+					code:   200,
+					header: map[string][]string{},
+					raw:    "Authenticated",
+				},
+				upstream: "wildcard-host",
+			}),
+			Entry("with a request to the apex of a wildcard", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://apps.example.com/api/",
+				response: testHTTPResponse{
+					code:   200,
+					header: map[string][]string{},
+					raw:    "Authenticated",
+				},
+				upstream: "any-host",
+			}),
+			Entry("with a request to an exact host also matching a wildcard", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://FOO.apps.example.com:8080/",
+				response: testHTTPResponse{
+					code:   200,
+					header: map[string][]string{},
+					raw:    "Authenticated",
+				},
+				upstream: "exact-host",
+			}),
+			Entry("with a request to the longest path of an exact host", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://foo.apps.example.com/api/foo",
+				response: testHTTPResponse{
+					code:   202,
+					header: map[string][]string{},
+					raw:    "Authenticated",
+				},
+				upstream: "exact-host-path",
+			}),
+			Entry("with a request to a host path, missing the trailing slash", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://bar.example.com/api",
+				response: testHTTPResponse{
+					code: 301,
+					header: map[string][]string{
+						contentType: {textHTMLUTF8},
+						"Location":  {"http://bar.example.com/api/"},
+					},
+					raw: "<a href=\"http://bar.example.com/api/\">Moved Permanently</a>.\n\n",
+				},
+			}),
+			Entry("with a request to a path only registered for other hosts", &proxyTableInput{
+				upstreams: hostUpstreams,
+				target:    "http://example.localhost/foo",
+				response: testHTTPResponse{
+					code: 404,
+					header: map[string][]string{
+						"X-Content-Type-Options": {"nosniff"},
+						contentType:              {textPlainUTF8},
+					},
+					raw: "404 page not found\n",
+				},
+				upstream: "",
+			}),
 		)
 	})
 
+	Context("with an upstream serving several hosts", func() {
+		It("shares one handler between the routes of its hosts", func() {
+			proxy, err := NewProxy(options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:    "load-balanced",
+						Path:  "/",
+						URI:   "http://backend-1.internal",
+						URIs:  []string{"http://backend-2.internal"},
+						Hosts: []string{"foo.example.com", "bar.example.com"},
+					},
+				},
+			}, nil, &pagewriter.WriterFuncs{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			handlers := []http.Handler{}
+			Expect(proxy.(*multiUpstreamProxy).serveMux.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+				if route.GetName() == "load-balanced" {
+					handlers = append(handlers, route.GetHandler())
+				}
+				return nil
+			})).To(Succeed())
+
+			Expect(handlers).To(HaveLen(2))
+			Expect(handlers[0]).To(BeIdenticalTo(handlers[1]))
+		})
+
+		It("shares one circuit breaker between its hosts", func() {
+			requests := 0
+			backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				requests++
+				rw.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer backend.Close()
+
+			unavailable := http.StatusServiceUnavailable
+			openDuration := options.Duration(time.Minute)
+			proxy, err := NewProxy(options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:    "breaker-hosts",
+						Path:  "/",
+						URI:   backend.URL,
+						Hosts: []string{"foo.example.com", "bar.example.com"},
+						CircuitBreaker: &options.CircuitBreaker{
+							FailureThreshold: 2,
+							OpenDuration:     &openDuration,
+							StaticCode:       &unavailable,
+						},
+					},
+				},
+			}, nil, &pagewriter.WriterFuncs{}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			serve := func(host string) int {
+				req := middlewareapi.AddRequestScope(httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil), &middlewareapi.RequestScope{})
+				rw := httptest.NewRecorder()
+				proxy.ServeHTTP(rw, req)
+				return rw.Code
+			}
+
+			Expect(serve("foo.example.com")).To(Equal(http.StatusInternalServerError))
+			Expect(serve("foo.example.com")).To(Equal(http.StatusInternalServerError))
+
+			// The failures through one host opened the breaker for the other
+			Expect(serve("bar.example.com")).To(Equal(http.StatusServiceUnavailable))
+			Expect(requests).To(Equal(2))
+		})
+	})
+
 	Context("sortByPathLongest", func() {
 		type sortByPathLongestTableInput struct {
 			input          []options.Upstream
@@ -368,6 +553,21 @@ var _ = Describe("Proxy Suite", func() {
 			RewriteTarget: "/$1",
 		}
 
+		exactHost := options.Upstream{
+			Path:  "/",
+			Hosts: []string{"foo.apps.example.com"},
+		}
+
+		longWildcardHost := options.Upstream{
+			Path:  "/",
+			Hosts: []string{"*.apps.example.com"},
+		}
+
+		shortWildcardHost := options.Upstream{
+			Path:  "/http/subpath/",
+			Hosts: []string{"*.example.com"},
+		}
+
 		DescribeTable("short sort into the correct order",
 			func(in sortByPathLongestTableInput) {
 				Expect(sortByPathLongest(in.input)).To(Equal(in.expectedOutput))
@@ -408,6 +608,10 @@ var _ = Describe("Proxy Suite", func() {
 				input:          []options.Upstream{shortPathWithRewrite, shortSubPathWithRewrite},
 				expectedOutput: []options.Upstream{shortSubPathWithRewrite, shortPathWithRewrite},
 			}),
+			Entry("with hosts registered", sortByPathLongestTableInput{
+				input:          []options.Upstream{httpSubPath, shortWildcardHost, shortPathWithRewrite, longWildcardHost, exactHost},
+				expectedOutput: []options.Upstream{exactHost, longWildcardHost, shortWildcardHost, shortPathWithRewrite, httpSubPath},
+			}),
 		)
 	})
 })
@@ -0,0 +1,121 @@
+package upstream
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// newRetryTransport wraps the transport so that requests with idempotent
+// methods are retried following the retry configuration.
+func newRetryTransport(upstream string, retry options.Retry, next http.RoundTripper) http.RoundTripper {
+	t := &retryTransport{
+		next:        next,
+		upstream:    upstream,
+		attempts:    options.DefaultRetryAttempts,
+		backoff:     options.DefaultRetryBackoff,
+		statusCodes: make(map[int]struct{}, len(retry.StatusCodes)),
+	}
+	if retry.Attempts > 0 {
+		t.attempts = retry.Attempts
+	}
+	if retry.Backoff != nil {
+		t.backoff = retry.Backoff.Duration()
+	}
+	for _, code := range retry.StatusCodes {
+		t.statusCodes[code] = struct{}{}
+	}
+	return t
+}
+
+// retryTransport retries requests with idempotent methods that fail to
+// connect, or that receive a retryable status code.
+type retryTransport struct {
+	next        http.RoundTripper
+	upstream    string
+	attempts    int
+	backoff     time.Duration
+	statusCodes map[int]struct{}
+}
+
+// RoundTrip sends the request, retrying it with an exponential backoff until
+// it succeeds or runs out of attempts. The response of the last attempt is
+// returned.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) || !canReplayBody(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.attempts || !t.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		if err != nil {
+			logger.Errorf("Retrying request to upstream %q after attempt %d: %v", t.upstream, attempt, err)
+		} else {
+			logger.Errorf("Retrying request to upstream %q after attempt %d: unexpected status %d", t.upstream, attempt, resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		if req, err = rewindBody(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable returns whether the result of an attempt should be retried.
+// Requests cancelled by the client are not retried.
+func (t *retryTransport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	_, ok := t.statusCodes[resp.StatusCode]
+	return ok
+}
+
+// isIdempotent returns whether requests with the method may be sent more than
+// once, as defined by RFC 7231.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// canReplayBody returns whether the body of the request can be sent again.
+func canReplayBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindBody returns a copy of the request with a new body, so that it can be
+// sent again.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	return retryReq, nil
+}
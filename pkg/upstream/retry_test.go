@@ -0,0 +1,148 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// roundTripperFunc allows a function to be used as an http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Retry Suite", func() {
+	type retryTableInput struct {
+		method           string
+		body             []byte
+		results          []int
+		expectedAttempts int
+		expectedCode     int
+		expectedErr      error
+	}
+
+	errConnect := errors.New("connection refused")
+	backoff := options.Duration(time.Millisecond)
+
+	DescribeTable("retryTransport RoundTrip",
+		func(in retryTableInput) {
+			attempts := 0
+			bodies := []string{}
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if req.Body != nil {
+					body, err := io.ReadAll(req.Body)
+					Expect(err).ToNot(HaveOccurred())
+					bodies = append(bodies, string(body))
+				}
+
+				code := in.results[attempts]
+				attempts++
+				if code == 0 {
+					return nil, errConnect
+				}
+				return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			})
+
+			transport := newRetryTransport("retry", options.Retry{
+				Backoff:     &backoff,
+				StatusCodes: []int{http.StatusServiceUnavailable},
+			}, next)
+
+			var body io.Reader
+			if in.body != nil {
+				body = bytes.NewReader(in.body)
+			}
+			req, err := http.NewRequest(in.method, "http://example.localhost/", body)
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := transport.RoundTrip(req)
+			Expect(attempts).To(Equal(in.expectedAttempts))
+			if in.expectedErr != nil {
+				Expect(err).To(MatchError(in.expectedErr))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(in.expectedCode))
+
+			for _, b := range bodies {
+				Expect(b).To(Equal(string(in.body)))
+			}
+		},
+		Entry("with a successful request", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{200},
+			expectedAttempts: 1,
+			expectedCode:     200,
+		}),
+		Entry("with a retryable status code", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{503, 200},
+			expectedAttempts: 2,
+			expectedCode:     200,
+		}),
+		Entry("with a status code that is not retryable", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{500, 200},
+			expectedAttempts: 1,
+			expectedCode:     500,
+		}),
+		Entry("with a connection error", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{0, 0, 200},
+			expectedAttempts: 3,
+			expectedCode:     200,
+		}),
+		Entry("when the attempts run out", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{503, 0, 503, 200},
+			expectedAttempts: 3,
+			expectedCode:     503,
+		}),
+		Entry("when the attempts run out with a connection error", retryTableInput{
+			method:           http.MethodGet,
+			results:          []int{503, 503, 0, 200},
+			expectedAttempts: 3,
+			expectedErr:      errConnect,
+		}),
+		Entry("with an idempotent request with a body", retryTableInput{
+			method:           http.MethodPut,
+			body:             []byte("body"),
+			results:          []int{0, 200},
+			expectedAttempts: 2,
+			expectedCode:     200,
+		}),
+		Entry("with a request that is not idempotent", retryTableInput{
+			method:           http.MethodPost,
+			body:             []byte("body"),
+			results:          []int{0, 200},
+			expectedAttempts: 1,
+			expectedErr:      errConnect,
+		}),
+	)
+
+	It("does not retry requests cancelled by the client", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts := 0
+		next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			cancel()
+			return nil, context.Canceled
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.localhost/", nil).WithContext(ctx)
+		_, err := newRetryTransport("retry", options.Retry{Backoff: &backoff}, next).RoundTrip(req)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(attempts).To(Equal(1))
+	})
+})
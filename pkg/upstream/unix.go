@@ -0,0 +1,73 @@
+package upstream
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	unixScheme = "unix"
+
+	// unixHost is the host of requests to unix socket upstreams, sent as the
+	// host header when the request host header is not passed.
+	unixHost = "localhost"
+)
+
+// splitUnixSocketPath splits the path of a unix socket URI into the path of
+// the socket and a path prefix for requests to the socket.
+// The socket path ends with the first path segment ending in `.sock`, eg.
+// `/var/run/app.sock/api` is the socket `/var/run/app.sock` with the prefix
+// `/api`. Paths without a `.sock` segment are the path of the socket.
+func splitUnixSocketPath(path string) (string, string) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasSuffix(segment, ".sock") {
+			socket := strings.Join(segments[:i+1], "/")
+			prefix := strings.TrimSuffix(strings.Join(segments[i+1:], "/"), "/")
+			if prefix != "" {
+				prefix = "/" + prefix
+			}
+			return socket, prefix
+		}
+	}
+	return path, ""
+}
+
+// unixSocketTarget returns the HTTP target of requests to the unix socket URI,
+// with the path prefix of the URI, and the path of the socket to dial.
+func unixSocketTarget(u *url.URL) (*url.URL, string) {
+	socket, prefix := splitUnixSocketPath(u.Path)
+	return &url.URL{
+		Scheme: httpScheme,
+		Host:   unixHost,
+		Path:   prefix,
+	}, socket
+}
+
+// setUnixSocketDialer sets the transport to dial the unix socket for every
+// request, whatever the host of the request.
+func setUnixSocketDialer(transport *http.Transport, socket string) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
+
+// setProxyPathPrefix sets the proxy.Director so that request URIs are prefixed
+// with the path prefix of the upstream.
+// This must be set after setProxyDirector, which sets the request URI.
+func setProxyPathPrefix(proxy *httputil.ReverseProxy, prefix string) {
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.URL.Opaque = prefix + req.URL.Opaque
+	}
+}
@@ -0,0 +1,142 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+)
+
+var _ = Describe("Unix Socket Suite", func() {
+	truth := true
+
+	DescribeTable("splitUnixSocketPath",
+		func(socketPath, expectedSocket, expectedPrefix string) {
+			socket, prefix := splitUnixSocketPath(socketPath)
+			Expect(socket).To(Equal(expectedSocket))
+			Expect(prefix).To(Equal(expectedPrefix))
+		},
+		Entry("with a socket", "/var/run/app.sock", "/var/run/app.sock", ""),
+		Entry("with a socket and a path", "/var/run/app.sock/api/v1", "/var/run/app.sock", "/api/v1"),
+		Entry("with a socket and a trailing slash", "/var/run/app.sock/api/", "/var/run/app.sock", "/api"),
+		Entry("with a socket without the .sock suffix", "/var/run/app", "/var/run/app", ""),
+	)
+
+	Context("with a unix socket upstream server", func() {
+		var dir string
+		var socket string
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "oauth2-proxy-unix")
+			Expect(err).ToNot(HaveOccurred())
+
+			socket = path.Join(dir, "app.sock")
+			listener, err = net.Listen("unix", socket)
+			Expect(err).ToNot(HaveOccurred())
+			go func() {
+				_ = http.Serve(listener, &testHTTPUpstream{})
+			}()
+		})
+
+		AfterEach(func() {
+			Expect(listener.Close()).To(Succeed())
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		type unixSocketTableInput struct {
+			pathSuffix     string
+			passHostHeader bool
+			expectedURI    string
+			expectedHost   string
+		}
+
+		DescribeTable("proxies requests over the socket",
+			func(in unixSocketTableInput) {
+				u, err := url.Parse(fmt.Sprintf("unix://%s%s", socket, in.pathSuffix))
+				Expect(err).ToNot(HaveOccurred())
+
+				upstream := options.Upstream{
+					ID:             "unix",
+					PassHostHeader: &in.passHostHeader,
+				}
+				handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+
+				req := middlewareapi.AddRequestScope(
+					httptest.NewRequest(http.MethodGet, "/foo?bar=baz", nil),
+					&middlewareapi.RequestScope{},
+				)
+				req.Host = "example.localhost"
+				rw := httptest.NewRecorder()
+				handler.ServeHTTP(rw, req)
+				Expect(rw.Code).To(Equal(http.StatusOK))
+
+				body, err := io.ReadAll(rw.Body)
+				Expect(err).ToNot(HaveOccurred())
+				request := testHTTPRequest{}
+				Expect(json.Unmarshal(body, &request)).To(Succeed())
+				Expect(request.RequestURI).To(Equal(in.expectedURI))
+				Expect(request.Host).To(Equal(in.expectedHost))
+			},
+			Entry("passing the host header", unixSocketTableInput{
+				passHostHeader: true,
+				expectedURI:    "/foo?bar=baz",
+				expectedHost:   "example.localhost",
+			}),
+			Entry("without passing the host header", unixSocketTableInput{
+				passHostHeader: false,
+				expectedURI:    "/foo?bar=baz",
+				expectedHost:   unixHost,
+			}),
+			Entry("with a path prefix", unixSocketTableInput{
+				pathSuffix:     "/api",
+				passHostHeader: true,
+				expectedURI:    "/api/foo?bar=baz",
+				expectedHost:   "example.localhost",
+			}),
+		)
+
+		It("will proxy websockets", func() {
+			u, err := url.Parse(fmt.Sprintf("unix://%s", socket))
+			Expect(err).ToNot(HaveOccurred())
+
+			upstream := options.Upstream{
+				ID:              "unix",
+				PassHostHeader:  &truth,
+				ProxyWebSockets: &truth,
+			}
+			handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+			proxyServer := httptest.NewServer(middleware.NewScope(false, "X-Request-Id")(handler))
+			defer proxyServer.Close()
+
+			origin := "http://example.localhost"
+			message := "Hello, world!"
+
+			ws, err := websocket.Dial(fmt.Sprintf("ws://%s/", proxyServer.Listener.Addr().String()), "", origin)
+			Expect(err).ToNot(HaveOccurred())
+			defer ws.Close()
+
+			Expect(websocket.Message.Send(ws, []byte(message))).To(Succeed())
+			var response testWebSocketResponse
+			Expect(websocket.JSON.Receive(ws, &response)).To(Succeed())
+			Expect(response).To(Equal(testWebSocketResponse{
+				Message: message,
+				Origin:  origin,
+			}))
+		})
+	})
+})
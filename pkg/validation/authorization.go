@@ -0,0 +1,30 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+func validateExternalAuthz(o options.ExternalAuthzOptions) []string {
+	if o.URL == "" {
+		return []string{}
+	}
+
+	msgs := []string{}
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to parse authz_url %q: %v", o.URL, err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		msgs = append(msgs, fmt.Sprintf("authz_url %q must be an absolute http or https URL", o.URL))
+	}
+
+	if o.Timeout < 0 {
+		msgs = append(msgs, "authz_timeout must not be negative")
+	}
+	if o.CacheTTL < 0 {
+		msgs = append(msgs, "authz_cache_ttl must not be negative")
+	}
+	return msgs
+}
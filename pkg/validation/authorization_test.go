@@ -0,0 +1,60 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("External Authorization", func() {
+	type externalAuthzTableInput struct {
+		opts       options.ExternalAuthzOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateExternalAuthz",
+		func(o *externalAuthzTableInput) {
+			Expect(validateExternalAuthz(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("a disabled authorizer is skipped", &externalAuthzTableInput{
+			opts: options.ExternalAuthzOptions{
+				Timeout: -time.Second,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a valid URL", &externalAuthzTableInput{
+			opts: options.ExternalAuthzOptions{
+				URL:      "http://opa:8181/v1/data/oauth2_proxy/authz",
+				Timeout:  time.Second,
+				CacheTTL: time.Minute,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a relative URL", &externalAuthzTableInput{
+			opts: options.ExternalAuthzOptions{
+				URL: "/v1/data/oauth2_proxy/authz",
+			},
+			errStrings: []string{"authz_url \"/v1/data/oauth2_proxy/authz\" must be an absolute http or https URL"},
+		}),
+		Entry("with an invalid URL", &externalAuthzTableInput{
+			opts: options.ExternalAuthzOptions{
+				URL: "http://opa:port",
+			},
+			errStrings: []string{"unable to parse authz_url \"http://opa:port\": parse \"http://opa:port\": invalid port \":port\" after host"},
+		}),
+		Entry("with negative durations", &externalAuthzTableInput{
+			opts: options.ExternalAuthzOptions{
+				URL:      "https://opa",
+				Timeout:  -time.Second,
+				CacheTTL: -time.Second,
+			},
+			errStrings: []string{
+				"authz_timeout must not be negative",
+				"authz_cache_ttl must not be negative",
+			},
+		}),
+	)
+})
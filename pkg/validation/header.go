@@ -2,21 +2,45 @@ package validation
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 )
 
-func validateHeaders(headers []options.Header) []string {
+func validateHeaders(headers []options.Header, upstreams options.UpstreamConfig) []string {
 	msgs := []string{}
 	names := make(map[string]struct{})
 
+	// Token exchange values must reference an upstream with a token exchange
+	tokenExchanges := make(map[string]struct{})
+	for _, upstream := range upstreams.Upstreams {
+		if upstream.TokenExchange != nil {
+			tokenExchanges[upstream.ID] = struct{}{}
+		}
+	}
+
+	for _, header := range headers {
+		msgs = append(msgs, validateHeader(header, names, tokenExchanges)...)
+	}
+	return msgs
+}
+
+// validateResponseHeaders checks that exchanged tokens are never injected
+// into responses, they must only be sent to the upstream they were issued for
+func validateResponseHeaders(headers []options.Header) []string {
+	msgs := []string{}
 	for _, header := range headers {
-		msgs = append(msgs, validateHeader(header, names)...)
+		for _, value := range header.Values {
+			if value.TokenExchange != nil {
+				msgs = append(msgs, fmt.Sprintf("invalid header %q: tokenExchange values can only be injected into requests", header.Name))
+			}
+		}
 	}
 	return msgs
 }
 
-func validateHeader(header options.Header, names map[string]struct{}) []string {
+func validateHeader(header options.Header, names map[string]struct{}, tokenExchanges map[string]struct{}) []string {
 	msgs := []string{}
 
 	if header.Name == "" {
@@ -31,21 +55,27 @@ func validateHeader(header options.Header, names map[string]struct{}) []string {
 	for _, value := range header.Values {
 		msgs = append(msgs,
 			prefixValues(fmt.Sprintf("invalid header %q: invalid values: ", header.Name),
-				validateHeaderValue(header.Name, value)...,
+				validateHeaderValue(header.Name, value, tokenExchanges)...,
 			)...,
 		)
 	}
 	return msgs
 }
 
-func validateHeaderValue(name string, value options.HeaderValue) []string {
+func validateHeaderValue(name string, value options.HeaderValue, tokenExchanges map[string]struct{}) []string {
 	switch {
-	case value.SecretSource != nil && value.ClaimSource == nil:
+	case !header.HasSingleSource(value):
+		return []string{"header value has multiple entries: only one entry per value is allowed"}
+	case value.SecretSource != nil:
 		return []string{validateSecretSource(*value.SecretSource)}
-	case value.SecretSource == nil && value.ClaimSource != nil:
+	case value.ClaimSource != nil:
 		return validateHeaderValueClaimSource(*value.ClaimSource)
+	case value.TokenExchange != nil:
+		return validateHeaderValueTokenExchangeSource(*value.TokenExchange, tokenExchanges)
+	case value.SignedJWT != nil:
+		return validateHeaderValueSignedJWTSource(*value.SignedJWT)
 	default:
-		return []string{"header value has multiple entries: only one entry per value is allowed"}
+		return validateHeaderValueTemplate(value.Template)
 	}
 }
 
@@ -61,3 +91,59 @@ func validateHeaderValueClaimSource(claim options.ClaimSource) []string {
 	}
 	return msgs
 }
+
+func validateHeaderValueTokenExchangeSource(source options.TokenExchangeSource, tokenExchanges map[string]struct{}) []string {
+	if source.Upstream == "" {
+		return []string{"tokenExchange upstream should not be empty"}
+	}
+	if _, ok := tokenExchanges[source.Upstream]; !ok {
+		return []string{fmt.Sprintf("tokenExchange upstream %q does not exist or has no tokenExchange", source.Upstream)}
+	}
+	return []string{}
+}
+
+func validateHeaderValueSignedJWTSource(source options.SignedJWTSource) []string {
+	msgs := []string{}
+	if source.Expiry != nil && source.Expiry.Duration() <= 0 {
+		msgs = append(msgs, "signedJWT expiry must be greater than 0")
+	}
+	for _, claim := range source.Claims {
+		if claim == "" {
+			msgs = append(msgs, "signedJWT claims should not be empty")
+		}
+	}
+	return msgs
+}
+
+func validateHeaderValueTemplate(text string) []string {
+	if _, err := header.ParseTemplate(text); err != nil {
+		return []string{fmt.Sprintf("invalid template: %v", err)}
+	}
+	return []string{}
+}
+
+// validateUpstreamJWTKey checks that the key signing the JWTs of signed JWT
+// header values is configured when they are used, and can be loaded
+func validateUpstreamJWTKey(o *options.Options) []string {
+	if o.UpstreamJWTKeyFile == "" {
+		for _, headers := range [][]options.Header{o.InjectRequestHeaders, o.InjectResponseHeaders} {
+			for _, h := range headers {
+				for _, value := range h.Values {
+					if value.SignedJWT != nil {
+						return []string{fmt.Sprintf("signedJWT value for header %q requires upstream_jwt_key_file", h.Name)}
+					}
+				}
+			}
+		}
+		return []string{}
+	}
+
+	key, err := os.ReadFile(o.UpstreamJWTKeyFile)
+	if err != nil {
+		return []string{fmt.Sprintf("could not read upstream_jwt_key_file: %v", err)}
+	}
+	if _, err := header.NewSigner(key); err != nil {
+		return []string{fmt.Sprintf("invalid upstream_jwt_key_file: %v", err)}
+	}
+	return []string{}
+}
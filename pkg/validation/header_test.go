@@ -1,7 +1,13 @@
 package validation
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo"
@@ -12,6 +18,7 @@ import (
 var _ = Describe("Headers", func() {
 	type validateHeaderTableInput struct {
 		headers      []options.Header
+		upstreams    options.UpstreamConfig
 		expectedMsgs []string
 	}
 
@@ -51,9 +58,35 @@ var _ = Describe("Headers", func() {
 		},
 	}
 
+	tokenExchangeHeader := options.Header{
+		Name: "Authorization",
+		Values: []options.HeaderValue{
+			{
+				TokenExchange: &options.TokenExchangeSource{
+					Upstream: "api",
+					Prefix:   "Bearer ",
+				},
+			},
+		},
+	}
+
+	negativeExpiry := options.Duration(-time.Minute)
+
+	signedJWTHeader := options.Header{
+		Name: "X-Signed-JWT",
+		Values: []options.HeaderValue{
+			{
+				SignedJWT: &options.SignedJWTSource{
+					Audience: "api",
+					Claims:   []string{"email", "groups"},
+				},
+			},
+		},
+	}
+
 	DescribeTable("validateHeaders",
 		func(in validateHeaderTableInput) {
-			Expect(validateHeaders(in.headers)).To(ConsistOf(in.expectedMsgs))
+			Expect(validateHeaders(in.headers, in.upstreams)).To(ConsistOf(in.expectedMsgs))
 		},
 		Entry("with no headers", validateHeaderTableInput{
 			headers:      []options.Header{},
@@ -160,5 +193,201 @@ var _ = Describe("Headers", func() {
 				"invalid header \"With-Invalid-Basic-Auth\": invalid values: invalid basicAuthPassword: error loading secret from environent: no value for for key \"UNKNOWN_ENV\"",
 			},
 		}),
+		Entry("with a token exchange for an upstream", validateHeaderTableInput{
+			headers: []options.Header{
+				tokenExchangeHeader,
+			},
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID: "api",
+						TokenExchange: &options.TokenExchange{
+							Audience: "api",
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{},
+		}),
+		Entry("with a token exchange for an upstream without a token exchange", validateHeaderTableInput{
+			headers: []options.Header{
+				tokenExchangeHeader,
+			},
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID: "api",
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"Authorization\": invalid values: tokenExchange upstream \"api\" does not exist or has no tokenExchange",
+			},
+		}),
+		Entry("with a token exchange without an upstream", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "Authorization",
+					Values: []options.HeaderValue{
+						{
+							TokenExchange: &options.TokenExchangeSource{},
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"Authorization\": invalid values: tokenExchange upstream should not be empty",
+			},
+		}),
+		Entry("with a signed JWT", validateHeaderTableInput{
+			headers: []options.Header{
+				signedJWTHeader,
+			},
+			expectedMsgs: []string{},
+		}),
+		Entry("with a signed JWT with an invalid expiry and claim", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "Authorization",
+					Values: []options.HeaderValue{
+						{
+							SignedJWT: &options.SignedJWTSource{
+								Claims: []string{"email", ""},
+								Expiry: &negativeExpiry,
+							},
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"Authorization\": invalid values: signedJWT expiry must be greater than 0",
+				"invalid header \"Authorization\": invalid values: signedJWT claims should not be empty",
+			},
+		}),
+		Entry("with a template", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "X-User",
+					Values: []options.HeaderValue{
+						{
+							Template: "{{.preferred_username}}@{{.provider}}",
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{},
+		}),
+		Entry("with an invalid template", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "X-User",
+					Values: []options.HeaderValue{
+						{
+							Template: "{{.user | unknown}}",
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"X-User\": invalid values: invalid template: template: header:1: function \"unknown\" not defined",
+			},
+		}),
+		Entry("with a template and a claim", validateHeaderTableInput{
+			headers: []options.Header{
+				{
+					Name: "X-User",
+					Values: []options.HeaderValue{
+						{
+							ClaimSource: &options.ClaimSource{
+								Claim: "user",
+							},
+							Template: "{{.user}}",
+						},
+					},
+				},
+			},
+			expectedMsgs: []string{
+				"invalid header \"X-User\": invalid values: header value has multiple entries: only one entry per value is allowed",
+			},
+		}),
 	)
+
+	DescribeTable("validateResponseHeaders",
+		func(headers []options.Header, expectedMsgs []string) {
+			Expect(validateResponseHeaders(headers)).To(ConsistOf(expectedMsgs))
+		},
+		Entry("with valid headers", []options.Header{validHeader1, validHeader2}, []string{}),
+		Entry("with a token exchange", []options.Header{validHeader1, tokenExchangeHeader}, []string{
+			"invalid header \"Authorization\": tokenExchange values can only be injected into requests",
+		}),
+	)
+
+	Context("validateUpstreamJWTKey", func() {
+		var keyFile string
+
+		BeforeEach(func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ToNot(HaveOccurred())
+
+			file, err := os.CreateTemp("", "upstream-jwt-key-*.pem")
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			keyFile = file.Name()
+
+			Expect(pem.Encode(file, &pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(key),
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(keyFile)).To(Succeed())
+		})
+
+		It("passes with a signed JWT header and a key", func() {
+			o := &options.Options{
+				InjectRequestHeaders: []options.Header{signedJWTHeader},
+				UpstreamJWTKeyFile:   keyFile,
+			}
+			Expect(validateUpstreamJWTKey(o)).To(BeEmpty())
+		})
+
+		It("fails with a signed JWT header and no key", func() {
+			o := &options.Options{
+				InjectResponseHeaders: []options.Header{signedJWTHeader},
+			}
+			Expect(validateUpstreamJWTKey(o)).To(ConsistOf(
+				"signedJWT value for header \"X-Signed-JWT\" requires upstream_jwt_key_file",
+			))
+		})
+
+		It("does not modify the configured headers", func() {
+			requestHeaders := make([]options.Header, 1, 2)
+			requestHeaders[0] = validHeader1
+			o := &options.Options{
+				InjectRequestHeaders:  requestHeaders,
+				InjectResponseHeaders: []options.Header{signedJWTHeader},
+			}
+			Expect(validateUpstreamJWTKey(o)).To(HaveLen(1))
+			Expect(requestHeaders[:2][1]).To(Equal(options.Header{}))
+		})
+
+		It("fails with a missing key file", func() {
+			o := &options.Options{
+				UpstreamJWTKeyFile: keyFile + ".missing",
+			}
+			Expect(validateUpstreamJWTKey(o)).To(HaveLen(1))
+			Expect(validateUpstreamJWTKey(o)[0]).To(HavePrefix("could not read upstream_jwt_key_file: "))
+		})
+
+		It("fails with an invalid key file", func() {
+			Expect(os.WriteFile(keyFile, []byte("not a key"), 0600)).To(Succeed())
+			o := &options.Options{
+				UpstreamJWTKeyFile: keyFile,
+			}
+			Expect(validateUpstreamJWTKey(o)).To(ConsistOf(
+				"invalid upstream_jwt_key_file: key must be a PEM encoded RSA or ECDSA private key",
+			))
+		})
+	})
 })
@@ -1,9 +1,12 @@
 package validation
 
 import (
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -43,6 +46,15 @@ func configureLogger(o options.Logging, msgs []string) []string {
 		logger.Error("Warning: Logging disabled. No further logs will be shown.")
 	}
 
+	switch logger.Format(o.Format) {
+	case "", logger.TextFormat:
+		logger.SetFormat(logger.TextFormat)
+	case logger.JSONFormat:
+		logger.SetFormat(logger.JSONFormat)
+	default:
+		msgs = append(msgs, fmt.Sprintf("logging_format (%q) must be one of ['text', 'json']", o.Format))
+	}
+
 	// Pass configuration values to the standard logger
 	logger.SetStandardEnabled(o.StandardEnabled)
 	logger.SetErrToInfo(o.ErrToInfo)
@@ -60,3 +72,45 @@ func configureLogger(o options.Logging, msgs []string) []string {
 
 	return msgs
 }
+
+// configureAuditLogger is responsible for configuring the audit log based on
+// the options given
+func configureAuditLogger(o options.Logging, msgs []string) []string {
+	var writers []io.Writer
+
+	if len(o.Audit.Filename) > 0 {
+		// Validate that the file/dir can be written
+		file, err := os.OpenFile(o.Audit.Filename, os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return append(msgs, "unable to write to audit log file: "+o.Audit.Filename)
+		}
+		err = file.Close()
+		if err != nil {
+			return append(msgs, "error closing the audit log file: "+o.Audit.Filename)
+		}
+
+		writers = append(writers, &lumberjack.Logger{
+			Filename:   o.Audit.Filename,
+			MaxSize:    o.File.MaxSize, // megabytes
+			MaxAge:     o.File.MaxAge,  // days
+			MaxBackups: o.File.MaxBackups,
+			LocalTime:  o.LocalTime,
+			Compress:   o.File.Compress,
+		})
+	}
+
+	if len(o.Audit.Syslog) > 0 {
+		writer, err := audit.NewSyslogWriter(o.Audit.Syslog)
+		if err != nil {
+			return append(msgs, fmt.Sprintf("unable to connect to the audit log syslog: %v", err))
+		}
+		writers = append(writers, writer)
+	}
+
+	// The audit log is disabled unless a file or syslog is configured
+	if len(writers) > 0 {
+		audit.SetOutput(io.MultiWriter(writers...))
+	}
+
+	return msgs
+}
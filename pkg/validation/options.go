@@ -10,6 +10,7 @@ import (
 
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/audit"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
@@ -22,11 +23,20 @@ func Validate(o *options.Options) error {
 	msgs := validateCookie(o.Cookie)
 	msgs = append(msgs, validateSessionCookieMinimal(o)...)
 	msgs = append(msgs, validateRedisSessionStore(o)...)
-	msgs = append(msgs, prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders)...)...)
-	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders)...)...)
+	msgs = append(msgs, validateSQLSessionStore(o)...)
+	msgs = append(msgs, validateBoltSessionStore(o)...)
+	msgs = append(msgs, validateSessionCache(o)...)
+	msgs = append(msgs, validateAdminServer(o)...)
+	msgs = append(msgs, validateExternalAuthz(o.ExternalAuthz)...)
+	msgs = append(msgs, validateTracing(o.Tracing)...)
+	msgs = append(msgs, prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders, o.UpstreamServers)...)...)
+	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders, o.UpstreamServers)...)...)
+	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateResponseHeaders(o.InjectResponseHeaders)...)...)
+	msgs = append(msgs, validateUpstreamJWTKey(o)...)
 	msgs = append(msgs, validateProviders(o)...)
 	msgs = append(msgs, validateAPIRoutes(o)...)
 	msgs = configureLogger(o.Logging, msgs)
+	msgs = configureAuditLogger(o.Logging, msgs)
 	msgs = parseSignatureKey(o, msgs)
 
 	if o.SSLInsecureSkipVerify {
@@ -95,6 +105,9 @@ func Validate(o *options.Options) error {
 		logger.SetGetClientFunc(func(r *http.Request) string {
 			return ip.GetClientString(o.GetRealClientIPParser(), r, false)
 		})
+		audit.SetGetClientFunc(func(r *http.Request) string {
+			return ip.GetClientString(o.GetRealClientIPParser(), r, false)
+		})
 	}
 
 	// Do this after ReverseProxy validation for TrustedIP coordinated checks
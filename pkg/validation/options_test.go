@@ -165,6 +165,24 @@ func TestGCPHealthcheck(t *testing.T) {
 	assert.Equal(t, nil, Validate(o))
 }
 
+func TestLoggingFormat(t *testing.T) {
+	o := testOptions()
+	o.Logging.Format = "json"
+	assert.Equal(t, nil, Validate(o))
+
+	o = testOptions()
+	o.Logging.Format = "logfmt"
+	err := Validate(o)
+	expected := errorMsg([]string{
+		"logging_format (\"logfmt\") must be one of ['text', 'json']",
+	})
+	assert.Equal(t, expected, err.Error())
+
+	// Restore the text format for the remaining tests
+	o = testOptions()
+	assert.Equal(t, nil, Validate(o))
+}
+
 func TestRealClientIPHeader(t *testing.T) {
 	// Ensure nil if ReverseProxy not set.
 	o := testOptions()
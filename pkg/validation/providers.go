@@ -60,6 +60,10 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 		}
 	}
 
+	if provider.IntrospectCacheDuration != nil && provider.IntrospectCacheDuration.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("provider %s has a negative introspectCacheDuration", provider.ID))
+	}
+
 	msgs = append(msgs, validateGoogleConfig(provider)...)
 
 	return msgs
@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"time"
+
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -31,6 +33,8 @@ var _ = Describe("Providers", func() {
 		ClientSecret: "ClientSecret",
 	}
 
+	negativeIntrospectCacheDuration := options.Duration(-time.Minute)
+
 	missingProvider := "at least one provider has to be defined"
 	emptyIDMsg := "provider has empty id: ids are required for all providers"
 	duplicateProviderIDMsg := "multiple providers found with id ProviderID: provider ids must be unique"
@@ -80,5 +84,19 @@ var _ = Describe("Providers", func() {
 			},
 			errStrings: []string{skipButtonAndMultipleProvidersMsg},
 		}),
+		Entry("with a negative introspectCacheDuration", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					{
+						ID:                      "ProviderID",
+						ClientID:                "ClientID",
+						ClientSecret:            "ClientSecret",
+						IntrospectURL:           "https://idp.example.com/introspect",
+						IntrospectCacheDuration: &negativeIntrospectCacheDuration,
+					},
+				},
+			},
+			errStrings: []string{"provider ProviderID has a negative introspectCacheDuration"},
+		}),
 	)
 })
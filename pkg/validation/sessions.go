@@ -2,6 +2,7 @@ package validation
 
 import (
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"fmt"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	// Register the sql session store drivers with database/sql
+	_ "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 )
 
 func validateSessionCookieMinimal(o *options.Options) []string {
@@ -61,6 +64,83 @@ func validateRedisSessionStore(o *options.Options) []string {
 	return sendRedisConnectionTest(client, key, nonce)
 }
 
+// validateSQLSessionStore checks a DSN is configured for the SQL session
+// store and that the database can be connected to
+func validateSQLSessionStore(o *options.Options) []string {
+	if o.Session.Type != options.SQLSessionStoreType {
+		return []string{}
+	}
+	if o.Session.SQL.DSN == "" {
+		return []string{"missing setting: sql-dsn"}
+	}
+
+	db, err := sql.Open(o.Session.SQL.Driver, o.Session.SQL.DSN)
+	if err != nil {
+		return []string{fmt.Sprintf("unable to initialize a sql client: %v", err)}
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return []string{fmt.Sprintf("unable to connect to the sql database: %v", err)}
+	}
+	return []string{}
+}
+
+// validateBoltSessionStore checks a file is configured for the bolt session
+// store
+func validateBoltSessionStore(o *options.Options) []string {
+	if o.Session.Type != options.BoltSessionStoreType {
+		return []string{}
+	}
+	if o.Session.Bolt.Path == "" {
+		return []string{"missing setting: bolt-path"}
+	}
+	return []string{}
+}
+
+// validateSessionCache checks the in-memory session cache can be used with
+// the configured session store
+func validateSessionCache(o *options.Options) []string {
+	if o.Session.Cache.Size <= 0 {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.Session.Cache.TTL <= 0 {
+		msgs = append(msgs, "session_cache_ttl must be greater than 0 when the session cache is enabled")
+	}
+	if o.Session.Cache.RedisInvalidation && o.Session.Type != options.RedisSessionStoreType {
+		msgs = append(msgs, "session_cache_redis_invalidation requires the redis session store")
+	}
+	return msgs
+}
+
+// validateAdminServer checks the admin API can be served when the admin
+// server is enabled. Sessions can only be listed and revoked in a persistent
+// session store, and every request must be authenticated with the token.
+func validateAdminServer(o *options.Options) []string {
+	if !serverEnabled(o.AdminServer) {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.AdminToken == "" {
+		msgs = append(msgs, "the admin server requires an admin_token")
+	}
+	if o.Session.Type == options.CookieSessionStoreType {
+		msgs = append(msgs, "the admin server requires a persistent session store")
+	}
+	return msgs
+}
+
+// serverEnabled checks whether the server has a HTTP or HTTPS bind address
+func serverEnabled(s options.Server) bool {
+	return (s.BindAddress != "" && s.BindAddress != "-") ||
+		(s.SecureBindAddress != "" && s.SecureBindAddress != "-")
+}
+
 func sendRedisConnectionTest(client redis.Client, key string, val string) []string {
 	msgs := []string{}
 	ctx := context.Background()
@@ -1,11 +1,14 @@
 package validation
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/Bose/minisentinel"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -192,8 +195,8 @@ var _ = Describe("Sessions", func() {
 		invalidPasswordDelMsg     = "unable to delete the redis initialization key: WRONGPASS invalid username-password pair"
 		unreachableRedisSetMsg    = "unable to set a redis initialization key: dial tcp 127.0.0.1:65535: connect: connection refused"
 		unreachableRedisDelMsg    = "unable to delete the redis initialization key: dial tcp 127.0.0.1:65535: connect: connection refused"
-		unreachableSentinelSetMsg = "unable to set a redis initialization key: redis: all sentinels are unreachable"
-		unrechableSentinelDelMsg  = "unable to delete the redis initialization key: redis: all sentinels are unreachable"
+		unreachableSentinelSetMsg = "unable to set a redis initialization key: redis: all sentinels specified in configuration are unreachable"
+		unrechableSentinelDelMsg  = "unable to delete the redis initialization key: redis: all sentinels specified in configuration are unreachable"
 	)
 
 	type redisStoreTableInput struct {
@@ -405,4 +408,237 @@ var _ = Describe("Sessions", func() {
 			errStrings: []string{clusterAndSentinelMsg},
 		}),
 	)
+
+	type sqlStoreTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	DescribeTable("validateSQLSessionStore",
+		func(o *sqlStoreTableInput) {
+			Expect(validateSQLSessionStore(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("cookie sessions are skipped", &sqlStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("missing DSN", &sqlStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.SQLSessionStoreType,
+					SQL: options.SQLStoreOptions{
+						Driver: "postgres",
+					},
+				},
+			},
+			errStrings: []string{"missing setting: sql-dsn"},
+		}),
+		Entry("unknown driver", &sqlStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.SQLSessionStoreType,
+					SQL: options.SQLStoreOptions{
+						Driver: "unknown",
+						DSN:    "unknown://",
+					},
+				},
+			},
+			errStrings: []string{"unable to initialize a sql client: sql: unknown driver \"unknown\" (forgotten import?)"},
+		}),
+	)
+
+	It("validateSQLSessionStore connects to the database", func() {
+		if !tests.SQLiteAvailable() {
+			Skip("the sqlite3 driver requires cgo")
+		}
+
+		dir, err := os.MkdirTemp("", "sql-session-store")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		Expect(validateSQLSessionStore(&options.Options{
+			Session: options.SessionOptions{
+				Type: options.SQLSessionStoreType,
+				SQL: options.SQLStoreOptions{
+					Driver: "sqlite3",
+					DSN:    filepath.Join(dir, "sessions.db"),
+				},
+			},
+		})).To(BeEmpty())
+	})
+
+	type boltStoreTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	DescribeTable("validateBoltSessionStore",
+		func(o *boltStoreTableInput) {
+			Expect(validateBoltSessionStore(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("cookie sessions are skipped", &boltStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a path", &boltStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.BoltSessionStoreType,
+					Bolt: options.BoltStoreOptions{
+						Path: "/var/lib/oauth2-proxy/sessions.db",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("missing path", &boltStoreTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.BoltSessionStoreType,
+				},
+			},
+			errStrings: []string{"missing setting: bolt-path"},
+		}),
+	)
+
+	type sessionCacheTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	DescribeTable("validateSessionCache",
+		func(o *sessionCacheTableInput) {
+			Expect(validateSessionCache(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("a disabled cache is skipped", &sessionCacheTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.BoltSessionStoreType,
+					Cache: options.SessionCacheOptions{
+						RedisInvalidation: true,
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with redis invalidation", &sessionCacheTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+					Cache: options.SessionCacheOptions{
+						Size:              100,
+						TTL:               time.Second,
+						RedisInvalidation: true,
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("missing TTL", &sessionCacheTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.BoltSessionStoreType,
+					Cache: options.SessionCacheOptions{
+						Size: 100,
+					},
+				},
+			},
+			errStrings: []string{"session_cache_ttl must be greater than 0 when the session cache is enabled"},
+		}),
+		Entry("redis invalidation without redis", &sessionCacheTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.SQLSessionStoreType,
+					Cache: options.SessionCacheOptions{
+						Size:              100,
+						TTL:               time.Second,
+						RedisInvalidation: true,
+					},
+				},
+			},
+			errStrings: []string{"session_cache_redis_invalidation requires the redis session store"},
+		}),
+	)
+
+	const (
+		adminTokenMsg      = "the admin server requires an admin_token"
+		adminSessionMsg    = "the admin server requires a persistent session store"
+		adminAddr          = "127.0.0.1:9200"
+		adminToken         = "admin-token"
+		disabledServerAddr = "-"
+	)
+
+	type adminServerTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	DescribeTable("validateAdminServer",
+		func(o *adminServerTableInput) {
+			Expect(validateAdminServer(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("admin server not configured", &adminServerTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("admin server disabled", &adminServerTableInput{
+			opts: &options.Options{
+				AdminServer: options.Server{
+					BindAddress:       disabledServerAddr,
+					SecureBindAddress: disabledServerAddr,
+				},
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("admin server with redis sessions and a token", &adminServerTableInput{
+			opts: &options.Options{
+				AdminServer: options.Server{
+					BindAddress: adminAddr,
+				},
+				AdminToken: adminToken,
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("secure admin server without a token", &adminServerTableInput{
+			opts: &options.Options{
+				AdminServer: options.Server{
+					SecureBindAddress: adminAddr,
+				},
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+			},
+			errStrings: []string{adminTokenMsg},
+		}),
+		Entry("admin server with cookie sessions", &adminServerTableInput{
+			opts: &options.Options{
+				AdminServer: options.Server{
+					BindAddress: adminAddr,
+				},
+				AdminToken: adminToken,
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{adminSessionMsg},
+		}),
+	)
 })
@@ -0,0 +1,30 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+func validateTracing(o options.TracingOptions) []string {
+	if o.OTLPEndpoint == "" {
+		return []string{}
+	}
+
+	msgs := []string{}
+	u, err := url.Parse(o.OTLPEndpoint)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to parse tracing_otlp_endpoint %q: %v", o.OTLPEndpoint, err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		msgs = append(msgs, fmt.Sprintf("tracing_otlp_endpoint %q must be an absolute http or https URL", o.OTLPEndpoint))
+	}
+
+	if o.ServiceName == "" {
+		msgs = append(msgs, "tracing_service_name must not be empty")
+	}
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		msgs = append(msgs, fmt.Sprintf("tracing_sample_ratio (%v) must be between 0 and 1", o.SampleRatio))
+	}
+	return msgs
+}
@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	type tracingTableInput struct {
+		opts       options.TracingOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateTracing",
+		func(o *tracingTableInput) {
+			Expect(validateTracing(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("disabled tracing is skipped", &tracingTableInput{
+			opts: options.TracingOptions{
+				SampleRatio: 2,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a valid endpoint", &tracingTableInput{
+			opts: options.TracingOptions{
+				OTLPEndpoint: "http://otel-collector:4318/v1/traces",
+				ServiceName:  "oauth2-proxy",
+				SampleRatio:  0.5,
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a relative endpoint", &tracingTableInput{
+			opts: options.TracingOptions{
+				OTLPEndpoint: "otel-collector:4318",
+				ServiceName:  "oauth2-proxy",
+				SampleRatio:  1,
+			},
+			errStrings: []string{"tracing_otlp_endpoint \"otel-collector:4318\" must be an absolute http or https URL"},
+		}),
+		Entry("with an invalid endpoint", &tracingTableInput{
+			opts: options.TracingOptions{
+				OTLPEndpoint: "http://otel-collector:port",
+				ServiceName:  "oauth2-proxy",
+				SampleRatio:  1,
+			},
+			errStrings: []string{"unable to parse tracing_otlp_endpoint \"http://otel-collector:port\": parse \"http://otel-collector:port\": invalid port \":port\" after host"},
+		}),
+		Entry("without a service name and with an invalid sample ratio", &tracingTableInput{
+			opts: options.TracingOptions{
+				OTLPEndpoint: "https://otel-collector",
+				SampleRatio:  -0.5,
+			},
+			errStrings: []string{
+				"tracing_service_name must not be empty",
+				"tracing_sample_ratio (-0.5) must be between 0 and 1",
+			},
+		}),
+	)
+})
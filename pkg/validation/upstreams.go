@@ -3,6 +3,7 @@ package validation
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 )
@@ -37,17 +38,203 @@ func validateUpstream(upstream options.Upstream, ids, paths map[string]struct{})
 	}
 	ids[upstream.ID] = struct{}{}
 
-	// Ensure upstream Paths are unique
-	if _, ok := paths[upstream.Path]; ok {
-		msgs = append(msgs, fmt.Sprintf("multiple upstreams found with path %q: upstream paths must be unique", upstream.Path))
+	// Ensure upstream Paths are unique for each host
+	if len(upstream.Hosts) == 0 {
+		if _, ok := paths[upstream.Path]; ok {
+			msgs = append(msgs, fmt.Sprintf("multiple upstreams found with path %q: upstream paths must be unique", upstream.Path))
+		}
+		paths[upstream.Path] = struct{}{}
+	}
+	for _, host := range upstream.Hosts {
+		key := strings.ToLower(host) + upstream.Path
+		if _, ok := paths[key]; ok {
+			msgs = append(msgs, fmt.Sprintf("multiple upstreams found with host %q and path %q: upstream paths must be unique for each host", host, upstream.Path))
+		}
+		paths[key] = struct{}{}
 	}
-	paths[upstream.Path] = struct{}{}
 
 	msgs = append(msgs, validateUpstreamURI(upstream)...)
+	msgs = append(msgs, validateUpstreamHosts(upstream)...)
+	msgs = append(msgs, validateUpstreamLoadBalancer(upstream)...)
+	msgs = append(msgs, validateUpstreamRetry(upstream)...)
+	msgs = append(msgs, validateUpstreamCircuitBreaker(upstream)...)
 	msgs = append(msgs, validateStaticUpstream(upstream)...)
+	msgs = append(msgs, validateUpstreamTokenExchange(upstream)...)
+	msgs = append(msgs, validateUpstreamAuthorization(upstream)...)
+	msgs = append(msgs, validateUpstreamAllowUnauthenticated(upstream)...)
+	return msgs
+}
+
+// validateUpstreamLoadBalancer checks that the URIs of a load balanced
+// upstream are HTTP(S), h2c or grpc URIs, and that the load balancer settings are valid.
+func validateUpstreamLoadBalancer(upstream options.Upstream) []string {
+	msgs := []string{}
+	if len(upstream.URIs) == 0 && upstream.LoadBalancer == nil {
+		return msgs
+	}
+
+	if upstream.Static {
+		return append(msgs, fmt.Sprintf("upstream %q has uris or loadBalancer, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if u, err := url.Parse(upstream.URI); err == nil && (u.Scheme == "file" || u.Scheme == "unix") {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has uris or loadBalancer, but is a %s upstream: only http(s), h2c and grpc upstreams can be load balanced", upstream.ID, u.Scheme))
+	}
+
+	for _, uri := range upstream.URIs {
+		u, err := url.Parse(uri)
+		switch {
+		case err != nil:
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid uri in uris: %v", upstream.ID, err))
+		case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "h2c" && u.Scheme != "grpc":
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid scheme in uris: %q", upstream.ID, u.Scheme))
+		}
+	}
+
+	lb := upstream.LoadBalancer
+	if lb == nil {
+		return msgs
+	}
+
+	switch lb.Strategy {
+	case "", options.RoundRobinStrategy, options.LeastConnectionsStrategy, options.UserHashStrategy:
+		// Valid, do nothing
+	default:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid loadBalancer strategy %q: must be one of %q, %q or %q", upstream.ID, lb.Strategy,
+			options.RoundRobinStrategy, options.LeastConnectionsStrategy, options.UserHashStrategy))
+	}
+	if lb.EjectionDuration != nil && lb.EjectionDuration.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative loadBalancer ejectionDuration", upstream.ID))
+	}
+
+	if check := lb.HealthCheck; check != nil {
+		if !strings.HasPrefix(check.Path, "/") {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid healthCheck path %q: paths must start with '/'", upstream.ID, check.Path))
+		}
+		if check.Interval != nil && check.Interval.Duration() <= 0 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has healthCheck interval, it must be greater than 0", upstream.ID))
+		}
+		if check.Timeout != nil && check.Timeout.Duration() <= 0 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has healthCheck timeout, it must be greater than 0", upstream.ID))
+		}
+	}
+	return msgs
+}
+
+// validateUpstreamRetry checks that the retry configuration is valid
+func validateUpstreamRetry(upstream options.Upstream) []string {
+	msgs := []string{}
+	retry := upstream.Retry
+	if retry == nil {
+		return msgs
+	}
+
+	if upstream.Static {
+		return append(msgs, fmt.Sprintf("upstream %q has retry, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if retry.Attempts < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative number of retry attempts", upstream.ID))
+	}
+	if retry.Backoff != nil && retry.Backoff.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative retry backoff", upstream.ID))
+	}
+	for _, code := range retry.StatusCodes {
+		if code < 100 || code > 599 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid retry status code %d", upstream.ID, code))
+		}
+	}
+	return msgs
+}
+
+// validateUpstreamCircuitBreaker checks that the circuit breaker
+// configuration is valid
+func validateUpstreamCircuitBreaker(upstream options.Upstream) []string {
+	msgs := []string{}
+	breaker := upstream.CircuitBreaker
+	if breaker == nil {
+		return msgs
+	}
+
+	if upstream.Static {
+		return append(msgs, fmt.Sprintf("upstream %q has circuitBreaker, but is a static upstream, this will have no effect.", upstream.ID))
+	}
+	if breaker.FailureThreshold < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has a negative circuitBreaker failureThreshold", upstream.ID))
+	}
+	if breaker.OpenDuration != nil && breaker.OpenDuration.Duration() <= 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has circuitBreaker openDuration, it must be greater than 0", upstream.ID))
+	}
+	if breaker.StaticCode != nil && (*breaker.StaticCode < 100 || *breaker.StaticCode > 599) {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid circuitBreaker staticCode %d", upstream.ID, *breaker.StaticCode))
+	}
+	if breaker.StaticCode == nil && breaker.StaticBody != "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has circuitBreaker staticBody, but no staticCode, set 'staticCode' for a static response", upstream.ID))
+	}
+	return msgs
+}
+
+// validateUpstreamHosts checks that the hosts are either exact hosts, or
+// wildcards with a leading `*.`
+func validateUpstreamHosts(upstream options.Upstream) []string {
+	msgs := []string{}
+	for _, host := range upstream.Hosts {
+		switch {
+		case host == "":
+			msgs = append(msgs, fmt.Sprintf("upstream %q has an empty host", upstream.ID))
+		case strings.Contains(strings.TrimPrefix(host, "*."), "*"):
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid host %q: wildcards are only allowed as a leading '*.'", upstream.ID, host))
+		case host == "*." || strings.ContainsAny(host, "/:"):
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid host %q", upstream.ID, host))
+		}
+	}
+	return msgs
+}
+
+// validateUpstreamAllowUnauthenticated checks that allowedMethods are only
+// set when unauthenticated requests are allowed, and that authorization rules
+// are not set when unauthenticated requests are allowed for every method.
+func validateUpstreamAllowUnauthenticated(upstream options.Upstream) []string {
+	msgs := []string{}
+
+	if !upstream.AllowUnauthenticated && len(upstream.AllowedMethods) > 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has allowedMethods, but does not allow unauthenticated requests, set 'allowUnauthenticated' to allow them", upstream.ID))
+	}
+	if upstream.AllowUnauthenticated && len(upstream.AllowedMethods) == 0 && upstream.Authorization != nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has authorization, but allows unauthenticated requests for all methods, this will have no effect.", upstream.ID))
+	}
+	return msgs
+}
+
+// validateUpstreamAuthorization checks that the claim matchers of the
+// authorization rules name a claim and the values it may have
+func validateUpstreamAuthorization(upstream options.Upstream) []string {
+	msgs := []string{}
+	if upstream.Authorization == nil {
+		return msgs
+	}
+
+	for _, matcher := range upstream.Authorization.Claims {
+		if matcher.Claim == "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has an authorization claim matcher without a claim", upstream.ID))
+		}
+		if len(matcher.Values) == 0 {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has an authorization claim matcher for %q without values", upstream.ID, matcher.Claim))
+		}
+	}
 	return msgs
 }
 
+// validateUpstreamTokenExchange checks that a token exchange requests a
+// token for an audience or scopes
+func validateUpstreamTokenExchange(upstream options.Upstream) []string {
+	if upstream.TokenExchange == nil {
+		return []string{}
+	}
+	if upstream.TokenExchange.Audience == "" && len(upstream.TokenExchange.Scopes) == 0 {
+		return []string{fmt.Sprintf("upstream %q has tokenExchange without an audience or scopes", upstream.ID)}
+	}
+	return []string{}
+}
+
 // validateStaticUpstream checks that the StaticCode is only set when Static
 // is set, and that any options that do not make sense for a static upstream
 // are not set.
@@ -102,8 +289,12 @@ func validateUpstreamURI(upstream options.Upstream) []string {
 	}
 
 	switch u.Scheme {
-	case "http", "https", "file":
+	case "http", "https", "h2c", "grpc", "file":
 		// Valid, do nothing
+	case "unix":
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has invalid unix socket uri: the socket path must be absolute, eg. unix:///path/to.sock", upstream.ID))
+		}
 	default:
 		msgs = append(msgs, fmt.Sprintf("upstream %q has invalid scheme: %q", upstream.ID, u.Scheme))
 	}
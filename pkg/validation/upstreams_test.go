@@ -16,6 +16,8 @@ var _ = Describe("Upstreams", func() {
 	}
 
 	flushInterval := options.Duration(5 * time.Second)
+	negativeDuration := options.Duration(-1 * time.Second)
+	zeroDuration := options.Duration(0)
 	staticCode200 := 200
 	truth := true
 
@@ -47,6 +49,7 @@ var _ = Describe("Upstreams", func() {
 	staticWithProxyWebSocketsMsg := "upstream \"foo\" has proxyWebSockets, but is a static upstream, this will have no effect."
 	multipleIDsMsg := "multiple upstreams found with id \"foo\": upstream ids must be unique"
 	multiplePathsMsg := "multiple upstreams found with path \"/foo\": upstream paths must be unique"
+	tokenExchangeMsg := "upstream \"foo\" has tokenExchange without an audience or scopes"
 	staticCodeMsg := "upstream \"foo\" has staticCode (200), but is not a static upstream, set 'static' for a static response"
 
 	DescribeTable("validateUpstreams",
@@ -139,6 +142,46 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{invalidURISchemeMsg},
 		}),
+		Entry("with a unix socket URI", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "unix:///var/run/foo.sock/api",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a unix socket URI with a host", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "unix://var/run/foo.sock",
+					},
+				},
+			},
+			errStrings: []string{"upstream \"foo\" has invalid unix socket uri: the socket path must be absolute, eg. unix:///path/to.sock"},
+		}),
+		Entry("with a load balanced unix socket upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "unix:///var/run/foo.sock",
+						URIs: []string{"unix:///var/run/foo2.sock"},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has uris or loadBalancer, but is a unix upstream: only http(s), h2c and grpc upstreams can be load balanced",
+				"upstream \"foo\" has invalid scheme in uris: \"unix\"",
+			},
+		}),
 		Entry("with a static upstream and invalid optons", &validateUpstreamTableInput{
 			upstreams: options.UpstreamConfig{
 				Upstreams: []options.Upstream{
@@ -196,6 +239,186 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{multiplePathsMsg},
 		}),
+		Entry("with duplicate Paths for different hosts", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:    "foo1",
+						Path:  "/foo",
+						Hosts: []string{"foo.example.com"},
+						URI:   "http://foo",
+					},
+					{
+						ID:    "foo2",
+						Path:  "/foo",
+						Hosts: []string{"*.apps.example.com"},
+						URI:   "http://foo",
+					},
+					{
+						ID:   "foo3",
+						Path: "/foo",
+						URI:  "http://foo",
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with duplicate Paths for the same host", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:    "foo1",
+						Path:  "/foo",
+						Hosts: []string{"foo.example.com", "bar.example.com"},
+						URI:   "http://foo",
+					},
+					{
+						ID:    "foo2",
+						Path:  "/foo",
+						Hosts: []string{"Bar.example.com"},
+						URI:   "http://foo",
+					},
+				},
+			},
+			errStrings: []string{
+				"multiple upstreams found with host \"Bar.example.com\" and path \"/foo\": upstream paths must be unique for each host",
+			},
+		}),
+		Entry("with invalid hosts", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:    "foo",
+						Path:  "/foo",
+						Hosts: []string{"", "foo.*.example.com", "*.", "foo.example.com:8080"},
+						URI:   "http://foo",
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has an empty host",
+				"upstream \"foo\" has invalid host \"foo.*.example.com\": wildcards are only allowed as a leading '*.'",
+				"upstream \"foo\" has invalid host \"*.\"",
+				"upstream \"foo\" has invalid host \"foo.example.com:8080\"",
+			},
+		}),
+		Entry("with a valid load balanced upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo1",
+						URIs: []string{"http://foo2", "https://foo3"},
+						LoadBalancer: &options.LoadBalancer{
+							Strategy: options.LeastConnectionsStrategy,
+							HealthCheck: &options.HealthCheck{
+								Path: "/healthz",
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with a valid load balanced h2c upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "h2c://foo1",
+						URIs: []string{"grpc://foo2"},
+						LoadBalancer: &options.LoadBalancer{
+							HealthCheck: &options.HealthCheck{
+								Path: "/healthz",
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid load balanced upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "file:///foo",
+						URIs: []string{"http://foo2", "ftp://foo3"},
+						LoadBalancer: &options.LoadBalancer{
+							Strategy:         "random",
+							EjectionDuration: &negativeDuration,
+							HealthCheck: &options.HealthCheck{
+								Path:     "healthz",
+								Interval: &zeroDuration,
+								Timeout:  &zeroDuration,
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has uris or loadBalancer, but is a file upstream: only http(s), h2c and grpc upstreams can be load balanced",
+				"upstream \"foo\" has invalid scheme in uris: \"ftp\"",
+				"upstream \"foo\" has invalid loadBalancer strategy \"random\": must be one of \"roundRobin\", \"leastConnections\" or \"userHash\"",
+				"upstream \"foo\" has a negative loadBalancer ejectionDuration",
+				"upstream \"foo\" has invalid healthCheck path \"healthz\": paths must start with '/'",
+				"upstream \"foo\" has healthCheck interval, it must be greater than 0",
+				"upstream \"foo\" has healthCheck timeout, it must be greater than 0",
+			},
+		}),
+		Entry("with valid retry and circuitBreaker", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Retry: &options.Retry{
+							Attempts:    2,
+							StatusCodes: []int{502, 503},
+						},
+						CircuitBreaker: &options.CircuitBreaker{
+							FailureThreshold: 3,
+							StaticCode:       &staticCode200,
+							StaticBody:       "unavailable",
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid retry and circuitBreaker", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Retry: &options.Retry{
+							Attempts:    -1,
+							Backoff:     &negativeDuration,
+							StatusCodes: []int{503, 1000},
+						},
+						CircuitBreaker: &options.CircuitBreaker{
+							FailureThreshold: -1,
+							OpenDuration:     &zeroDuration,
+							StaticBody:       "unavailable",
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has a negative number of retry attempts",
+				"upstream \"foo\" has a negative retry backoff",
+				"upstream \"foo\" has invalid retry status code 1000",
+				"upstream \"foo\" has a negative circuitBreaker failureThreshold",
+				"upstream \"foo\" has circuitBreaker openDuration, it must be greater than 0",
+				"upstream \"foo\" has circuitBreaker staticBody, but no staticCode, set 'staticCode' for a static response",
+			},
+		}),
 		Entry("when a static code is supplied without static", &validateUpstreamTableInput{
 			upstreams: options.UpstreamConfig{
 				Upstreams: []options.Upstream{
@@ -208,5 +431,84 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{emptyURIMsg, staticCodeMsg},
 		}),
+		Entry("with a token exchange without an audience or scopes", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:            "foo",
+						Path:          "/foo",
+						URI:           "http://localhost:8080",
+						TokenExchange: &options.TokenExchange{},
+					},
+				},
+			},
+			errStrings: []string{tokenExchangeMsg},
+		}),
+		Entry("with invalid authorization claim matchers", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://localhost:8080",
+						Authorization: &options.UpstreamAuthorization{
+							Groups: []string{"ops"},
+							Claims: []options.ClaimMatcher{
+								{Claim: "team", Values: []string{"platform"}},
+								{Values: []string{"platform"}},
+								{Claim: "team"},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has an authorization claim matcher without a claim",
+				"upstream \"foo\" has an authorization claim matcher for \"team\" without values",
+			},
+		}),
+		Entry("with allowedMethods without allowUnauthenticated", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:             "foo",
+						Path:           "/foo",
+						URI:            "http://localhost:8080",
+						AllowedMethods: []string{"GET"},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has allowedMethods, but does not allow unauthenticated requests, set 'allowUnauthenticated' to allow them",
+			},
+		}),
+		Entry("with allowUnauthenticated and authorization", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:                   "foo",
+						Path:                 "/foo",
+						URI:                  "http://localhost:8080",
+						AllowUnauthenticated: true,
+						Authorization: &options.UpstreamAuthorization{
+							Groups: []string{"ops"},
+						},
+					},
+					{
+						ID:                   "bar",
+						Path:                 "/bar",
+						URI:                  "http://localhost:8080",
+						AllowUnauthenticated: true,
+						AllowedMethods:       []string{"GET"},
+						Authorization: &options.UpstreamAuthorization{
+							Groups: []string{"ops"},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has authorization, but allows unauthenticated requests for all methods, this will have no effect.",
+			},
+		}),
 	)
 })
@@ -26,6 +26,9 @@ const (
 	adfsProviderName = "ADFS"
 	adfsDefaultScope = "openid email profile"
 	adfsUPNClaim     = "upn"
+
+	adfsLoginPathSuffix  = "/adfs/oauth2/authorize"
+	adfsLogoutPathSuffix = "/adfs/oauth2/logout"
 )
 
 // NewADFSProvider initiates a new ADFSProvider
@@ -46,6 +49,10 @@ func NewADFSProvider(p *ProviderData, opts options.ADFSOptions) *ADFSProvider {
 		}
 	}
 
+	// ADFS only advertises the end_session_endpoint from Windows Server 2016,
+	// fall back to the logout endpoint next to the login endpoint.
+	p.deriveLogoutURL(adfsLoginPathSuffix, adfsLogoutPathSuffix)
+
 	oidcProvider := &OIDCProvider{
 		ProviderData: p,
 		SkipNonce:    false,
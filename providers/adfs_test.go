@@ -138,6 +138,22 @@ var _ = Describe("ADFS Provider Tests", func() {
 			Expect(providerData.ProviderName).To(Equal("ADFS"))
 			Expect(providerData.Scope).To(Equal("openid email profile"))
 		})
+
+		It("derives the logout URL from the login URL", func() {
+			loginURL, err := url.Parse("https://example.com/adfs/oauth2/authorize/")
+			Expect(err).ToNot(HaveOccurred())
+			providerData := NewADFSProvider(&ProviderData{LoginURL: loginURL}, options.ADFSOptions{}).Data()
+			Expect(providerData.LogoutURL.String()).To(Equal("https://example.com/adfs/oauth2/logout"))
+		})
+
+		It("does not override a configured logout URL", func() {
+			loginURL, err := url.Parse("https://example.com/adfs/oauth2/authorize/")
+			Expect(err).ToNot(HaveOccurred())
+			logoutURL, err := url.Parse("https://example.com/custom/logout")
+			Expect(err).ToNot(HaveOccurred())
+			providerData := NewADFSProvider(&ProviderData{LoginURL: loginURL, LogoutURL: logoutURL}, options.ADFSOptions{}).Data()
+			Expect(providerData.LogoutURL.String()).To(Equal("https://example.com/custom/logout"))
+		})
 	})
 
 	Context("with bad token", func() {
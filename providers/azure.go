@@ -25,8 +25,10 @@ type AzureProvider struct {
 var _ Provider = (*AzureProvider)(nil)
 
 const (
-	azureProviderName = "Azure"
-	azureDefaultScope = "openid"
+	azureProviderName     = "Azure"
+	azureDefaultScope     = "openid"
+	azureLoginPathSuffix  = "/oauth2/authorize"
+	azureLogoutPathSuffix = "/oauth2/logout"
 )
 
 var (
@@ -87,6 +89,18 @@ func NewAzureProvider(p *ProviderData, opts options.AzureOptions) *AzureProvider
 		overrideTenantURL(p.LoginURL, azureDefaultLoginURL, tenant, "authorize")
 		overrideTenantURL(p.RedeemURL, azureDefaultRedeemURL, tenant, "token")
 	}
+	p.deriveLogoutURL(azureLoginPathSuffix, azureLogoutPathSuffix)
+	if p.LogoutURL == nil || p.LogoutURL.String() == "" {
+		// The login URL doesn't follow the usual layout, so keep its host
+		// (e.g. a sovereign cloud) and fall back to the tenant logout path.
+		logoutURL := *azureDefaultLoginURL
+		if p.LoginURL != nil && p.LoginURL.Host != "" {
+			logoutURL.Scheme = p.LoginURL.Scheme
+			logoutURL.Host = p.LoginURL.Host
+		}
+		logoutURL.Path = "/" + tenant + azureLogoutPathSuffix
+		p.LogoutURL = &logoutURL
+	}
 
 	return &AzureProvider{
 		ProviderData: p,
@@ -355,6 +369,29 @@ func (p *AzureProvider) getEmailFromProfileAPI(ctx context.Context, accessToken
 	return getEmailFromJSON(json)
 }
 
+// RevokeSession revokes the user's refresh tokens through the Microsoft Graph
+// revokeSignInSessions API configured as the RevokeURL, as Azure AD has no
+// RFC 7009 revocation endpoint. This invalidates the refresh tokens of all of
+// the user's sessions, so it is only done when a RevokeURL is configured.
+func (p *AzureProvider) RevokeSession(ctx context.Context, s *sessions.SessionState) error {
+	if p.RevokeURL == nil || p.RevokeURL.String() == "" || s == nil || s.AccessToken == "" {
+		return nil
+	}
+
+	result := requests.New(p.RevokeURL.String()).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithHeaders(makeAzureHeader(s.AccessToken)).
+		Do()
+	if result.Error() != nil {
+		return result.Error()
+	}
+	if result.StatusCode() != http.StatusOK && result.StatusCode() != http.StatusNoContent {
+		return fmt.Errorf("unexpected status \"%d\" revoking sign in sessions: %s", result.StatusCode(), result.Body())
+	}
+	return nil
+}
+
 // ValidateSession validates the AccessToken
 func (p *AzureProvider) ValidateSession(ctx context.Context, s *sessions.SessionState) bool {
 	return validateToken(ctx, p, s.AccessToken, makeAzureHeader(s.AccessToken))
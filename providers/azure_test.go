@@ -143,6 +143,76 @@ func TestAzureSetTenant(t *testing.T) {
 		p.Data().ProtectedResource.String())
 	assert.Equal(t, "https://graph.microsoft.com/v1.0/me", p.Data().ValidateURL.String())
 	assert.Equal(t, "openid", p.Data().Scope)
+	assert.Equal(t, "https://login.microsoftonline.com/example/oauth2/logout",
+		p.Data().LogoutURL.String())
+}
+
+func TestAzureLogoutURLFollowsLoginURL(t *testing.T) {
+	testCases := []struct {
+		name           string
+		loginURL       string
+		logoutURL      string
+		expectedLogout string
+	}{
+		{
+			name:           "sovereign cloud login URL",
+			loginURL:       "https://login.microsoftonline.us/example/oauth2/authorize",
+			expectedLogout: "https://login.microsoftonline.us/example/oauth2/logout",
+		},
+		{
+			name:           "login URL with a non standard path",
+			loginURL:       "http://localhost:8080/login",
+			expectedLogout: "http://localhost:8080/example/oauth2/logout",
+		},
+		{
+			name:           "configured logout URL is kept",
+			loginURL:       "https://login.microsoftonline.us/example/oauth2/authorize",
+			logoutURL:      "https://logout.example.com/signout",
+			expectedLogout: "https://logout.example.com/signout",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loginURL, err := url.Parse(tc.loginURL)
+			assert.NoError(t, err)
+			logoutURL, err := url.Parse(tc.logoutURL)
+			assert.NoError(t, err)
+
+			p := NewAzureProvider(&ProviderData{
+				LoginURL:          loginURL,
+				RedeemURL:         &url.URL{},
+				ProfileURL:        &url.URL{},
+				ValidateURL:       &url.URL{},
+				ProtectedResource: &url.URL{},
+				LogoutURL:         logoutURL,
+			}, options.AzureOptions{Tenant: "example"})
+
+			assert.Equal(t, tc.expectedLogout, p.Data().LogoutURL.String())
+		})
+	}
+}
+
+func TestAzureProviderRevokeSession(t *testing.T) {
+	var authorization string
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1.0/me/revokeSignInSessions", r.URL.Path)
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer b.Close()
+
+	p := testAzureProvider("", options.AzureOptions{})
+	session := &sessions.SessionState{AccessToken: "imaginary_access_token"}
+
+	// Revocation is opt-in as it revokes all of the user's sessions
+	assert.NoError(t, p.RevokeSession(context.Background(), session))
+	assert.Equal(t, "", authorization)
+
+	p.RevokeURL, _ = url.Parse(b.URL + "/v1.0/me/revokeSignInSessions")
+	assert.NoError(t, p.RevokeSession(context.Background(), session))
+	assert.Equal(t, "Bearer imaginary_access_token", authorization)
 }
 
 func testAzureBackend(payload string, accessToken, refreshToken string) *httptest.Server {
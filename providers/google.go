@@ -78,6 +78,14 @@ var (
 		Host:   "www.googleapis.com",
 		Path:   "/oauth2/v1/tokeninfo",
 	}
+
+	// Default Revoke URL for Google.
+	// Pre-parsed URL of https://oauth2.googleapis.com/revoke.
+	googleDefaultRevokeURL = &url.URL{
+		Scheme: "https",
+		Host:   "oauth2.googleapis.com",
+		Path:   "/revoke",
+	}
 )
 
 // NewGoogleProvider initiates a new GoogleProvider
@@ -90,6 +98,7 @@ func NewGoogleProvider(p *ProviderData, opts options.GoogleOptions) (*GoogleProv
 		validateURL: googleDefaultValidateURL,
 		scope:       googleDefaultScope,
 	})
+	p.RevokeURL = defaultURL(p.RevokeURL, googleDefaultRevokeURL)
 	provider := &GoogleProvider{
 		ProviderData: p,
 		// Set a default groupValidator to just always return valid (true), it will
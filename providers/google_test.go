@@ -54,6 +54,7 @@ func TestNewGoogleProvider(t *testing.T) {
 	g.Expect(providerData.RedeemURL.String()).To(Equal("https://www.googleapis.com/oauth2/v3/token"))
 	g.Expect(providerData.ProfileURL.String()).To(Equal(""))
 	g.Expect(providerData.ValidateURL.String()).To(Equal("https://www.googleapis.com/oauth2/v1/tokeninfo"))
+	g.Expect(providerData.RevokeURL.String()).To(Equal("https://oauth2.googleapis.com/revoke"))
 	g.Expect(providerData.Scope).To(Equal("profile email"))
 }
 
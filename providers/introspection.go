@@ -0,0 +1,265 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+const (
+	// introspectionFailureCacheDuration is how long inactive tokens and tokens
+	// the introspection endpoint rejects are cached for
+	introspectionFailureCacheDuration = 10 * time.Second
+
+	// introspectionCacheSize is the maximum number of tokens cached per
+	// provider
+	introspectionCacheSize = 10000
+)
+
+// introspectionResponse holds the members of a token introspection response
+// (RFC 7662) that are mapped into sessions.
+type introspectionResponse struct {
+	Active   bool   `json:"active"`
+	Subject  string `json:"sub"`
+	Username string `json:"username"`
+	Scope    string `json:"scope"`
+	Expiry   int64  `json:"exp"`
+}
+
+// IntrospectToken creates a session from a bearer token by introspecting it
+// at the IntrospectURL following RFC 7662, authenticating with the client
+// credentials of the provider.
+// Responses for active tokens are cached until the token expires, for at most
+// the configured introspection cache duration. Inactive tokens and tokens the
+// introspection endpoint rejects are cached briefly, so that invalid tokens do
+// not cause an introspection request each time they are presented.
+func (p *ProviderData) IntrospectToken(ctx context.Context, token string) (*sessions.SessionState, error) {
+	if p.IntrospectURL == nil || p.IntrospectURL.String() == "" {
+		return nil, errors.New("provider has no introspection URL")
+	}
+
+	var response *introspectionResponse
+	var err error
+	if entry, ok := p.introspectionCache.get(token); ok {
+		response, err = entry.response, entry.err
+	} else {
+		response, err = p.introspectToken(ctx, token)
+		var statusErr *introspectionStatusError
+		switch {
+		case err == nil && response.Active:
+			p.introspectionCache.set(token, response)
+		case err == nil:
+			err = errors.New("introspected token is not active")
+			p.introspectionCache.setFailure(token, err)
+		case errors.As(err, &statusErr) && statusErr.rejected():
+			p.introspectionCache.setFailure(token, err)
+		}
+		// Transport errors and server errors say nothing about the token, so
+		// are not cached
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session := &sessions.SessionState{
+		User:              response.Subject,
+		PreferredUsername: response.Username,
+		AccessToken:       token,
+	}
+	if response.Scope != "" {
+		session.Scopes = strings.Fields(response.Scope)
+	}
+	if session.User == "" {
+		session.User = response.Username
+	}
+	// Bearer sessions fall back to the subject when there is no email, as
+	// sessions created from JWT bearer tokens do
+	session.Email = session.User
+	if response.Expiry != 0 {
+		expiresOn := time.Unix(response.Expiry, 0)
+		session.ExpiresOn = &expiresOn
+	}
+	return session, nil
+}
+
+// introspectToken requests the introspection of the token at the
+// IntrospectURL.
+func (p *ProviderData) introspectToken(ctx context.Context, token string) (*introspectionResponse, error) {
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("token", token)
+	params.Add("token_type_hint", "access_token")
+	params.Add("client_id", p.ClientID)
+	params.Add("client_secret", clientSecret)
+
+	result := requests.New(p.IntrospectURL.String()).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetHeader("Accept", "application/json").
+		Do()
+	if result.Error() != nil {
+		return nil, result.Error()
+	}
+	if result.StatusCode() != http.StatusOK {
+		return nil, &introspectionStatusError{status: result.StatusCode(), body: result.Body()}
+	}
+
+	response := &introspectionResponse{}
+	if err := result.UnmarshalInto(response); err != nil {
+		return nil, fmt.Errorf("error parsing token introspection response: %v", err)
+	}
+	return response, nil
+}
+
+// introspectionStatusError is the error for introspection responses with an
+// unexpected status.
+type introspectionStatusError struct {
+	status int
+	body   []byte
+}
+
+func (e *introspectionStatusError) Error() string {
+	return fmt.Sprintf("unexpected status \"%d\" introspecting token: %s", e.status, e.body)
+}
+
+// rejected returns whether the introspection endpoint rejected the request, so
+// that it would be rejected again. Rate limited requests may succeed later.
+func (e *introspectionStatusError) rejected() bool {
+	return e.status >= 400 && e.status < 500 && e.status != http.StatusTooManyRequests
+}
+
+// introspectionCache caches the introspection responses of active tokens,
+// keyed by the hash of the token, until the tokens expire or the maximum
+// duration passes. Rejected tokens are cached for the shorter failure duration.
+// The cache holds at most introspectionCacheSize tokens.
+type introspectionCache struct {
+	maxAge time.Duration
+	clock  clock.Clock
+
+	mu        sync.Mutex
+	entries   map[string]introspectionCacheEntry
+	nextPurge time.Time
+}
+
+type introspectionCacheEntry struct {
+	response  *introspectionResponse
+	err       error
+	expiresAt time.Time
+}
+
+func newIntrospectionCache(maxAge time.Duration) *introspectionCache {
+	return &introspectionCache{
+		maxAge:  maxAge,
+		entries: make(map[string]introspectionCacheEntry),
+	}
+}
+
+// get returns the cached entry for the token, if it has not expired.
+// A nil cache never has any entries.
+func (c *introspectionCache) get(token string) (introspectionCacheEntry, bool) {
+	if c == nil {
+		return introspectionCacheEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tokenCacheKey(token)]
+	if !ok || !c.clock.Now().Before(entry.expiresAt) {
+		return introspectionCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// set caches the response for the token until the token expires, for at most
+// the maximum duration of the cache.
+func (c *introspectionCache) set(token string, response *introspectionResponse) {
+	if c == nil {
+		return
+	}
+
+	now := c.clock.Now()
+	expiresAt := now.Add(c.maxAge)
+	if response.Expiry != 0 {
+		if tokenExpiry := time.Unix(response.Expiry, 0); tokenExpiry.Before(expiresAt) {
+			expiresAt = tokenExpiry
+		}
+	}
+	c.add(now, token, introspectionCacheEntry{
+		response:  response,
+		expiresAt: expiresAt,
+	})
+}
+
+// setFailure caches the failed introspection of the token for the failure
+// duration, for at most the maximum duration of the cache.
+func (c *introspectionCache) setFailure(token string, err error) {
+	if c == nil {
+		return
+	}
+
+	now := c.clock.Now()
+	maxAge := c.maxAge
+	if maxAge > introspectionFailureCacheDuration {
+		maxAge = introspectionFailureCacheDuration
+	}
+	c.add(now, token, introspectionCacheEntry{
+		err:       err,
+		expiresAt: now.Add(maxAge),
+	})
+}
+
+// add caches the entry unless it has already expired.
+// Expired entries are purged at most once per maximum duration, and an
+// arbitrary entry is evicted to make room when the cache is full.
+func (c *introspectionCache) add(now time.Time, token string, entry introspectionCacheEntry) {
+	if !now.Before(entry.expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !now.Before(c.nextPurge) {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		c.nextPurge = now.Add(c.maxAge)
+	}
+
+	key := tokenCacheKey(token)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= introspectionCacheSize {
+		for evict := range c.entries {
+			delete(c.entries, evict)
+			break
+		}
+	}
+	c.entries[key] = entry
+}
+
+// tokenCacheKey returns the key of the token in the cache, so that tokens
+// are not held in memory in the clear.
+func tokenCacheKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
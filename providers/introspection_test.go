@@ -0,0 +1,302 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	testCases := map[string]struct {
+		responseStatus  int
+		responseBody    string
+		expectedSession *sessions.SessionState
+		expectedError   string
+	}{
+		"with an active token": {
+			responseStatus: http.StatusOK,
+			responseBody:   `{"active":true,"sub":"123456","username":"jdoe","scope":"read write","exp":` + formatUnix(expiry) + `}`,
+			expectedSession: &sessions.SessionState{
+				User:              "123456",
+				Email:             "123456",
+				PreferredUsername: "jdoe",
+				Scopes:            []string{"read", "write"},
+				AccessToken:       "opaque",
+				ExpiresOn:         &expiry,
+			},
+		},
+		"with an active token without a subject or expiry": {
+			responseStatus: http.StatusOK,
+			responseBody:   `{"active":true,"username":"jdoe"}`,
+			expectedSession: &sessions.SessionState{
+				User:              "jdoe",
+				Email:             "jdoe",
+				PreferredUsername: "jdoe",
+				AccessToken:       "opaque",
+			},
+		},
+		"with an inactive token": {
+			responseStatus: http.StatusOK,
+			responseBody:   `{"active":false}`,
+			expectedError:  "introspected token is not active",
+		},
+		"with an error response": {
+			responseStatus: http.StatusUnauthorized,
+			responseBody:   `{"error":"invalid_client"}`,
+			expectedError:  "unexpected status \"401\" introspecting token: {\"error\":\"invalid_client\"}",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var receivedForm url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assert.NoError(t, req.ParseForm())
+				receivedForm = req.PostForm
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.responseStatus)
+				_, err := rw.Write([]byte(tc.responseBody))
+				assert.NoError(t, err)
+			}))
+			defer server.Close()
+
+			introspectURL, err := url.Parse(server.URL)
+			assert.NoError(t, err)
+			p := &ProviderData{
+				ClientID:      "client",
+				ClientSecret:  "secret",
+				IntrospectURL: introspectURL,
+			}
+
+			session, err := p.IntrospectToken(context.Background(), "opaque")
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.Nil(t, session)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedSession, session)
+			}
+			assert.Equal(t, url.Values{
+				"token":           {"opaque"},
+				"token_type_hint": {"access_token"},
+				"client_id":       {"client"},
+				"client_secret":   {"secret"},
+			}, receivedForm)
+		})
+	}
+}
+
+func TestIntrospectTokenWithoutIntrospectURL(t *testing.T) {
+	p := &ProviderData{}
+	session, err := p.IntrospectToken(context.Background(), "opaque")
+	assert.EqualError(t, err, "provider has no introspection URL")
+	assert.Nil(t, session)
+}
+
+func TestIntrospectTokenCaching(t *testing.T) {
+	now := time.Now()
+
+	testCases := map[string]struct {
+		expiry        time.Time
+		elapsed       time.Duration
+		expectedCalls int
+		maxAge        time.Duration
+	}{
+		"within the token expiry and maximum duration": {
+			expiry:        now.Add(time.Hour),
+			elapsed:       time.Minute,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 1,
+		},
+		"after the maximum duration": {
+			expiry:        now.Add(time.Hour),
+			elapsed:       6 * time.Minute,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 2,
+		},
+		"after the token expiry": {
+			expiry:        now.Add(2 * time.Minute),
+			elapsed:       3 * time.Minute,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 2,
+		},
+		"with caching disabled": {
+			expiry:        now.Add(time.Hour),
+			elapsed:       0,
+			maxAge:        0,
+			expectedCalls: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls++
+				rw.Header().Set("Content-Type", "application/json")
+				_, err := rw.Write([]byte(`{"active":true,"sub":"123456","exp":` + formatUnix(tc.expiry) + `}`))
+				assert.NoError(t, err)
+			}))
+			defer server.Close()
+
+			introspectURL, err := url.Parse(server.URL)
+			assert.NoError(t, err)
+			p := &ProviderData{
+				ClientID:           "client",
+				ClientSecret:       "secret",
+				IntrospectURL:      introspectURL,
+				introspectionCache: newIntrospectionCache(tc.maxAge),
+			}
+			p.introspectionCache.clock.Set(now)
+
+			_, err = p.IntrospectToken(context.Background(), "opaque")
+			assert.NoError(t, err)
+
+			assert.NoError(t, p.introspectionCache.clock.Add(tc.elapsed))
+			session, err := p.IntrospectToken(context.Background(), "opaque")
+			assert.NoError(t, err)
+			assert.Equal(t, "123456", session.User)
+			assert.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestIntrospectTokenFailureCaching(t *testing.T) {
+	now := time.Now()
+
+	testCases := map[string]struct {
+		status        int
+		responseBody  string
+		elapsed       time.Duration
+		expectedCalls int
+		maxAge        time.Duration
+	}{
+		"inactive token within the failure duration": {
+			status:        http.StatusOK,
+			responseBody:  `{"active":false}`,
+			elapsed:       5 * time.Second,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 1,
+		},
+		"inactive token after the failure duration": {
+			status:        http.StatusOK,
+			responseBody:  `{"active":false}`,
+			elapsed:       11 * time.Second,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 2,
+		},
+		"rejected introspection within the failure duration": {
+			status:        http.StatusBadRequest,
+			responseBody:  `{}`,
+			elapsed:       5 * time.Second,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 1,
+		},
+		"rate limited introspection": {
+			status:        http.StatusTooManyRequests,
+			responseBody:  `{}`,
+			elapsed:       5 * time.Second,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 2,
+		},
+		"failed introspection": {
+			status:        http.StatusInternalServerError,
+			responseBody:  `{}`,
+			elapsed:       5 * time.Second,
+			maxAge:        5 * time.Minute,
+			expectedCalls: 2,
+		},
+		"inactive token after a maximum duration shorter than the failure duration": {
+			status:        http.StatusOK,
+			responseBody:  `{"active":false}`,
+			elapsed:       5 * time.Second,
+			maxAge:        time.Second,
+			expectedCalls: 2,
+		},
+		"inactive token with caching disabled": {
+			status:        http.StatusOK,
+			responseBody:  `{"active":false}`,
+			elapsed:       0,
+			maxAge:        0,
+			expectedCalls: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls++
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.status)
+				_, err := rw.Write([]byte(tc.responseBody))
+				assert.NoError(t, err)
+			}))
+			defer server.Close()
+
+			introspectURL, err := url.Parse(server.URL)
+			assert.NoError(t, err)
+			p := &ProviderData{
+				ClientID:           "client",
+				ClientSecret:       "secret",
+				IntrospectURL:      introspectURL,
+				introspectionCache: newIntrospectionCache(tc.maxAge),
+			}
+			p.introspectionCache.clock.Set(now)
+
+			_, err = p.IntrospectToken(context.Background(), "invalid")
+			assert.Error(t, err)
+
+			assert.NoError(t, p.introspectionCache.clock.Add(tc.elapsed))
+			_, err = p.IntrospectToken(context.Background(), "invalid")
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestIntrospectTokenTransportErrorNotCached(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	introspectURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	server.Close()
+
+	p := &ProviderData{
+		ClientID:           "client",
+		ClientSecret:       "secret",
+		IntrospectURL:      introspectURL,
+		introspectionCache: newIntrospectionCache(5 * time.Minute),
+	}
+	p.introspectionCache.clock.Set(time.Now())
+
+	_, err = p.IntrospectToken(context.Background(), "opaque")
+	assert.Error(t, err)
+	assert.Empty(t, p.introspectionCache.entries)
+}
+
+func TestIntrospectionCacheSize(t *testing.T) {
+	cache := newIntrospectionCache(5 * time.Minute)
+	cache.clock.Set(time.Now())
+
+	for i := 0; i <= introspectionCacheSize; i++ {
+		cache.setFailure(strconv.Itoa(i), errors.New("introspected token is not active"))
+	}
+	assert.Len(t, cache.entries, introspectionCacheSize)
+
+	_, ok := cache.get(strconv.Itoa(introspectionCacheSize))
+	assert.True(t, ok)
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -20,6 +20,9 @@ var _ Provider = (*KeycloakProvider)(nil)
 const (
 	keycloakProviderName = "Keycloak"
 	keycloakDefaultScope = "api"
+
+	keycloakLoginPathSuffix  = "/protocol/openid-connect/auth"
+	keycloakLogoutPathSuffix = "/protocol/openid-connect/logout"
 )
 
 var (
@@ -59,6 +62,8 @@ func NewKeycloakProvider(p *ProviderData, opts options.KeycloakOptions) *Keycloa
 		scope:       keycloakDefaultScope,
 	})
 
+	p.deriveLogoutURL(keycloakLoginPathSuffix, keycloakLogoutPathSuffix)
+
 	provider := &KeycloakProvider{ProviderData: p}
 	provider.setAllowedGroups(opts.Groups)
 	return provider
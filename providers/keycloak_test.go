@@ -57,6 +57,20 @@ var _ = Describe("Keycloak Provider Tests", func() {
 			Expect(providerData.ProfileURL.String()).To(Equal(""))
 			Expect(providerData.ValidateURL.String()).To(Equal("https://keycloak.org/api/v3/user"))
 			Expect(providerData.Scope).To(Equal("api"))
+			Expect(providerData.LogoutURL).To(BeNil())
+		})
+
+		It("derives the logout URL from the login URL", func() {
+			p := NewKeycloakProvider(
+				&ProviderData{
+					LoginURL: &url.URL{
+						Scheme: "https",
+						Host:   "example.com",
+						Path:   "/realms/test/protocol/openid-connect/auth"},
+				},
+				options.KeycloakOptions{})
+
+			Expect(p.Data().LogoutURL.String()).To(Equal("https://example.com/realms/test/protocol/openid-connect/logout"))
 		})
 
 		It("overrides defaults", func() {
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -152,6 +153,7 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 		s.User = newSession.User
 		s.Groups = newSession.Groups
 		s.PreferredUsername = newSession.PreferredUsername
+		s.Claims = newSession.Claims
 	}
 
 	s.AccessToken = newSession.AccessToken
@@ -174,6 +176,15 @@ func (p *OIDCProvider) CreateSessionFromToken(ctx context.Context, token string)
 		return nil, err
 	}
 
+	var claims struct {
+		Scope string      `json:"scope"`
+		Scp   interface{} `json:"scp"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse bearer token claims: %v", err)
+	}
+	ss.Scopes = middleware.ParseScopes(claims.Scope, claims.Scp)
+
 	// Allow empty Email in Bearer case since we can't hit the ProfileURL
 	if ss.Email == "" {
 		ss.Email = ss.User
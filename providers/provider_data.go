@@ -33,6 +33,9 @@ type ProviderData struct {
 	ProfileURL        *url.URL
 	ProtectedResource *url.URL
 	ValidateURL       *url.URL
+	LogoutURL         *url.URL
+	RevokeURL         *url.URL
+	IntrospectURL     *url.URL
 	ClientID          string
 	ClientSecret      string
 	ClientSecretFile  string
@@ -47,6 +50,7 @@ type ProviderData struct {
 	UserClaim            string
 	EmailClaim           string
 	GroupsClaim          string
+	ExtraClaims          []string
 	Verifier             internaloidc.IDTokenVerifier
 
 	// Universal Group authorization data structure
@@ -54,6 +58,7 @@ type ProviderData struct {
 	AllowedGroups map[string]struct{}
 
 	getAuthorizationHeaderFunc func(string) http.Header
+	introspectionCache         *introspectionCache
 	loginURLParameterDefaults  url.Values
 	loginURLParameterOverrides map[string]*regexp.Regexp
 }
@@ -169,6 +174,28 @@ func regexpForRule(rule options.URLParameterRule) string {
 	return "(?:" + *rule.Pattern + ")"
 }
 
+// deriveLogoutURL sets the LogoutURL from the LoginURL by replacing the
+// loginSuffix of its path with the logoutSuffix.
+// A configured LogoutURL is never overridden.
+func (p *ProviderData) deriveLogoutURL(loginSuffix, logoutSuffix string) {
+	if p.LogoutURL != nil && p.LogoutURL.String() != "" {
+		return
+	}
+	if p.LoginURL == nil {
+		return
+	}
+
+	loginPath := strings.TrimSuffix(p.LoginURL.Path, "/")
+	if !strings.HasSuffix(loginPath, loginSuffix) {
+		return
+	}
+
+	logoutURL := *p.LoginURL
+	logoutURL.Path = strings.TrimSuffix(loginPath, loginSuffix) + logoutSuffix
+	logoutURL.RawQuery = ""
+	p.LogoutURL = &logoutURL
+}
+
 // setAllowedGroups organizes a group list into the AllowedGroups map
 // to be consumed by Authorize implementations
 func (p *ProviderData) setAllowedGroups(groups []string) {
@@ -263,6 +290,21 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 		}
 	}
 
+	for _, claim := range p.ExtraClaims {
+		var values []string
+		exists, err := extractor.GetClaimInto(claim, &values)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if ss.Claims == nil {
+			ss.Claims = map[string][]string{}
+		}
+		ss.Claims[claim] = values
+	}
+
 	// `email_verified` must be present and explicitly set to `false` to be
 	// considered unverified.
 	verifyEmail := (p.EmailClaim == options.OIDCEmailClaim) && !p.AllowUnverifiedEmail
@@ -108,6 +108,20 @@ var (
 	minimalIDToken = idTokenClaims{
 		StandardClaims: standardClaims,
 	}
+
+	extraClaimsIDToken = idTokenClaims{
+		Name:     "Jane Dobbs",
+		Email:    "janed@me.com",
+		Phone:    "+4798765432",
+		Groups:   []string{"test:a", "test:b"},
+		Roles:    []string{"test:c", "test:d"},
+		Verified: &verified,
+		Address: map[string]interface{}{
+			"country":  "NO",
+			"postcode": 1234,
+		},
+		StandardClaims: standardClaims,
+	}
 )
 
 type idTokenClaims struct {
@@ -119,6 +133,7 @@ type idTokenClaims struct {
 	Roles    interface{} `json:"roles,omitempty"`
 	Verified *bool       `json:"email_verified,omitempty"`
 	Nonce    string      `json:"nonce,omitempty"`
+	Address  interface{} `json:"address,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -238,6 +253,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 		UserClaim       string
 		EmailClaim      string
 		GroupsClaim     string
+		ExtraClaims     []string
 		ExpectedError   error
 		ExpectedSession *sessions.SessionState
 	}{
@@ -408,6 +424,27 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				PreferredUsername: "Jane Dobbs",
 			},
 		},
+		"Extra Claims": {
+			IDToken:         extraClaimsIDToken,
+			AllowUnverified: false,
+			EmailClaim:      "email",
+			GroupsClaim:     "groups",
+			UserClaim:       "sub",
+			ExtraClaims:     []string{"phone_number", "roles", "address", "address.country", "address.postcode", "missing"},
+			ExpectedSession: &sessions.SessionState{
+				User:              "123456789",
+				Email:             "janed@me.com",
+				Groups:            []string{"test:a", "test:b"},
+				PreferredUsername: "Jane Dobbs",
+				Claims: map[string][]string{
+					"phone_number":     {"+4798765432"},
+					"roles":            {"test:c", "test:d"},
+					"address":          {"{\"country\":\"NO\",\"postcode\":1234}"},
+					"address.country":  {"NO"},
+					"address.postcode": {"1234"},
+				},
+			},
+		},
 	}
 	for testName, tc := range testCases {
 		t.Run(testName, func(t *testing.T) {
@@ -428,6 +465,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			provider.UserClaim = tc.UserClaim
 			provider.EmailClaim = tc.EmailClaim
 			provider.GroupsClaim = tc.GroupsClaim
+			provider.ExtraClaims = tc.ExtraClaims
 
 			rawIDToken, err := newSignedTestIDToken(tc.IDToken)
 			g.Expect(err).ToNot(HaveOccurred())
@@ -5,11 +5,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokenExchangeGrantType is the grant type of RFC 8693 token exchanges
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// accessTokenType identifies access tokens in RFC 8693 token exchanges
+	accessTokenType = "urn:ietf:params:oauth:token-type:access_token"
 )
 
 var (
@@ -139,6 +151,133 @@ func (p *ProviderData) RefreshSession(_ context.Context, _ *sessions.SessionStat
 	return false, ErrNotImplemented
 }
 
+// GetLogoutURL returns the URL users should be sent to in order to end their
+// session with the provider, following OIDC RP-Initiated Logout.
+// An empty string is returned when the provider has no logout URL.
+func (p *ProviderData) GetLogoutURL(s *sessions.SessionState, postLogoutRedirectURI string) string {
+	if p.LogoutURL == nil || p.LogoutURL.String() == "" {
+		return ""
+	}
+
+	logoutURL := *p.LogoutURL
+	params, _ := url.ParseQuery(logoutURL.RawQuery)
+	if s != nil && s.IDToken != "" {
+		params.Set("id_token_hint", s.IDToken)
+	} else {
+		params.Set("client_id", p.ClientID)
+	}
+	if postLogoutRedirectURI != "" {
+		params.Set("post_logout_redirect_uri", postLogoutRedirectURI)
+	}
+	logoutURL.RawQuery = params.Encode()
+	return logoutURL.String()
+}
+
+// RevokeSession revokes the session's tokens at the RevokeURL following
+// RFC 7009. The refresh token is preferred as providers may also invalidate
+// the access tokens issued with it, otherwise the access token is revoked.
+// It is a no-op when the provider has no RevokeURL.
+func (p *ProviderData) RevokeSession(ctx context.Context, s *sessions.SessionState) error {
+	if p.RevokeURL == nil || p.RevokeURL.String() == "" || s == nil {
+		return nil
+	}
+
+	token, tokenTypeHint := s.RefreshToken, "refresh_token"
+	if token == "" {
+		token, tokenTypeHint = s.AccessToken, "access_token"
+	}
+	if token == "" {
+		return nil
+	}
+
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Add("token", token)
+	params.Add("token_type_hint", tokenTypeHint)
+	params.Add("client_id", p.ClientID)
+	params.Add("client_secret", clientSecret)
+
+	result := requests.New(p.RevokeURL.String()).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do()
+	if result.Error() != nil {
+		return result.Error()
+	}
+	if result.StatusCode() != http.StatusOK {
+		return fmt.Errorf("unexpected status \"%d\" revoking %s: %s", result.StatusCode(), tokenTypeHint, result.Body())
+	}
+	return nil
+}
+
+// ExchangeToken exchanges the session's access token at the RedeemURL for an
+// access token issued for the audience and scopes, following RFC 8693.
+// The expiry of the exchanged token is unset if the provider omits it.
+func (p *ProviderData) ExchangeToken(ctx context.Context, s *sessions.SessionState, audience string, scopes []string) (*oauth2.Token, error) {
+	if s == nil || s.AccessToken == "" {
+		return nil, errors.New("session has no access token to exchange")
+	}
+
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("grant_type", tokenExchangeGrantType)
+	params.Add("subject_token", s.AccessToken)
+	params.Add("subject_token_type", accessTokenType)
+	params.Add("requested_token_type", accessTokenType)
+	params.Add("client_id", p.ClientID)
+	params.Add("client_secret", clientSecret)
+	if audience != "" {
+		params.Add("audience", audience)
+	}
+	if len(scopes) > 0 {
+		params.Add("scope", strings.Join(scopes, " "))
+	}
+
+	result := requests.New(p.RedeemURL.String()).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		Do()
+	if result.Error() != nil {
+		return nil, result.Error()
+	}
+	if result.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status \"%d\" exchanging token: %s", result.StatusCode(), result.Body())
+	}
+
+	var jsonResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := result.UnmarshalInto(&jsonResponse); err != nil {
+		return nil, fmt.Errorf("error parsing token exchange response: %v", err)
+	}
+	if jsonResponse.AccessToken == "" {
+		return nil, fmt.Errorf("no access token found %s", result.Body())
+	}
+
+	token := &oauth2.Token{
+		AccessToken: jsonResponse.AccessToken,
+		TokenType:   jsonResponse.TokenType,
+	}
+	if jsonResponse.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(jsonResponse.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
 // CreateSessionFromToken converts Bearer IDTokens into sessions
 func (p *ProviderData) CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error) {
 	if p.Verifier != nil {
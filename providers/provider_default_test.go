@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -119,3 +121,237 @@ func TestProviderDataAuthorize(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLogoutURL(t *testing.T) {
+	logoutURL := &url.URL{
+		Scheme:   "https",
+		Host:     "my.test.idp",
+		Path:     "/oauth/logout",
+		RawQuery: "foo=bar",
+	}
+
+	testCases := map[string]struct {
+		logoutURL          *url.URL
+		session            *sessions.SessionState
+		postLogoutRedirect string
+		expectedURL        string
+	}{
+		"without a logout URL": {
+			logoutURL:          nil,
+			session:            &sessions.SessionState{IDToken: "id_token"},
+			postLogoutRedirect: "https://my.test.app/",
+			expectedURL:        "",
+		},
+		"with an ID token": {
+			logoutURL:          logoutURL,
+			session:            &sessions.SessionState{IDToken: "id_token"},
+			postLogoutRedirect: "https://my.test.app/",
+			expectedURL:        "https://my.test.idp/oauth/logout?foo=bar&id_token_hint=id_token&post_logout_redirect_uri=https%3A%2F%2Fmy.test.app%2F",
+		},
+		"without an ID token": {
+			logoutURL:          logoutURL,
+			session:            &sessions.SessionState{},
+			postLogoutRedirect: "https://my.test.app/",
+			expectedURL:        "https://my.test.idp/oauth/logout?client_id=client&foo=bar&post_logout_redirect_uri=https%3A%2F%2Fmy.test.app%2F",
+		},
+		"without a post logout redirect": {
+			logoutURL:          logoutURL,
+			session:            &sessions.SessionState{IDToken: "id_token"},
+			postLogoutRedirect: "",
+			expectedURL:        "https://my.test.idp/oauth/logout?foo=bar&id_token_hint=id_token",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := &ProviderData{
+				ClientID:  "client",
+				LogoutURL: tc.logoutURL,
+			}
+			assert.Equal(t, tc.expectedURL, p.GetLogoutURL(tc.session, tc.postLogoutRedirect))
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	testCases := map[string]struct {
+		session           *sessions.SessionState
+		responseStatus    int
+		expectedForm      url.Values
+		expectedError     string
+		expectNoRevokeURL bool
+	}{
+		"without a revoke URL": {
+			session:           &sessions.SessionState{AccessToken: "access_token"},
+			expectNoRevokeURL: true,
+		},
+		"with a refresh token": {
+			session:        &sessions.SessionState{AccessToken: "access_token", RefreshToken: "refresh_token"},
+			responseStatus: http.StatusOK,
+			expectedForm: url.Values{
+				"token":           {"refresh_token"},
+				"token_type_hint": {"refresh_token"},
+				"client_id":       {"client"},
+				"client_secret":   {"secret"},
+			},
+		},
+		"with only an access token": {
+			session:        &sessions.SessionState{AccessToken: "access_token"},
+			responseStatus: http.StatusOK,
+			expectedForm: url.Values{
+				"token":           {"access_token"},
+				"token_type_hint": {"access_token"},
+				"client_id":       {"client"},
+				"client_secret":   {"secret"},
+			},
+		},
+		"without tokens": {
+			session: &sessions.SessionState{},
+		},
+		"with an error response": {
+			session:        &sessions.SessionState{AccessToken: "access_token"},
+			responseStatus: http.StatusServiceUnavailable,
+			expectedForm: url.Values{
+				"token":           {"access_token"},
+				"token_type_hint": {"access_token"},
+				"client_id":       {"client"},
+				"client_secret":   {"secret"},
+			},
+			expectedError: "unexpected status \"503\" revoking access_token: ",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var receivedForm url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assert.NoError(t, req.ParseForm())
+				receivedForm = req.PostForm
+				rw.WriteHeader(tc.responseStatus)
+			}))
+			defer server.Close()
+
+			p := &ProviderData{
+				ClientID:     "client",
+				ClientSecret: "secret",
+			}
+			if !tc.expectNoRevokeURL {
+				revokeURL, err := url.Parse(server.URL)
+				assert.NoError(t, err)
+				p.RevokeURL = revokeURL
+			}
+
+			err := p.RevokeSession(context.Background(), tc.session)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedForm, receivedForm)
+		})
+	}
+}
+
+func TestExchangeToken(t *testing.T) {
+	testCases := map[string]struct {
+		session        *sessions.SessionState
+		audience       string
+		scopes         []string
+		responseStatus int
+		responseBody   string
+		expectedForm   url.Values
+		expectedToken  string
+		expectExpiry   bool
+		expectedError  string
+	}{
+		"with an audience and scopes": {
+			session:        &sessions.SessionState{AccessToken: "access_token"},
+			audience:       "api",
+			scopes:         []string{"read", "write"},
+			responseStatus: http.StatusOK,
+			responseBody:   `{"access_token":"exchanged","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":300}`,
+			expectedForm: url.Values{
+				"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+				"subject_token":        {"access_token"},
+				"subject_token_type":   {"urn:ietf:params:oauth:token-type:access_token"},
+				"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+				"client_id":            {"client"},
+				"client_secret":        {"secret"},
+				"audience":             {"api"},
+				"scope":                {"read write"},
+			},
+			expectedToken: "exchanged",
+			expectExpiry:  true,
+		},
+		"without an expiry": {
+			session:        &sessions.SessionState{AccessToken: "access_token"},
+			audience:       "api",
+			responseStatus: http.StatusOK,
+			responseBody:   `{"access_token":"exchanged","token_type":"Bearer"}`,
+			expectedForm: url.Values{
+				"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+				"subject_token":        {"access_token"},
+				"subject_token_type":   {"urn:ietf:params:oauth:token-type:access_token"},
+				"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+				"client_id":            {"client"},
+				"client_secret":        {"secret"},
+				"audience":             {"api"},
+			},
+			expectedToken: "exchanged",
+		},
+		"without an access token": {
+			session:       &sessions.SessionState{},
+			expectedError: "session has no access token to exchange",
+		},
+		"with an error response": {
+			session:        &sessions.SessionState{AccessToken: "access_token"},
+			audience:       "api",
+			responseStatus: http.StatusBadRequest,
+			responseBody:   `{"error":"invalid_target"}`,
+			expectedForm: url.Values{
+				"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+				"subject_token":        {"access_token"},
+				"subject_token_type":   {"urn:ietf:params:oauth:token-type:access_token"},
+				"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+				"client_id":            {"client"},
+				"client_secret":        {"secret"},
+				"audience":             {"api"},
+			},
+			expectedError: "unexpected status \"400\" exchanging token: {\"error\":\"invalid_target\"}",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var receivedForm url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assert.NoError(t, req.ParseForm())
+				receivedForm = req.PostForm
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.responseStatus)
+				_, err := rw.Write([]byte(tc.responseBody))
+				assert.NoError(t, err)
+			}))
+			defer server.Close()
+
+			redeemURL, err := url.Parse(server.URL)
+			assert.NoError(t, err)
+			p := &ProviderData{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedeemURL:    redeemURL,
+			}
+
+			token, err := p.ExchangeToken(context.Background(), tc.session, tc.audience, tc.scopes)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedToken, token.AccessToken)
+				assert.Equal(t, tc.expectExpiry, !token.Expiry.IsZero())
+			}
+			assert.Equal(t, tc.expectedForm, receivedForm)
+		})
+	}
+}
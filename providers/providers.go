@@ -9,6 +9,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
+	"golang.org/x/oauth2"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
@@ -29,6 +30,9 @@ type Provider interface {
 	ValidateSession(ctx context.Context, s *sessions.SessionState) bool
 	RefreshSession(ctx context.Context, s *sessions.SessionState) (bool, error)
 	CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error)
+	GetLogoutURL(s *sessions.SessionState, postLogoutRedirectURI string) string
+	RevokeSession(ctx context.Context, s *sessions.SessionState) error
+	ExchangeToken(ctx context.Context, s *sessions.SessionState, audience string, scopes []string) (*oauth2.Token, error)
 }
 
 func NewProvider(providerConfig options.Provider) (Provider, error) {
@@ -106,6 +110,12 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			providerConfig.RedeemURL = endpoints.TokenURL
 			providerConfig.ProfileURL = endpoints.UserInfoURL
 			providerConfig.OIDCConfig.JwksURL = endpoints.JWKsURL
+			if endpoints.EndSessionURL != "" {
+				providerConfig.LogoutURL = endpoints.EndSessionURL
+			}
+			if endpoints.RevocationURL != "" {
+				providerConfig.RevokeURL = endpoints.RevocationURL
+			}
 			p.SupportedCodeChallengeMethods = pkce.CodeChallengeAlgs
 		}
 	}
@@ -115,11 +125,14 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		dst **url.URL
 		raw string
 	}{
-		"login":    {dst: &p.LoginURL, raw: providerConfig.LoginURL},
-		"redeem":   {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
-		"profile":  {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
-		"validate": {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
-		"resource": {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
+		"login":      {dst: &p.LoginURL, raw: providerConfig.LoginURL},
+		"redeem":     {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
+		"profile":    {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
+		"validate":   {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
+		"logout":     {dst: &p.LogoutURL, raw: providerConfig.LogoutURL},
+		"revoke":     {dst: &p.RevokeURL, raw: providerConfig.RevokeURL},
+		"introspect": {dst: &p.IntrospectURL, raw: providerConfig.IntrospectURL},
+		"resource":   {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
 	} {
 		var err error
 		*u.dst, err = url.Parse(u.raw)
@@ -138,6 +151,7 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	p.AllowUnverifiedEmail = providerConfig.OIDCConfig.InsecureAllowUnverifiedEmail
 	p.EmailClaim = providerConfig.OIDCConfig.EmailClaim
 	p.GroupsClaim = providerConfig.OIDCConfig.GroupsClaim
+	p.ExtraClaims = providerConfig.OIDCConfig.ExtraClaims
 
 	// Set PKCE enabled or disabled based on discovery and force options
 	p.CodeChallengeMethod = parseCodeChallengeMethod(providerConfig)
@@ -165,6 +179,14 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 
 	p.setAllowedGroups(providerConfig.AllowedGroups)
 
+	if providerConfig.IntrospectURL != "" {
+		maxAge := options.DefaultIntrospectCacheDuration
+		if providerConfig.IntrospectCacheDuration != nil {
+			maxAge = providerConfig.IntrospectCacheDuration.Duration()
+		}
+		p.introspectionCache = newIntrospectionCache(maxAge)
+	}
+
 	return p, nil
 }
 